@@ -8,6 +8,7 @@ import (
 	"example/pkg/sql"
 	"fmt"
 	"github.com/urfave/cli/v2"
+	"io"
 	"log"
 	"os"
 )
@@ -16,6 +17,40 @@ func main() {
 	app := &cli.App{
 		Name:  "sql",
 		Usage: "Queries piped data",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "lenient-types",
+				Usage: "coerce strings that look like numbers into numbers when comparing",
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:      "fmt",
+				Usage:     "prints a query formatted, read from the first argument or piped in",
+				ArgsUsage: "[query]",
+				Action: func(ctx *cli.Context) error {
+					queryString := ctx.Args().Get(0)
+
+					if queryString == "" {
+						piped, err := io.ReadAll(os.Stdin)
+						if err != nil {
+							return err
+						}
+
+						queryString = string(piped)
+					}
+
+					query, err := sql.Parse(queryString)
+					if err != nil {
+						return err
+					}
+
+					fmt.Println(query.Format())
+
+					return nil
+				},
+			},
+		},
 		Action: func(ctx *cli.Context) error {
 			queryString := ctx.Args().Get(0)
 
@@ -28,12 +63,23 @@ func main() {
 				return err
 			}
 
-			dataRows, err := input.NewStdinReader().Parse(bufio.NewReader(os.Stdin))
-			if err != nil {
-				return err
+			var options []sql.ExecutorOption
+			if ctx.Bool("lenient-types") {
+				options = append(options, sql.LenientTypes())
+			}
+
+			executor := sql.NewExecutor(*query, options...)
+
+			// a query that only reads files and its own tables doesn't need anything piped in
+			var dataRows []input.DataRow
+			if executor.ReadsPipedRows() {
+				dataRows, err = input.NewStdinReader().Parse(bufio.NewReader(os.Stdin))
+				if err != nil {
+					return err
+				}
 			}
 
-			result, err := sql.NewExecutor(*query).QueryData(dataRows)
+			result, err := executor.QueryData(dataRows)
 			if err != nil {
 				return err
 			}
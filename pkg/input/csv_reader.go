@@ -0,0 +1,86 @@
+package input
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type CsvReader struct {
+}
+
+// reads csv with a header row into a series of data rows, cells that are valid json numbers are numbers and empty
+// cells are null so lookup files compare the same way newline delimited json does
+func (c CsvReader) Parse(buf *bufio.Reader) ([]DataRow, error) {
+	reader := csv.NewReader(buf)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []DataRow
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line := DataRow{}
+		for i, cell := range record {
+			line[header[i]] = csvValue(cell)
+		}
+
+		lines = append(lines, line)
+	}
+}
+
+func csvValue(cell string) interface{} {
+	if cell == "" {
+		return nil
+	}
+
+	var number json.Number
+	decoder := json.NewDecoder(strings.NewReader(cell))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&number); err == nil && !decoder.More() && string(number) == cell {
+		return number
+	}
+
+	return cell
+}
+
+func NewCsvReader() *CsvReader {
+	return &CsvReader{}
+}
+
+// ReadFile reads a .csv file as csv and anything else as newline delimited json
+func ReadFile(path string) ([]DataRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		return NewCsvReader().Parse(bufio.NewReader(file))
+	}
+
+	return NewStdinReader().Parse(bufio.NewReader(file))
+}
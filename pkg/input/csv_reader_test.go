@@ -0,0 +1,67 @@
+package input
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadsCsv(t *testing.T) {
+	data := "id,name,score\n1,bob,\n2,\"smith, jane\",1.50\n007,3 cats,-2\n"
+
+	result, err := NewCsvReader().Parse(bufio.NewReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []DataRow{
+		{"id": json.Number("1"), "name": "bob", "score": nil},
+		{"id": json.Number("2"), "name": "smith, jane", "score": json.Number("1.50")},
+		{"id": "007", "name": "3 cats", "score": json.Number("-2")},
+	}
+
+	if !reflect.DeepEqual(result, expect) {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
+
+func TestReadsFilesByExtension(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "hosts.csv"), []byte("host,dc\nweb1,eu\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "users.ndjson"), []byte(`{"id": 1, "name": "bob"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, err := ReadFile(filepath.Join(dir, "hosts.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(hosts, []DataRow{{"host": "web1", "dc": "eu"}}) {
+		t.Logf("%v", hosts)
+		t.Fail()
+	}
+
+	users, err := ReadFile(filepath.Join(dir, "users.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(users, []DataRow{{"id": json.Number("1"), "name": "bob"}}) {
+		t.Logf("%v", users)
+		t.Fail()
+	}
+
+	if _, err := ReadFile(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Fail()
+	}
+}
@@ -3,6 +3,7 @@ package input
 import (
 	"bufio"
 	"encoding/json"
+	"strings"
 )
 
 type DataRow map[string]interface{}
@@ -25,7 +26,11 @@ func (s StdinReader) Parse(buf *bufio.Reader) ([]DataRow, error) {
 
 		var line DataRow
 
-		if err := json.Unmarshal([]byte(text), &line); err != nil {
+		// numbers are kept as json.Number so large integers and decimals survive a round trip untouched
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&line); err != nil {
 			return nil, err
 		}
 
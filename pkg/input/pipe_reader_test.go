@@ -3,6 +3,7 @@ package input
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"reflect"
 	"testing"
 )
@@ -20,11 +21,30 @@ func TestReadsPipe(t *testing.T) {
 	}
 
 	expect := []DataRow{
-		{"foo": 1},
-		{"foo": 2},
+		{"foo": json.Number("1")},
+		{"foo": json.Number("2")},
 	}
 
 	if !reflect.DeepEqual(result, expect) {
-		t.Failed()
+		t.Fail()
+	}
+}
+
+func TestReadsNumbersWithoutLosingPrecision(t *testing.T) {
+	data := []byte(`{"id": 1234567890123456789, "price": 1.50, "big": 1e400}`)
+
+	result, err := NewStdinReader().Parse(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := json.Marshal(result[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(output) != `{"big":1e400,"id":1234567890123456789,"price":1.50}` {
+		t.Log(string(output))
+		t.Fail()
 	}
 }
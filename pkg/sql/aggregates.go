@@ -0,0 +1,296 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Aggregate folds the rows of a group into a single value. A group can be split into parts that are accumulated
+// separately and combined with Merge, so Merge must give the same result as accumulating every row into one Aggregate
+type Aggregate interface {
+	Accumulate(args []interface{}) error
+	// Merge folds in another partial Aggregate made by the same function
+	Merge(other Aggregate) error
+	// Finalize can be called again after more rows are accumulated, window frames that grow row by row rely on it
+	Finalize() (interface{}, error)
+}
+
+// builtinAggregate takes a value from every row followed by params parameters that are the same for the whole group
+type builtinAggregate struct {
+	// the number of values taken from every row when it is more than one
+	values int
+	params int
+	// ordered set aggregates can take their value from within group (order by x) instead of the first argument
+	orderedSet bool
+	init       func(params []interface{}) (Aggregate, error)
+}
+
+// an aggregate of a single value without parameters
+func simpleAggregate(init func() Aggregate) builtinAggregate {
+	return builtinAggregate{
+		init: func(params []interface{}) (Aggregate, error) {
+			return init(), nil
+		},
+	}
+}
+
+var aggregateFunctions = map[string]builtinAggregate{
+	"count":   simpleAggregate(func() Aggregate { return &countAggregator{} }),
+	"sum":     simpleAggregate(func() Aggregate { return &sumAggregator{} }),
+	"avg":     simpleAggregate(func() Aggregate { return &averageAggregator{} }),
+	"average": simpleAggregate(func() Aggregate { return &averageAggregator{} }),
+	"min":     simpleAggregate(func() Aggregate { return &extremeAggregator{keep: -1} }),
+	"max":     simpleAggregate(func() Aggregate { return &extremeAggregator{keep: 1} }),
+}
+
+// aggregateFunction is a builtin or registered aggregate
+type aggregateFunction struct {
+	// the declared types of a registered aggregate, builtins take values of any type
+	args       []Type
+	returns    Type
+	values     int
+	params     int
+	orderedSet bool
+	init       func(params []interface{}) (Aggregate, error)
+}
+
+func isAggregate(name string) bool {
+	_, ok := aggregateFunctions[strings.ToLower(name)]
+
+	return ok
+}
+
+func (s *Executor) aggregateFunction(name string) (aggregateFunction, bool) {
+	if builtin, ok := aggregateFunctions[strings.ToLower(name)]; ok {
+		return aggregateFunction{
+			values:     tern(builtin.values > 1, builtin.values, 1),
+			params:     builtin.params,
+			orderedSet: builtin.orderedSet,
+			init:       builtin.init,
+		}, true
+	}
+
+	if udf, ok := s.registry.aggregate(name); ok {
+		return aggregateFunction{
+			args:    udf.Args,
+			returns: udf.Returns,
+			init: func(params []interface{}) (Aggregate, error) {
+				return udf.Init(), nil
+			},
+		}, true
+	}
+
+	return aggregateFunction{}, false
+}
+
+// the number of arguments a call takes, values and parameters
+func (f aggregateFunction) arity() int {
+	return tern(f.args == nil, f.values+f.params, len(f.args))
+}
+
+func (s *Executor) isAggregate(name string) bool {
+	_, ok := s.aggregateFunction(name)
+
+	return ok
+}
+
+// true when the expression or anything under it is an aggregate call
+func (s *Executor) containsAggregate(expr *Expr) bool {
+	switch {
+	case expr == nil:
+		return false
+	case expr.Call != nil && expr.Call.Over != nil:
+		// a window aggregate runs over the window rather than a group
+		return false
+	case expr.Call != nil:
+		if s.isAggregate(expr.Call.Name) {
+			return true
+		}
+
+		for i := range expr.Call.Args {
+			if s.containsAggregate(&expr.Call.Args[i]) {
+				return true
+			}
+		}
+	case expr.Cast != nil:
+		return s.containsAggregate(&expr.Cast.Expr)
+	case expr.Binary != nil:
+		return s.containsAggregate(&expr.Binary.Left) || s.containsAggregate(&expr.Binary.Right)
+	case expr.Case != nil:
+		return slices.ContainsFunc(expr.Case.exprs(), s.containsAggregate)
+	}
+
+	return false
+}
+
+// partial aggregates can only be merged with partials of the same function
+func mergeError(into Aggregate, other Aggregate) error {
+	return errors.New(fmt.Sprintf("cannot merge %s into %s", reflect.TypeOf(other), reflect.TypeOf(into)))
+}
+
+// count(*) counts rows, count(x) counts the rows where x isn't null
+type countAggregator struct {
+	count int64
+}
+
+func (c *countAggregator) Accumulate(args []interface{}) error {
+	if args[0] != nil {
+		c.count++
+	}
+
+	return nil
+}
+
+func (c *countAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*countAggregator)
+	if !ok {
+		return mergeError(c, other)
+	}
+
+	c.count += partial.count
+
+	return nil
+}
+
+func (c *countAggregator) Finalize() (interface{}, error) {
+	return c.count, nil
+}
+
+// sums stay integers until they overflow, nulls are skipped
+type sumAggregator struct {
+	sum interface{}
+}
+
+func (a *sumAggregator) Accumulate(args []interface{}) error {
+	if args[0] == nil {
+		return nil
+	}
+
+	if !isNumeric(args[0]) {
+		return errors.New(fmt.Sprintf("cannot sum non numeric value %v", args[0]))
+	}
+
+	return a.add(args[0])
+}
+
+func (a *sumAggregator) add(value interface{}) error {
+	if a.sum == nil {
+		a.sum = value
+		return nil
+	}
+
+	sum, err := numericArithmetic("+", a.sum, value)
+	if err != nil {
+		return err
+	}
+
+	a.sum = sum
+
+	return nil
+}
+
+func (a *sumAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*sumAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	if partial.sum == nil {
+		return nil
+	}
+
+	return a.add(partial.sum)
+}
+
+func (a *sumAggregator) Finalize() (interface{}, error) {
+	return a.sum, nil
+}
+
+type averageAggregator struct {
+	sum   float64
+	count int64
+}
+
+func (a *averageAggregator) Accumulate(args []interface{}) error {
+	if args[0] == nil {
+		return nil
+	}
+
+	if !isNumeric(args[0]) {
+		return errors.New(fmt.Sprintf("cannot average non numeric value %v", args[0]))
+	}
+
+	value, err := CastValue(args[0], Double)
+	if err != nil {
+		return err
+	}
+
+	a.sum += value.(float64)
+	a.count++
+
+	return nil
+}
+
+func (a *averageAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*averageAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	a.sum += partial.sum
+	a.count += partial.count
+
+	return nil
+}
+
+func (a *averageAggregator) Finalize() (interface{}, error) {
+	if a.count == 0 {
+		return nil, nil
+	}
+
+	return a.sum / float64(a.count), nil
+}
+
+// keeps the smallest value when keep is -1 and the largest when it is 1
+type extremeAggregator struct {
+	keep  int
+	value interface{}
+}
+
+func (a *extremeAggregator) Accumulate(args []interface{}) error {
+	if args[0] == nil {
+		return nil
+	}
+
+	if a.value == nil {
+		a.value = args[0]
+		return nil
+	}
+
+	result, ok := compareValues(args[0], a.value)
+	if !ok {
+		return errors.New(fmt.Sprintf("cannot compare %s with %s", reflect.TypeOf(args[0]), reflect.TypeOf(a.value)))
+	}
+
+	if result == a.keep {
+		a.value = args[0]
+	}
+
+	return nil
+}
+
+func (a *extremeAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*extremeAggregator)
+	if !ok || partial.keep != a.keep {
+		return mergeError(a, other)
+	}
+
+	return a.Accumulate([]interface{}{partial.value})
+}
+
+func (a *extremeAggregator) Finalize() (interface{}, error) {
+	return a.value, nil
+}
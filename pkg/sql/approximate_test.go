@@ -0,0 +1,114 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestApproxCountDistinct(t *testing.T) {
+	for _, distinct := range []int{0, 10, 1000, 100000} {
+		var rows []input.DataRow
+		for i := 0; i < distinct; i++ {
+			rows = append(rows, input.DataRow{"user": fmt.Sprintf("user-%d", i)}, input.DataRow{"user": fmt.Sprintf("user-%d", i)})
+		}
+
+		rows = append(rows, input.DataRow{})
+
+		query, err := Parse(`select approx_count_distinct(user) as users`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, workers := range []int{1, 4} {
+			result, err := NewExecutor(*query, Parallelism(workers)).QueryData(rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			estimate := result[0]["users"].(int64)
+			if math.Abs(float64(estimate-int64(distinct))) > 0.02*float64(distinct) {
+				t.Errorf("%d distinct with %d workers estimated as %d", distinct, workers, estimate)
+			}
+		}
+	}
+}
+
+func TestApproxPercentile(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	var rows []input.DataRow
+	var values []float64
+
+	for i := 0; i < 50000; i++ {
+		// a long tailed latency distribution
+		value := math.Exp(random.NormFloat64()) * 100
+		values = append(values, value)
+		rows = append(rows, input.DataRow{"ms": value})
+	}
+
+	rows = append(rows, input.DataRow{"ms": nil})
+	sort.Float64s(values)
+
+	query, err := Parse(`select approx_percentile(ms, 0.5) as p50, approx_percentile(ms, 0.99) as p99, approx_median(ms) as median, approx_percentile(ms, 1) as max`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 3} {
+		result, err := NewExecutor(*query, Parallelism(workers)).QueryData(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for field, exact := range map[string]float64{
+			"p50":    values[len(values)/2],
+			"p99":    values[len(values)*99/100],
+			"median": values[len(values)/2],
+			"max":    values[len(values)-1],
+		} {
+			estimate := result[0][field].(float64)
+			if math.Abs(estimate-exact)/exact > 0.01 {
+				t.Errorf("%s with %d workers: expected about %f got %f", field, workers, exact, estimate)
+			}
+		}
+	}
+}
+
+func TestApproxPercentileEdgeCases(t *testing.T) {
+	query, err := Parse(`select approx_percentile(ms, 0.9) as p90, approx_median(ms) as median`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{{"ms": 7}})
+	if err != nil || result[0]["p90"] != 7.0 || result[0]["median"] != 7.0 {
+		t.Logf("%v %v", result, err)
+		t.Fail()
+	}
+
+	result, err = NewExecutor(*query).QueryData(nil)
+	if err != nil || result[0]["p90"] != nil {
+		t.Logf("%v %v", result, err)
+		t.Fail()
+	}
+
+	for _, sql := range []string{
+		`select approx_percentile(ms, 2) as p`,
+		`select approx_percentile(ms, ms) as p`,
+		`select approx_percentile(ms) as p`,
+		`select approx_percentile(name, 0.5) as p`,
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query).QueryData([]input.DataRow{{"ms": 1, "name": "a"}}); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
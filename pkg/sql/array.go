@@ -0,0 +1,246 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"example/pkg/util"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+func init() {
+	for name, function := range arrayFunctions {
+		scalarFunctions[name] = function
+	}
+
+	aggregateFunctions["array_agg"] = simpleAggregate(func() Aggregate { return &arrayAggregator{} })
+}
+
+var arrayFunctions = map[string]scalarFunction{
+	"array_length": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			array, err := arrayArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return int64(len(array)), nil
+		},
+	},
+	"array_contains": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			array, err := arrayArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return slices.ContainsFunc(array, func(element interface{}) bool {
+				result, ok := compareValues(normalize(element), normalize(args[1]))
+				return ok && result == 0
+			}), nil
+		},
+	},
+	// the element at a position counting from 1, or from the end when it is negative. Objects are read by key
+	"element_at": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			if object, ok := args[0].(map[string]interface{}); ok {
+				key, err := stringArg(args[1])
+				if err != nil {
+					return nil, err
+				}
+
+				return object[key], nil
+			}
+
+			array, err := arrayArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			position, ok := normalize(args[1]).(int64)
+			switch {
+			case !ok:
+				return nil, errors.New(fmt.Sprintf("element_at expects an integer position but got %v", args[1]))
+			case position == 0:
+				return nil, errors.New("element_at positions start at 1")
+			case position < 0:
+				position += int64(len(array)) + 1
+			}
+
+			if position < 1 || position > int64(len(array)) {
+				return nil, nil
+			}
+
+			return array[position-1], nil
+		},
+	},
+	// the array itself, a selected unnest is expanded into a row for each element after the fields are selected
+	"unnest": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return arrayArg(args[0])
+		},
+	},
+}
+
+// arrays are usually read from json as []interface{}, rows built in go can hold slices of any type
+func arrayArg(value interface{}) ([]interface{}, error) {
+	if array, ok := value.([]interface{}); ok {
+		return array, nil
+	}
+
+	reflected := reflect.ValueOf(value)
+	if value == nil || reflected.Kind() != reflect.Slice {
+		return nil, errors.New(fmt.Sprintf("expected an array but got %v", value))
+	}
+
+	array := make([]interface{}, reflected.Len())
+	for i := range array {
+		array[i] = reflected.Index(i).Interface()
+	}
+
+	return array, nil
+}
+
+// the array argument of any(tags) when expr is one, any(tags) = 'x' holds when any element equals 'x'
+func anyArg(expr *Expr) *Expr {
+	if expr == nil || expr.Call == nil || strings.ToLower(expr.Call.Name) != "any" || len(expr.Call.Args) != 1 {
+		return nil
+	}
+
+	return &expr.Call.Args[0]
+}
+
+func isUnnest(expr *Expr) bool {
+	return expr != nil && expr.Call != nil && strings.ToLower(expr.Call.Name) == "unnest"
+}
+
+// true when compare holds for any element of the array, never for a null array
+func anyElement(array interface{}, compare func(element interface{}) (bool, error)) (bool, error) {
+	if array == nil {
+		return false, nil
+	}
+
+	elements, err := arrayArg(array)
+	if err != nil {
+		return false, err
+	}
+
+	return util.Some(elements, compare)
+}
+
+// expands the rows selecting unnest into a row for each element. Several unnests are read side by side and the
+// shorter ones are padded with nulls, a row whose arrays are all empty is left out
+func (s *Executor) unnestFields(rows []input.DataRow) []input.DataRow {
+	var keys []string
+	for _, field := range s.sql.Fields {
+		if isUnnest(field.Expr) {
+			keys = append(keys, outputKey(field))
+		}
+	}
+
+	if len(keys) == 0 {
+		return rows
+	}
+
+	var expanded []input.DataRow
+
+	for _, row := range rows {
+		arrays := make([][]interface{}, len(keys))
+		length := 0
+
+		for i, key := range keys {
+			arrays[i], _ = arrayArg(row[key])
+			length = tern(len(arrays[i]) > length, len(arrays[i]), length)
+		}
+
+		for i := 0; i < length; i++ {
+			element := make(input.DataRow, len(row))
+			for key, value := range row {
+				element[key] = value
+			}
+
+			for j, key := range keys {
+				element[key] = elementOrNil(arrays[j], i)
+			}
+
+			expanded = append(expanded, element)
+		}
+	}
+
+	return expanded
+}
+
+func elementOrNil(array []interface{}, i int) interface{} {
+	if i < len(array) {
+		return array[i]
+	}
+
+	return nil
+}
+
+// the rows unnest(array) as alias joins to a row, each with the element as alias. The keys of elements that are
+// objects are columns too, so unnest(events) as e can read e.name
+func (s *Executor) unnestRows(table Table, row input.DataRow) ([]input.DataRow, error) {
+	value, err := s.eval(newScope(row), table.Unnest)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	elements, err := arrayArg(value)
+	if err != nil {
+		return nil, err
+	}
+
+	column := table.qualifier()
+
+	rows := make([]input.DataRow, len(elements))
+	for i, element := range elements {
+		rows[i] = input.DataRow{column: element}
+
+		if object, ok := element.(map[string]interface{}); ok {
+			for key, value := range object {
+				if key != column {
+					rows[i][key] = value
+				}
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// array_agg collects the values of the group in order, nulls included
+type arrayAggregator struct {
+	values []interface{}
+}
+
+func (a *arrayAggregator) Accumulate(args []interface{}) error {
+	a.values = append(a.values, args[0])
+
+	return nil
+}
+
+func (a *arrayAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*arrayAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	a.values = append(a.values, partial.values...)
+
+	return nil
+}
+
+func (a *arrayAggregator) Finalize() (interface{}, error) {
+	if len(a.values) == 0 {
+		return nil, nil
+	}
+
+	return slices.Clone(a.values), nil
+}
@@ -0,0 +1,379 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Builder builds a query in go, Select("a", "b").From("logs").Where(Col("x").Gt(5)).OrderBy(Col("a").Desc()). The
+// query it builds is the same one the parser reads from the sql the query prints as
+type Builder struct {
+	query      Query
+	conditions []Condition
+	// the table As aliases, the last one added by From or Join
+	table *Table
+	err   error
+}
+
+// Condition is a where or join condition, a comparison like Col("x").Gt(5) or conditions combined with And and Or,
+// or with All and Any
+type Condition struct {
+	tree Tree
+	err  error
+}
+
+// Select starts a query selecting the fields, a string is a column and an expression is selected as its sql unless
+// it is given a name with As
+func Select(fields ...interface{}) *Builder {
+	b := &Builder{}
+
+	if len(fields) == 0 {
+		b.fail(errors.New("a query has to select at least one field"))
+	}
+
+	for _, field := range fields {
+		switch casted := field.(type) {
+		case string:
+			b.query.Fields = append(b.query.Fields, NewColumn(casted).As(casted))
+		case *Expr:
+			b.query.Fields = append(b.query.Fields, casted.As(tern(casted.Column != "", casted.Column, casted.String())))
+		case Field:
+			b.query.Fields = append(b.query.Fields, casted)
+		default:
+			b.fail(errors.New(fmt.Sprintf("can't select %v, expected a column name, an expression or a field", field)))
+		}
+	}
+
+	return b
+}
+
+// From reads the table, a name with a dot or a slash in it is a file like the parser reads from 'logs.json'
+func (b *Builder) From(name string) *Builder {
+	b.query.From = newTable(name)
+	b.table = b.query.From
+
+	return b
+}
+
+// Join joins the table on the conditions, a cross join has none
+func (b *Builder) Join(kind JoinKind, name string, on ...Condition) *Builder {
+	join := Join{Kind: kind, Table: *newTable(name)}
+
+	if len(on) > 0 {
+		join.On = b.group(All(on...))
+	}
+
+	b.query.Joins = append(b.query.Joins, join)
+	b.table = &b.query.Joins[len(b.query.Joins)-1].Table
+
+	return b
+}
+
+// As aliases the table added last by From or Join
+func (b *Builder) As(alias string) *Builder {
+	if b.table == nil {
+		b.fail(errors.New(fmt.Sprintf("can't alias a query without a table as %s", alias)))
+		return b
+	}
+
+	b.table.Alias = alias
+
+	return b
+}
+
+// Where filters the rows by the conditions, every call adds more conditions that all have to hold
+func (b *Builder) Where(conditions ...Condition) *Builder {
+	b.conditions = append(b.conditions, conditions...)
+
+	return b
+}
+
+// GroupBy groups the rows by the columns or expressions
+func (b *Builder) GroupBy(exprs ...interface{}) *Builder {
+	for _, expr := range exprs {
+		switch casted := expr.(type) {
+		case string:
+			b.query.GroupBy = append(b.query.GroupBy, *NewColumn(casted))
+		case *Expr:
+			b.query.GroupBy = append(b.query.GroupBy, *casted)
+		default:
+			b.fail(errors.New(fmt.Sprintf("can't group by %v, expected a column name or an expression", expr)))
+		}
+	}
+
+	return b
+}
+
+// OrderBy sorts the rows by the columns or expressions, in ascending order unless they are ordered with Desc
+func (b *Builder) OrderBy(orders ...interface{}) *Builder {
+	for _, order := range orders {
+		switch casted := order.(type) {
+		case string:
+			b.query.OrderBy = append(b.query.OrderBy, NewColumn(casted).Asc())
+		case *Expr:
+			b.query.OrderBy = append(b.query.OrderBy, casted.Asc())
+		case OrderBy:
+			b.query.OrderBy = append(b.query.OrderBy, casted)
+		default:
+			b.fail(errors.New(fmt.Sprintf("can't order by %v, expected a column name, an expression or an order", order)))
+		}
+	}
+
+	return b
+}
+
+// Build returns the query, or the first mistake made building it
+func (b *Builder) Build() (*Query, error) {
+	query := b.query
+	if len(b.conditions) > 0 {
+		query.Group = b.group(All(b.conditions...))
+	}
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	// a literal of a type a query can't hold fails here rather than when the query runs
+	walkQuery(&query, nil, func(expr *Expr) {
+		if expr.Literal == nil {
+			return
+		}
+
+		value, err := literalValue(expr.Literal.Value)
+		if err != nil {
+			b.fail(err)
+		}
+
+		expr.Literal.Value = value
+	})
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if err := checkQuery(&query); err != nil {
+		return nil, err
+	}
+
+	return &query, nil
+}
+
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// the group a where or an on is, a comparison on its own is a group of one like the parser reads it
+func (b *Builder) group(condition Condition) *PredicateGroup {
+	if condition.err != nil {
+		b.fail(condition.err)
+	}
+
+	if condition.tree.Group != nil {
+		return condition.tree.Group
+	}
+
+	return &PredicateGroup{Operator: And, Predicate: []Tree{condition.tree}}
+}
+
+func newTable(name string) *Table {
+	if strings.ContainsAny(name, "./") {
+		return &Table{File: name}
+	}
+
+	return &Table{Name: name}
+}
+
+// Col is the column, qualified like u.id when it is read from a joined table
+func Col(name string) *Expr {
+	return NewColumn(name)
+}
+
+// Lit is the value as a literal, ints are bigints, floats doubles, a time.Time a timestamp and a time.Duration an
+// interval. Comparisons take values as they are, a literal is only needed to pass a value to Fn
+func Lit(value interface{}) *Expr {
+	return NewLiteral(value)
+}
+
+// Fn calls the function with the arguments, an argument that isn't an expression is a literal
+func Fn(name string, args ...interface{}) *Expr {
+	call := &Call{Name: name}
+	for _, arg := range args {
+		call.Args = append(call.Args, *operand(arg))
+	}
+
+	return &Expr{Call: call}
+}
+
+// an expression as it is and anything else as a literal
+func operand(value interface{}) *Expr {
+	switch casted := value.(type) {
+	case *Expr:
+		return casted
+	case Expr:
+		return &casted
+	}
+
+	return NewLiteral(value)
+}
+
+// the value a literal holds, the types a bound value can have except objects
+func literalValue(value interface{}) (interface{}, error) {
+	if _, ok := value.(map[string]interface{}); ok {
+		return nil, errors.New("objects can't be literals")
+	}
+
+	return paramValue(value)
+}
+
+// As selects the expression as alias
+func (e *Expr) As(alias string) Field {
+	if e.Column != "" {
+		return Field{Name: e.Column, Alias: KeyAlias(alias)}
+	}
+
+	return Field{Alias: KeyAlias(alias), Expr: e}
+}
+
+// Asc orders by the expression from the lowest value up
+func (e *Expr) Asc() OrderBy {
+	return OrderBy{Expr: *e}
+}
+
+// Desc orders by the expression from the highest value down
+func (e *Expr) Desc() OrderBy {
+	return OrderBy{Expr: *e, Desc: true}
+}
+
+func (e *Expr) Eq(value interface{}) Condition {
+	return e.compare(Eq, value)
+}
+
+func (e *Expr) Neq(value interface{}) Condition {
+	return e.compare(Neq, value)
+}
+
+func (e *Expr) Gt(value interface{}) Condition {
+	return e.compare(Gt, value)
+}
+
+func (e *Expr) Lt(value interface{}) Condition {
+	return e.compare(Lt, value)
+}
+
+func (e *Expr) Gte(value interface{}) Condition {
+	return e.compare(Gte, value)
+}
+
+func (e *Expr) Lte(value interface{}) Condition {
+	return e.compare(Lte, value)
+}
+
+// In holds when the expression equals one of the values, given one by one or as a slice. The values can be
+// placeholders, and a subquery or a placeholder bound to an array is passed as the only value
+func (e *Expr) In(values ...interface{}) Condition {
+	leaf := e.leaf(In)
+
+	if len(values) == 1 {
+		if expr, ok := values[0].(*Expr); ok && expr.Literal == nil {
+			leaf.Right = expr
+			return Condition{tree: NewLeaf(leaf)}
+		}
+
+		if array, err := arrayArg(values[0]); err == nil {
+			values = array
+		}
+	}
+
+	if len(values) == 0 {
+		return Condition{err: errors.New(fmt.Sprintf("%s in needs at least one value", e))}
+	}
+
+	list := make([]interface{}, len(values))
+	for i, value := range values {
+		expr := operand(value)
+		if expr.Param != nil {
+			list[i] = *expr.Param
+			continue
+		}
+
+		if expr.Literal == nil {
+			return Condition{err: errors.New(fmt.Sprintf("in lists can only hold literals and placeholders, found %s", expr))}
+		}
+
+		var err error
+		if list[i], err = literalValue(expr.Literal.Value); err != nil {
+			return Condition{err: err}
+		}
+	}
+
+	leaf.Value = list
+
+	return Condition{tree: NewLeaf(leaf)}
+}
+
+// a literal is compared as the leaf's value and any other expression, like another column, as its right hand side
+func (e *Expr) compare(operator ComparisonOperator, value interface{}) Condition {
+	leaf := e.leaf(operator)
+
+	right := operand(value)
+	if right.Literal == nil {
+		leaf.Right = right
+		return Condition{tree: NewLeaf(leaf)}
+	}
+
+	var err error
+	if leaf.Value, err = literalValue(right.Literal.Value); err != nil {
+		return Condition{err: err}
+	}
+
+	return Condition{tree: NewLeaf(leaf)}
+}
+
+func (e *Expr) leaf(operator ComparisonOperator) Leaf {
+	if e.Column != "" {
+		return Leaf{Field: e.Column, Compare: operator}
+	}
+
+	return Leaf{Compare: operator, Left: e}
+}
+
+// All holds when every condition holds, the conditions are joined by and
+func All(conditions ...Condition) Condition {
+	return combine(And, conditions)
+}
+
+// Any holds when any of the conditions hold, the conditions are joined by or
+func Any(conditions ...Condition) Condition {
+	return combine(Or, conditions)
+}
+
+func (c Condition) And(conditions ...Condition) Condition {
+	return All(append([]Condition{c}, conditions...)...)
+}
+
+func (c Condition) Or(conditions ...Condition) Condition {
+	return Any(append([]Condition{c}, conditions...)...)
+}
+
+// a and b and c nests to the right as a and (b and c), the way the parser reads it
+func combine(operator GroupingOperator, conditions []Condition) Condition {
+	for _, condition := range conditions {
+		if condition.err != nil {
+			return condition
+		}
+	}
+
+	switch len(conditions) {
+	case 0:
+		return Condition{err: errors.New(fmt.Sprintf("%s needs at least one condition", operator))}
+	case 1:
+		return conditions[0]
+	}
+
+	rest := combine(operator, conditions[1:])
+
+	return Condition{tree: Tree{Group: &PredicateGroup{Operator: operator, Predicate: []Tree{conditions[0].tree, rest.tree}}}}
+}
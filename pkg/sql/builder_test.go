@@ -0,0 +1,124 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildsParsedQueries(t *testing.T) {
+	for _, test := range []struct {
+		builder *Builder
+		sql     string
+	}{
+		{Select("*"), "select *"},
+		{
+			Select("host", Fn("count", Col("*")).As("n"), Fn("max", Col("ms"))).From("logs").
+				Where(Col("status").Gte(500)).GroupBy("host").OrderBy(Col("n").Desc(), "host"),
+			"select host, count(*) as n, max(ms) from logs where status >= 500 group by host order by n desc, host",
+		},
+		{
+			Select("a").Where(Col("x").Gt(5).Or(Col("y").Eq("a").And(Col("z").In(1, 2.5, "y")))),
+			"select a where x > 5 or y = 'a' and z in (1, 2.5, 'y')",
+		},
+		{
+			Select("a").Where(Col("x").Gt(5), Col("y").Eq(int32(1)), Col("z").Neq(true)),
+			"select a where x > 5 and y = 1 and z != true",
+		},
+		{
+			Select("a").Where(Any(Col("x").Lt(5), Col("y").Lte(1.5)).And(Col("z").In([]string{"a", "b"}))),
+			"select a where (x < 5 or y <= 1.5) and z in ('a', 'b')",
+		},
+		{
+			Select("a").Where(All(Col("x").Eq(1), Col("y").Eq(2)), Col("z").Eq(nil)),
+			"select a where (x = 1 and y = 2) and z = null",
+		},
+		{
+			Select(Col("b").As("c"), Fn("lower", Col("name"))).Where(Fn("lower", Col("name")).Eq("bob"), Col("a").Eq(Col("b"))),
+			"select b as c, lower(name) where lower(name) = 'bob' and a = `b`",
+		},
+		{
+			Select("l.id", "u.name").From("logs").As("l").Join(LeftJoin, "users", Col("l.user_id").Eq(Col("u.id")), Col("u.active").Eq(true)).As("u").
+				Join(CrossJoin, "test/sample.dat"),
+			"select l.id, u.name from logs l left join users u on l.user_id = u.id and u.active = true cross join 'test/sample.dat'",
+		},
+		{
+			Select("id").Where(Col("ts").Gt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), Col("ms").Gt(Fn("epoch", Lit(time.Hour)))),
+			"select id where ts > timestamp '2024-01-01T00:00:00Z' and ms > epoch(interval '1 hour')",
+		},
+		{
+			Select("id").Where(Col("user").Eq(&Expr{Param: &Param{Name: "user"}}), Col("id").In(&Expr{Param: &Param{Position: 1}})),
+			"select id where user = $user and id in ?",
+		},
+		{
+			Select("id").Where(Col("id").In(&Expr{Param: &Param{Position: 1}}, 2, &Expr{Param: &Param{Name: "id"}})),
+			"select id where id in (?, 2, $id)",
+		},
+		{
+			Select(Fn("date_trunc", "hour", Col("ts")).As("hour")).GroupBy(Fn("date_trunc", "hour", Col("ts"))).OrderBy("hour"),
+			"select date_trunc('hour', ts) as hour group by date_trunc('hour', ts) order by hour",
+		},
+	} {
+		built, err := test.builder.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", test.sql, err)
+		}
+
+		parsed, err := Parse(test.sql)
+		if err != nil {
+			t.Fatalf("%s: %s", test.sql, err)
+		}
+
+		if !reflect.DeepEqual(built, parsed) {
+			t.Errorf("%s: expected %s got %s", test.sql, toJson(parsed, t), toJson(built, t))
+		}
+
+		if built.String() != parsed.String() {
+			t.Errorf("expected %s got %s", parsed, built)
+		}
+	}
+}
+
+func TestQueriesBuiltQueries(t *testing.T) {
+	query, err := Select("host", Fn("count", Col("*")).As("n")).From("logs").
+		Where(Col("status").Eq(500).Or(Col("ms").Gt(750))).GroupBy("host").OrderBy(Col("n").Desc(), "host").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query, WithTable("logs", hostLogs)).QueryData(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []input.DataRow{{"host": "web2", "n": int64(2)}, {"host": "web1", "n": int64(1)}, {"host": "web3", "n": int64(1)}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v got %v", expected, result)
+	}
+}
+
+func TestInvalidBuilds(t *testing.T) {
+	for _, test := range []struct {
+		builder *Builder
+		error   string
+	}{
+		{Select(), "at least one field"},
+		{Select(1), "can't select 1"},
+		{Select("a").As("b"), "without a table"},
+		{Select("a").Where(Col("x").Eq(struct{}{})), "unsupported type"},
+		{Select("a").Where(Col("x").Eq(map[string]interface{}{"a": 1})), "objects can't be literals"},
+		{Select("a").Where(Col("x").In()), "at least one value"},
+		{Select("a").Where(Col("x").In(1, Col("y"))), "only hold literals"},
+		{Select("a").Where(Col("x").Eq(1).And(Any())), "or needs at least one condition"},
+		{Select(Fn("lower", make(chan int))), "unsupported type"},
+		{Select("a").From("logs").Join(InnerJoin, "users"), "needs either On or Using"},
+		{Select("a").GroupBy(1), "can't group by 1"},
+		{Select("a").OrderBy(true), "can't order by true"},
+	} {
+		if _, err := test.builder.Build(); err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("expected %s but got %v", test.error, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"strings"
+)
+
+// case when x > 1 then 'a' else 'b' end, or case x when 1 then 'a' else 'b' end which compares x with each value
+type Case struct {
+	// the value a simple case compares, nil for a searched case
+	Operand *Expr `json:",omitempty"`
+	Whens   []When
+	// null when missing
+	Else *Expr `json:",omitempty"`
+}
+
+type When struct {
+	// the condition of a searched case
+	Condition *PredicateGroup `json:",omitempty"`
+	// the value a simple case compares its operand with
+	Value *Expr `json:",omitempty"`
+	Then  Expr
+}
+
+func (c *Case) String() string {
+	parts := []string{"case"}
+
+	if c.Operand != nil {
+		parts = append(parts, c.Operand.String())
+	}
+
+	for _, when := range c.Whens {
+		parts = append(parts, "when")
+
+		switch {
+		case when.Condition != nil:
+			parts = append(parts, when.Condition.String())
+		case when.Value != nil:
+			parts = append(parts, when.Value.String())
+		}
+
+		parts = append(parts, "then", when.Then.String())
+	}
+
+	if c.Else != nil {
+		parts = append(parts, "else", c.Else.String())
+	}
+
+	return strings.Join(append(parts, "end"), " ")
+}
+
+// every expression inside the case, including both sides of its conditions
+func (c *Case) exprs() []*Expr {
+	exprs := []*Expr{c.Operand}
+
+	for i := range c.Whens {
+		when := &c.Whens[i]
+
+		exprs = append(exprs, when.Condition.exprs()...)
+		exprs = append(exprs, when.Value, &when.Then)
+	}
+
+	return append(exprs, c.Else)
+}
+
+// the expressions on either side of the leaves of the group, the array of any(array) in place of the any
+func (g *PredicateGroup) exprs() []*Expr {
+	if g == nil {
+		return nil
+	}
+
+	side := func(expr *Expr) *Expr {
+		return tern(anyArg(expr) != nil, anyArg(expr), expr)
+	}
+
+	var exprs []*Expr
+	for _, predicate := range g.Predicate {
+		if leaf := predicate.Leaf; leaf != nil {
+			exprs = append(exprs, side(leaf.Left), side(leaf.Right))
+		}
+
+		exprs = append(exprs, predicate.Group.exprs()...)
+	}
+
+	return exprs
+}
+
+// the result of the first when that matches, or the else
+func (s *Executor) evalCase(sc *scope, c *Case) (interface{}, error) {
+	var operand interface{}
+	if c.Operand != nil {
+		var err error
+		if operand, err = s.eval(sc, c.Operand); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range c.Whens {
+		when := &c.Whens[i]
+
+		var matched bool
+		var err error
+
+		switch {
+		case when.Condition != nil:
+			matched, err = s.inPredicateGroup(sc, when.Condition)
+		case operand != nil:
+			// a null operand never equals anything, not even null
+			var value interface{}
+			if value, err = s.eval(sc, when.Value); err == nil {
+				matched, err = s.compare(Eq, operand, value)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			return s.eval(sc, &when.Then)
+		}
+	}
+
+	if c.Else == nil {
+		return nil, nil
+	}
+
+	return s.eval(sc, c.Else)
+}
@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"reflect"
+	"testing"
+)
+
+func TestParsesCase(t *testing.T) {
+	for _, sql := range []string{
+		"case when status >= 500 then 'error' when status >= 400 then 'warn' else 'ok' end",
+		"case status when 200 then 'ok' when 404 then 'missing' end",
+		"case when a = 1 or b = 2 then 1 else 0 end",
+		"case when active then 1 else 0 end + 1",
+		"case when x > 1 then case when y > 1 then 'both' else 'x' end end",
+	} {
+		result, err := Parse("select " + sql + " as c")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Fields[0].Expr.String() != sql {
+			t.Errorf("expected %s got %s", sql, result.Fields[0].Expr.String())
+		}
+	}
+
+	for _, sql := range []string{
+		"select case end as c",
+		"select case when then 1 end as c",
+		"select case when x = 1 then 1 as c",
+		"select case when x = 1 1 end as c",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
+
+func TestEvaluatesCase(t *testing.T) {
+	for _, test := range []struct {
+		expression string
+		row        input.DataRow
+		expected   interface{}
+	}{
+		{"case when status >= 500 then 'error' when status >= 400 then 'warn' else 'ok' end", input.DataRow{"status": 503}, "error"},
+		{"case when status >= 500 then 'error' when status >= 400 then 'warn' else 'ok' end", input.DataRow{"status": 404}, "warn"},
+		{"case when status >= 500 then 'error' when status >= 400 then 'warn' else 'ok' end", input.DataRow{"status": 200}, "ok"},
+		{"case when status >= 500 then 'error' end", input.DataRow{"status": 200}, nil},
+		{"case when status = 1 or status = 2 then 'low' end", input.DataRow{"status": 2}, "low"},
+		{"case when active then 'yes' else 'no' end", input.DataRow{"active": true}, "yes"},
+		{"case when active then 'yes' else 'no' end", input.DataRow{"active": nil}, "no"},
+		{"case status when 200 then 'ok' when 404 then 'missing' end", input.DataRow{"status": 404}, "missing"},
+		{"case status when 200 then 'ok' else 'other' end", input.DataRow{"status": nil}, "other"},
+		{"case status when null then 'null' else 'other' end", input.DataRow{"status": nil}, "other"},
+		{"case lower(name) when 'bob' then 1 else 0 end * 10", input.DataRow{"name": "BOB"}, int64(10)},
+		{"case when x > 1 then case when y > 1 then 'both' else 'x' end end", input.DataRow{"x": 2, "y": 0}, "x"},
+	} {
+		result, err := evalExpression(test.expression, test.row)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.expression, test.expected, result)
+		}
+	}
+}
+
+func TestQueriesCase(t *testing.T) {
+	rows := []input.DataRow{
+		{"route": "/a", "status": 200},
+		{"route": "/a", "status": 500},
+		{"route": "/b", "status": 404},
+		{"route": "/b", "status": 503},
+		{"route": "/b", "status": 502},
+	}
+
+	for _, test := range []struct {
+		sql      string
+		expected []input.DataRow
+	}{
+		{
+			"select route, sum(case when status >= 500 then 1 else 0 end) as errors group by route",
+			[]input.DataRow{{"route": "/a", "errors": int64(1)}, {"route": "/b", "errors": int64(2)}},
+		},
+		{
+			"select case when status >= 500 then 'error' else 'ok' end as kind, count(*) as n group by kind",
+			[]input.DataRow{{"kind": "ok", "n": int64(2)}, {"kind": "error", "n": int64(3)}},
+		},
+		{
+			"select route, case when count(*) > 2 then 'busy' else 'quiet' end as load group by route",
+			[]input.DataRow{{"route": "/a", "load": "quiet"}, {"route": "/b", "load": "busy"}},
+		},
+		{
+			"select status where status = case route when '/a' then 500 else 404 end",
+			[]input.DataRow{{"status": 500}, {"status": 404}},
+		},
+		{
+			"select status where case when route = '/b' then 'b' end = 'b' and status > 500",
+			[]input.DataRow{{"status": 503}, {"status": 502}},
+		},
+	} {
+		query, err := Parse(test.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := NewExecutor(*query).QueryData(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.sql, test.expected, result)
+		}
+	}
+}
+
+func TestInvalidCase(t *testing.T) {
+	for _, sql := range []string{
+		"select x where case when count(*) > 1 then true end",
+		"select case when potato(x) then 1 end as y",
+		"select case x when 1 then sum(sum(x)) end as y",
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query).QueryData([]input.DataRow{{"x": 1}}); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// recursive ctes stop with an error rather than run forever when every run finds new rows
+const maxRecursion = 10000
+
+// with name (columns) as (query), the rows of the query can be read as name by the queries after it
+type CommonTable struct {
+	Name string
+	// renames the columns the query selects, in order
+	Columns []string `json:",omitempty"`
+	Query   Query
+	// the recursive term of a with recursive, run over the rows the last run found until it finds no new rows
+	Recursive *Query `json:",omitempty"`
+	// union all keeps rows the table already has, union leaves them out
+	All bool `json:",omitempty"`
+}
+
+func (c CommonTable) String() string {
+	name := quoteIdentifier(c.Name)
+	if len(c.Columns) > 0 {
+		name += " (" + quoteIdentifiers(c.Columns) + ")"
+	}
+
+	body := c.Query.String()
+	if c.Recursive != nil {
+		body += tern(c.All, " union all ", " union ") + c.Recursive.String()
+	}
+
+	return fmt.Sprintf("%s as (%s)", name, body)
+}
+
+// the with clause at the start of a query
+func withString(with []CommonTable) string {
+	recursive := slices.ContainsFunc(with, func(c CommonTable) bool {
+		return c.Recursive != nil
+	})
+
+	var tables []string
+	for _, table := range with {
+		tables = append(tables, table.String())
+	}
+
+	return tern(recursive, "with recursive ", "with ") + strings.Join(tables, ", ")
+}
+
+// runs the common tables of the query in order, each one is run once and then read as often as it is used
+func (s *Executor) commonTables() error {
+	if len(s.sql.With) == 0 {
+		return nil
+	}
+
+	// the tables of an enclosing query are readable too
+	ctes := map[string][]input.DataRow{}
+	maps.Copy(ctes, s.ctes)
+	s.ctes = ctes
+
+	for _, cte := range s.sql.With {
+		rows, err := s.commonTable(cte)
+		if err != nil {
+			return err
+		}
+
+		ctes[cte.Name] = rows
+	}
+
+	return nil
+}
+
+func (s *Executor) commonTable(cte CommonTable) ([]input.DataRow, error) {
+	columns := cte.Columns
+	if len(columns) == 0 {
+		columns = outputKeys(cte.Query)
+	}
+
+	rows, err := s.commonTableRows(cte.Query, columns)
+	if err != nil || cte.Recursive == nil {
+		return rows, err
+	}
+
+	seen := map[string]bool{}
+	if !cte.All {
+		rows = distinctRows(rows, seen)
+	}
+
+	// the recursive term reads the rows found by the run before it as the table
+	found := rows
+	ctes := s.ctes
+
+	for i := 0; len(found) > 0; i++ {
+		if i == maxRecursion {
+			return nil, errors.New(fmt.Sprintf("recursive cte %s found new rows %d times, it may never finish", cte.Name, maxRecursion))
+		}
+
+		s.ctes = maps.Clone(ctes)
+		s.ctes[cte.Name] = found
+
+		next, err := s.commonTableRows(*cte.Recursive, columns)
+		if err != nil {
+			return nil, err
+		}
+
+		if !cte.All {
+			next = distinctRows(next, seen)
+		}
+
+		rows = append(rows, next...)
+		found = next
+	}
+
+	s.ctes = ctes
+
+	return rows, nil
+}
+
+// runs the query of a common table and names its columns
+func (s *Executor) commonTableRows(query Query, columns []string) ([]input.DataRow, error) {
+	rows, err := s.subExecutor(query, nil).run([]input.DataRow{{}})
+	if err != nil {
+		return nil, err
+	}
+
+	return renameColumns(rows, outputKeys(query), columns), nil
+}
+
+// true when the query, or a query inside it, reads the table name
+func readsTable(query *Query, name string) bool {
+	reads := false
+
+	walkQuery(query, func(q *Query) {
+		for _, table := range append([]*Table{q.From}, q.joinTables()...) {
+			if table != nil && table.Name == name {
+				reads = true
+			}
+		}
+	}, nil)
+
+	return reads
+}
+
+// the keys of the rows a query selects in the order they are selected, nil when it selects *
+func outputKeys(query Query) []string {
+	var keys []string
+
+	for _, field := range query.Fields {
+		if field.Name == "*" {
+			return nil
+		}
+
+		keys = append(keys, outputKey(field))
+	}
+
+	return keys
+}
+
+// the key a field is selected as
+func outputKey(field Field) string {
+	return string(tern(field.Alias != "", field.Alias, KeyAlias(field.Name)))
+}
+
+// the rows that haven't been seen before, marking them as seen
+func distinctRows(rows []input.DataRow, seen map[string]bool) []input.DataRow {
+	var distinct []input.DataRow
+
+	for _, row := range rows {
+		id := rowId(row)
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, row)
+		}
+	}
+
+	return distinct
+}
+
+// identifies a row by its columns and their values, rows with the same id are the same row
+func rowId(row input.DataRow) string {
+	keys := make([]string, 0, len(row))
+	for key := range row {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var parts []interface{}
+	for _, key := range keys {
+		parts = append(parts, key, normalize(row[key]))
+	}
+
+	return groupId(parts)
+}
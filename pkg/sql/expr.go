@@ -0,0 +1,369 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expr is a scalar expression, only one of its members is set
+type Expr struct {
+	Column  string   `json:",omitempty"`
+	Literal *Literal `json:",omitempty"`
+	Cast    *Cast    `json:",omitempty"`
+	Call    *Call    `json:",omitempty"`
+	Binary  *Binary  `json:",omitempty"`
+	Case    *Case    `json:",omitempty"`
+	// (select ...) is the value of the single column of its single row, or the values of every row on the right of in
+	Subquery *Query `json:",omitempty"`
+	// exists (select ...) is true when the query returns any rows
+	Exists *Query `json:",omitempty"`
+	// a placeholder bound by Statement.Executor
+	Param *Param `json:",omitempty"`
+}
+
+type Literal struct {
+	Value interface{}
+}
+
+// cast(x as type) fails the query when x can't be converted, try_cast(x as type) returns null instead
+type Cast struct {
+	Expr      Expr
+	Type      Type
+	Precision *int `json:",omitempty"`
+	Scale     *int `json:",omitempty"`
+	Try       bool `json:",omitempty"`
+}
+
+type Call struct {
+	Name string
+	Args []Expr
+	// the order of an ordered set aggregate like percentile_cont(0.5) within group (order by x)
+	WithinGroup []OrderBy `json:",omitempty"`
+	// makes the call a window function
+	Over *Window `json:",omitempty"`
+}
+
+type OrderBy struct {
+	Expr Expr
+	Desc bool `json:",omitempty"`
+}
+
+// arithmetic between two expressions, + - * / and %, or || to concatenate strings
+type Binary struct {
+	Operator string
+	Left     Expr
+	Right    Expr
+}
+
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"+":  1,
+	"-":  1,
+	"*":  2,
+	"/":  2,
+	"%":  2,
+}
+
+func NewColumn(name string) *Expr {
+	return &Expr{Column: name}
+}
+
+func NewLiteral(value interface{}) *Expr {
+	return &Expr{Literal: &Literal{Value: value}}
+}
+
+func (e Expr) String() string {
+	switch {
+	case e.Literal != nil:
+		return literalString(e.Literal.Value)
+	case e.Cast != nil:
+		name := tern(e.Cast.Try, "try_cast", "cast")
+		target := string(e.Cast.Type)
+		if e.Cast.Precision != nil && e.Cast.Scale != nil {
+			target = fmt.Sprintf("%s(%d, %d)", target, *e.Cast.Precision, *e.Cast.Scale)
+		}
+
+		return fmt.Sprintf("%s(%s as %s)", name, e.Cast.Expr.String(), target)
+	case e.Call != nil:
+		if strings.ToLower(e.Call.Name) == "date_part" && len(e.Call.Args) == 2 && e.Call.Args[0].Literal != nil {
+			return fmt.Sprintf("extract(%v from %s)", e.Call.Args[0].Literal.Value, e.Call.Args[1].String())
+		}
+
+		var args []string
+		for _, arg := range e.Call.Args {
+			args = append(args, arg.String())
+		}
+
+		call := fmt.Sprintf("%s(%s)", e.Call.Name, strings.Join(args, ", "))
+
+		if len(e.Call.WithinGroup) > 0 {
+			call += " within group (order by " + orderByString(e.Call.WithinGroup) + ")"
+		}
+
+		if e.Call.Over != nil {
+			call += " over " + e.Call.Over.String()
+		}
+
+		return call
+	case e.Case != nil:
+		return e.Case.String()
+	case e.Subquery != nil:
+		return "(" + e.Subquery.String() + ")"
+	case e.Exists != nil:
+		return "exists (" + e.Exists.String() + ")"
+	case e.Param != nil:
+		return e.Param.String()
+	case e.Binary != nil:
+		return fmt.Sprintf("%s %s %s", operandString(e.Binary.Left, e.Binary.Operator, false), e.Binary.Operator, operandString(e.Binary.Right, e.Binary.Operator, true))
+	}
+
+	return quoteIdentifier(e.Column)
+}
+
+// an ordered set aggregate with the value it orders as its first argument, so
+// percentile_cont(0.5) within group (order by x) is percentile_cont(x, 0.5)
+func (c *Call) orderedSetCall() *Call {
+	if len(c.WithinGroup) == 0 {
+		return c
+	}
+
+	return &Call{Name: c.Name, Args: append([]Expr{c.WithinGroup[0].Expr}, c.Args...)}
+}
+
+func orderByString(orderBy []OrderBy) string {
+	var parts []string
+	for _, order := range orderBy {
+		parts = append(parts, order.Expr.String()+tern(order.Desc, " desc", ""))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// brackets an operand when it binds looser than the operator it is under
+func operandString(operand Expr, operator string, right bool) string {
+	if operand.Binary == nil {
+		return operand.String()
+	}
+
+	inner := binaryPrecedence[operand.Binary.Operator]
+	outer := binaryPrecedence[operator]
+
+	if inner < outer || (right && inner == outer) {
+		return "(" + operand.String() + ")"
+	}
+
+	return operand.String()
+}
+
+func literalString(value interface{}) string {
+	switch casted := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quoteString(casted)
+	case DecimalValue:
+		return casted.String()
+	case time.Time:
+		return "timestamp " + quoteString(casted.Format(time.RFC3339Nano))
+	case IntervalValue:
+		return "interval " + quoteString(casted.String())
+	case Param:
+		return casted.String()
+	case float64:
+		if math.IsNaN(casted) || math.IsInf(casted, 0) {
+			break
+		}
+
+		// a whole double keeps its point so it reads back as a double rather than a bigint
+		double := strconv.FormatFloat(casted, 'g', -1, 64)
+		if !strings.ContainsAny(double, ".e") {
+			double += ".0"
+		}
+
+		return double
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return strings.Trim(string(encoded), `"`)
+}
+
+// scope is what an expression is evaluated against
+type scope struct {
+	row input.DataRow
+	// computed aliases being evaluated, an alias that refers back to itself reads the row instead
+	resolving map[KeyAlias]bool
+	// the rows aggregates run over and the group by values keyed by their expression
+	group     []input.DataRow
+	groupKeys map[string]interface{}
+	// the values of the window calls for the row, keyed by the call
+	windows map[string]interface{}
+}
+
+func newScope(row input.DataRow) *scope {
+	return &scope{row: row, resolving: map[KeyAlias]bool{}}
+}
+
+// backslashes and line breaks are escaped so the string lexes back to the same value and stays on one line
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''", "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value) + "'"
+}
+
+// words that can't be read back as a name without quoting
+var reserved = map[string]bool{
+	sel: true, from: true, join: true, where: true, groupBy: true, "by": true, "as": true, "on": true, "using": true,
+	"and": true, "or": true, "in": true, "not": true, "null": true, "true": true, "false": true, "case": true,
+	"when": true, "then": true, "else": true, "end": true, "over": true, "within": true, "asc": true, "desc": true,
+	"all": true, "exists": true, "lateral": true, with: true, union: true, inter: true, except: true,
+	string(InnerJoin): true, LeftJoin: true, RightJoin: true, FullJoin: true, CrossJoin: true, "outer": true,
+	"recursive": true, "partition": true, "order": true, "rows": true, "range": true, "between": true, "unbounded": true,
+	"preceding": true, "following": true, "current": true, "row": true,
+}
+
+// a column or alias as it is written in a query, names with spaces or other symbols and names that are keywords
+// are wrapped in backticks
+func quoteIdentifier(name string) string {
+	// a bare name starting with a digit or a dot would read back as a number
+	first, _ := utf8.DecodeRuneInString(name)
+
+	bare := name == "" || !reserved[strings.ToLower(name)] && !strings.HasPrefix(name, "$") && !unicode.IsDigit(first) && first != '.' &&
+		strings.IndexFunc(name, func(char rune) bool {
+			return !(char == '_' || char == '.' || char == '*' || char == '$' || unicode.IsLetter(char) || unicode.IsDigit(char))
+		}) < 0
+
+	if bare {
+		return name
+	}
+
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdentifier(name)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func (s *Executor) eval(sc *scope, expr *Expr) (interface{}, error) {
+	if sc.groupKeys != nil {
+		if value, ok := sc.groupKeys[expr.String()]; ok {
+			return value, nil
+		}
+	}
+
+	switch {
+	case expr.Call != nil && expr.Call.Over != nil:
+		value, ok := sc.windows[expr.String()]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("window function %s can only be used in the selected fields", expr.Call.Name))
+		}
+
+		return value, nil
+	case expr.Call != nil && s.isAggregate(expr.Call.Name):
+		return s.aggregate(sc, expr.Call)
+	case expr.Call != nil:
+		return s.call(sc, expr.Call)
+	case expr.Binary != nil:
+		left, err := s.eval(sc, &expr.Binary.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := s.eval(sc, &expr.Binary.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		return arithmetic(expr.Binary.Operator, left, right)
+	case expr.Case != nil:
+		return s.evalCase(sc, expr.Case)
+	case expr.Subquery != nil:
+		return s.subqueryValue(sc, expr.Subquery)
+	case expr.Exists != nil:
+		return s.exists(sc, expr.Exists)
+	case expr.Literal != nil:
+		return normalize(expr.Literal.Value), nil
+	case expr.Param != nil:
+		return s.param(*expr.Param)
+	case expr.Cast != nil:
+		value, err := s.eval(sc, &expr.Cast.Expr)
+		if err != nil {
+			return nil, err
+		}
+
+		casted, err := CastValue(value, expr.Cast.Type)
+		if err != nil {
+			if expr.Cast.Try {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		if decimal, ok := casted.(DecimalValue); ok && expr.Cast.Scale != nil {
+			casted = decimal.withScale(*expr.Cast.Scale)
+		}
+
+		return casted, nil
+	}
+
+	return s.column(sc, expr.Column)
+}
+
+// evaluates a selected field, its own alias inside it reads the row
+func (s *Executor) evalField(sc *scope, field Field) (interface{}, error) {
+	sc.resolving[field.Alias] = true
+	defer delete(sc.resolving, field.Alias)
+
+	return s.eval(sc, field.Expr)
+}
+
+// resolves a column to the row, or to the computed field it is an alias of when the row has no such column, so a
+// where on a column a field is aliased as filters by the column
+func (s *Executor) column(sc *scope, name string) (interface{}, error) {
+	if value, ok := sc.row[name]; ok && sc.groupKeys == nil {
+		return normalize(value), nil
+	}
+
+	alias := KeyAlias(name)
+
+	for _, field := range s.sql.Fields {
+		if field.Expr != nil && field.Alias == alias && !sc.resolving[alias] {
+			sc.resolving[alias] = true
+			defer delete(sc.resolving, alias)
+
+			return s.eval(sc, field.Expr)
+		}
+	}
+
+	key := keyNameFromAlias(name, s.sql)
+
+	// a group has no single row to read, only the values it is grouped by
+	if sc.groupKeys != nil {
+		if value, ok := sc.groupKeys[NewColumn(key).String()]; ok {
+			return value, nil
+		}
+
+		return nil, errors.New(fmt.Sprintf("%s has to be in the group by or inside an aggregate", quoteIdentifier(name)))
+	}
+
+	if _, ok := sc.row[key]; !ok && s.outerColumn(name) {
+		return s.parent.column(s.outer, name)
+	}
+
+	return normalize(sc.row[key]), nil
+}
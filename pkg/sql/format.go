@@ -0,0 +1,281 @@
+package sql
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const indent = "  "
+
+// Format writes the query over several lines with its keywords in upper case, one clause to a line and the fields of
+// the select, derived tables, common tables and subqueries indented. It parses back to the same query
+func (q Query) Format() string {
+	return strings.Join(q.formatLines(), "\n")
+}
+
+func (q Query) formatLines() []string {
+	var lines []string
+
+	recursive := slices.ContainsFunc(q.With, func(c CommonTable) bool {
+		return c.Recursive != nil
+	})
+
+	for i, cte := range q.With {
+		name := quoteIdentifier(cte.Name)
+		if len(cte.Columns) > 0 {
+			name += " (" + quoteIdentifiers(cte.Columns) + ")"
+		}
+
+		if i == 0 {
+			name = tern(recursive, "WITH RECURSIVE ", "WITH ") + name
+		}
+
+		body := cte.Query.formatLines()
+		if cte.Recursive != nil {
+			body = append(append(body, tern(cte.All, "UNION ALL", "UNION")), cte.Recursive.formatLines()...)
+		}
+
+		lines = append(lines, name+" AS (")
+		lines = append(lines, indentLines(body)...)
+		lines = append(lines, tern(i == len(q.With)-1, ")", "),"))
+	}
+
+	if len(q.Fields) == 1 {
+		lines = append(lines, prefixLines("SELECT ", "", formatExpression(q.Fields[0].String()))...)
+	} else {
+		lines = append(lines, "SELECT")
+
+		for i, field := range q.Fields {
+			field := formatExpression(field.String())
+			field[len(field)-1] += tern(i < len(q.Fields)-1, ",", "")
+
+			lines = append(lines, prefixLines(indent, indent, field)...)
+		}
+	}
+
+	if q.From != nil {
+		lines = append(lines, formatTable("FROM ", *q.From)...)
+	}
+
+	for _, join := range q.Joins {
+		table := formatTable(strings.ToUpper(string(join.Kind))+" JOIN ", join.Table)
+
+		last := &table[len(table)-1]
+		switch {
+		case len(join.Using) > 0:
+			*last += " USING (" + quoteIdentifiers(join.Using) + ")"
+		case join.On != nil:
+			on := formatExpression(join.On.String())
+			*last += " ON " + on[0]
+			table = append(table, on[1:]...)
+		}
+
+		lines = append(lines, table...)
+	}
+
+	if q.Group != nil && len(q.Group.Predicate) > 0 {
+		operator := strings.ToUpper(string(tern(q.Group.Operator == "", And, q.Group.Operator)))
+
+		for i, predicate := range q.Group.Predicate {
+			var condition string
+			switch {
+			case predicate.Leaf != nil:
+				condition = predicate.Leaf.String()
+			case predicate.Group != nil:
+				condition = "(" + predicate.Group.String() + ")"
+			}
+
+			lines = append(lines, prefixLines(tern(i == 0, "WHERE ", indent+operator+" "), tern(i == 0, "", indent), formatExpression(condition))...)
+		}
+	}
+
+	if len(q.GroupBy) > 0 {
+		var exprs []string
+		for _, expr := range q.GroupBy {
+			exprs = append(exprs, upperKeywords(expr.String()))
+		}
+
+		lines = append(lines, "GROUP BY "+strings.Join(exprs, ", "))
+	}
+
+	for _, set := range q.Sets {
+		lines = append(lines, strings.ToUpper(string(set.Operator))+tern(set.All, " ALL", ""))
+		lines = append(lines, set.Query.formatLines()...)
+	}
+
+	if len(q.OrderBy) > 0 {
+		lines = append(lines, "ORDER BY "+upperKeywords(orderByString(q.OrderBy)))
+	}
+
+	return lines
+}
+
+// the lines of a table after from or a join, a derived table has its query indented inside its brackets
+func formatTable(clause string, table Table) []string {
+	if table.Query == nil {
+		return []string{clause + upperKeywords(table.String())}
+	}
+
+	end := ")"
+	if table.Alias != "" {
+		end += " " + quoteIdentifier(table.Alias)
+	}
+
+	lines := []string{clause + tern(table.Lateral, "LATERAL (", "(")}
+	lines = append(lines, indentLines(table.Query.formatLines())...)
+
+	return append(lines, end)
+}
+
+// the lines of sql written by String with its keywords in upper case, a subquery in it is indented inside its
+// brackets like a derived table
+func formatExpression(sql string) []string {
+	chars := []rune(sql)
+
+	lines := []string{""}
+	start := 0
+
+	for i := 0; i < len(chars); i++ {
+		switch {
+		case chars[i] == '\'' || chars[i] == '`':
+			i = quotedEnd(chars, i) - 1
+		case chars[i] == '(':
+			end := closingBracket(chars, i)
+			if end == len(chars) {
+				continue
+			}
+
+			inner := string(chars[i+1 : end])
+			if !strings.HasPrefix(inner, sel+" ") && !strings.HasPrefix(inner, with+" ") {
+				continue
+			}
+
+			query, err := Parse(inner)
+			if err != nil {
+				continue
+			}
+
+			lines[len(lines)-1] += upperKeywords(string(chars[start:i])) + "("
+			lines = append(lines, indentLines(query.formatLines())...)
+			lines = append(lines, ")")
+
+			i, start = end, end+1
+		}
+	}
+
+	lines[len(lines)-1] += upperKeywords(string(chars[start:]))
+
+	return lines
+}
+
+// the index of the bracket that closes the one at start, or the end when it isn't closed
+func closingBracket(chars []rune, start int) int {
+	depth := 0
+
+	for i := start; i < len(chars); i++ {
+		switch chars[i] {
+		case '\'', '`':
+			i = quotedEnd(chars, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return len(chars)
+}
+
+// the index right after the quote that closes the string or name starting at start
+func quotedEnd(chars []rune, start int) int {
+	quote := chars[start]
+
+	end := start + 1
+	for ; end < len(chars); end++ {
+		if quote == '\'' && chars[end] == '\\' {
+			end++
+			continue
+		}
+
+		if chars[end] == quote {
+			// a doubled quote is part of the string
+			if end+1 < len(chars) && chars[end+1] == quote {
+				end++
+				continue
+			}
+
+			break
+		}
+	}
+
+	return tern(end < len(chars), end+1, len(chars))
+}
+
+// puts first before the first line and rest before the others
+func prefixLines(first string, rest string, lines []string) []string {
+	prefixed := make([]string, len(lines))
+	for i, line := range lines {
+		prefixed[i] = tern(i == 0, first, rest) + line
+	}
+
+	return prefixed
+}
+
+func indentLines(lines []string) []string {
+	indented := make([]string, len(lines))
+	for i, line := range lines {
+		indented[i] = indent + line
+	}
+
+	return indented
+}
+
+// words that are only keywords right before a bracket or a string, like cast(...) and timestamp '...'
+var keywordCalls = map[string]rune{"cast": '(', "try_cast": '(', "extract": '(', "timestamp": '\'', "interval": '\''}
+
+// upper cases the keywords of sql written by String. Names that are keywords are always quoted, and quoted strings
+// and names are copied as they are
+func upperKeywords(sql string) string {
+	chars := []rune(sql)
+
+	var builder strings.Builder
+
+	for i := 0; i < len(chars); {
+		char := chars[i]
+
+		switch {
+		case char == '\'' || char == '`':
+			end := quotedEnd(chars, i)
+			builder.WriteString(string(chars[i:end]))
+			i = end
+		case isWordChar(char):
+			end := i
+			for end < len(chars) && isWordChar(chars[end]) {
+				end++
+			}
+
+			word := string(chars[i:end])
+
+			next := strings.TrimLeft(string(chars[end:]), " ")
+			if follows, ok := keywordCalls[word]; reserved[word] || ok && strings.HasPrefix(next, string(follows)) {
+				word = strings.ToUpper(word)
+			}
+
+			builder.WriteString(word)
+			i = end
+		default:
+			builder.WriteRune(char)
+			i++
+		}
+	}
+
+	return builder.String()
+}
+
+func isWordChar(char rune) bool {
+	return char == '_' || char == '.' || char == '$' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
@@ -0,0 +1,154 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+// queries covering every part of the grammar, each has to parse back to itself from String and from Format
+var roundTrips = []string{
+	"select *",
+	"select foo, bar as b where foo = 1 and (bar = 'x' or bar in (1, 2.5, 'y')) and baz != true",
+	"SELECT Host, COUNT(*) AS n FROM logs WHERE status >= 500 GROUP BY Host",
+	"select max(ms), average(ms) as avg group by host",
+	"select lower(trim(name)) as n, first || ' ' || last as full, (a + b) * c - -2 as x where starts_with(name, 'a')",
+	"select cast(ms as decimal(10, 2)) as d, try_cast(id as bigint), extract(hour from ts) as h, date_part('minute', ts)",
+	"select timestamp '2024-01-01T00:00:00Z' as t, interval '1 day 2 hours' as i, now() - interval '1 hour' as since",
+	"select case when ms > 500 then 'slow' when ms = 0 then 'none' else 'ok' end as speed, case status when 200 then 'ok' end",
+	"select rank() over (partition by host order by ms desc) as r, sum(ms) over (order by ts rows between 2 preceding and current row)",
+	"select percentile_cont(0.5) within group (order by ms) as p50 from logs group by host",
+	"select `status code`, `from`, `select` as `the select`, \"order\" from `my logs` where `rows` = 'it''s' and x = 'a\\\\b\\n'",
+	"select l.id, u.name from logs l left join users u on l.user_id = u.id and u.active = true full join teams using (team_id)",
+	"select * from 'test/sample.dat' s cross join unnest(s.tags) t right join (select id from users where admin = true) a on a.id = s.id",
+	"select l.id, t.n from logs l cross join lateral (select count(*) as n from events e where e.log_id = l.id) t",
+	"select id from logs where exists (select 1 from users u where u.id = logs.user_id) and ms > (select avg(ms) from logs)",
+	"select id from logs where user_id in (select id from users union select id from admins)",
+	"with slow as (select host from logs where ms > 500), hosts (name) as (select distinct_host from slow) select name from hosts",
+	"with recursive n (i) as (select 1 union all select i + 1 from n where i < 10) select i from n",
+	"select host from logs union all select host from old intersect select host from new except select host from gone",
+	"select id, unnest(tags) as tag, json_extract(payload, '$.a[0]') from logs where any(tags) = 'web'",
+	"select id from logs where user = ? and ms > $min and id in $ids",
+	"select id from logs where id in (?, 2, $id) and user in ('a', ?)",
+	"select `$user`, '?' as q",
+	"select `123`, `1e3` as `2x`, `9lives` from `42` t where `7` = 7",
+	"select 1.0 as one, 1e3, 2.5e-8 * x, 1e21 where y = 1.0 and z in (1e3, 2, -0.5)",
+	"select host, count(*) as n from logs group by host union select host, 0 from old order by n desc, lower(host)",
+	"select n from (with a as (select 1 as n) select n from a) t where 'db' = any(tags)",
+	"select (select max(ms) from logs where note = '(select x') as m from logs l join u on u.id in (select id from t)",
+	"select array_length(tags), element_at(tags, -1) from logs where array_contains(tags, 'slow') and id in (1, 2, 'x')",
+}
+
+func TestRoundTrips(t *testing.T) {
+	for _, sql := range roundTrips {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatalf("%s: %s", sql, err)
+		}
+
+		for _, printed := range []string{query.String(), query.Format()} {
+			again, err := Parse(printed)
+			if err != nil {
+				t.Errorf("%s printed as %s doesn't parse: %s", sql, printed, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(query, again) {
+				t.Errorf("%s printed as %s parses to %s", sql, printed, again)
+			}
+		}
+
+		// printing is stable, the canonical string of a canonical string is itself
+		if again, _ := Parse(query.String()); again.String() != query.String() {
+			t.Errorf("expected %s got %s", query, again)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	query, err := Parse("with slow as (select host, ms from logs where ms > 500) " +
+		"select s.host, count(*) as n, case when max(ms) > 900 then 'bad' else 'ok' end as health " +
+		"from slow s inner join (select host, `team name` from hosts where active = true) h on s.host = h.host " +
+		"where s.host != 'web1' and (ms < 1000 or s.host in ('web2', 'web3')) group by s.host " +
+		"union all select 'total', count(*), null from logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `WITH slow AS (
+  SELECT
+    host,
+    ms
+  FROM logs
+  WHERE ms > 500
+)
+SELECT
+  s.host,
+  count(*) AS n,
+  CASE WHEN max(ms) > 900 THEN 'bad' ELSE 'ok' END AS health
+FROM slow s
+INNER JOIN (
+  SELECT
+    host,
+    ` + "`team name`" + `
+  FROM hosts
+  WHERE active = TRUE
+) h ON s.host = h.host
+WHERE s.host != 'web1'
+  AND (ms < 1000 OR s.host IN ('web2', 'web3'))
+GROUP BY s.host
+UNION ALL
+SELECT
+  'total',
+  count(*),
+  NULL
+FROM logs`
+
+	if query.Format() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, query.Format())
+	}
+
+	// subqueries in expressions are indented inside their brackets like derived tables
+	query, err = Parse("select id, (select max(ms) from logs) as top from logs l " +
+		"join users u on u.id = l.user_id and u.team in (select id from teams) " +
+		"where exists (select 1 from admins a where a.id = l.user_id) or l.id in (select id from flagged where note = '(select)')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `SELECT
+  id,
+  (
+    SELECT max(ms)
+    FROM logs
+  ) AS top
+FROM logs l
+INNER JOIN users u ON u.id = l.user_id AND u.team IN (
+  SELECT id
+  FROM teams
+)
+WHERE EXISTS (
+  SELECT 1
+  FROM admins a
+  WHERE a.id = l.user_id
+)
+  OR l.id IN (
+    SELECT id
+    FROM flagged
+    WHERE note = '(select)'
+  )`
+
+	if query.Format() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, query.Format())
+	}
+
+	// names that are keywords are quoted so they keep their case
+	query, err = Parse("select `timestamp`, timestamp, cast(x as int) as `cast`, `rows` from logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "SELECT\n  timestamp,\n  timestamp,\n  CAST(x AS bigint) AS cast,\n  `rows`\nFROM logs"
+	if query.Format() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, query.Format())
+	}
+}
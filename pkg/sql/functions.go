@@ -0,0 +1,305 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// scalarFunction is a builtin that maps a row's arguments to a single value
+type scalarFunction struct {
+	minArgs int
+	// -1 when the function takes any number of arguments
+	maxArgs int
+	// when false any null argument makes the result null without calling the function
+	acceptsNull bool
+	call        func(s *Executor, args []interface{}) (interface{}, error)
+}
+
+var scalarFunctions = map[string]scalarFunction{
+	"now": {
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return s.now, nil
+		},
+	},
+	"date_trunc": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			unit, ts, err := stringAndTimestamp(args)
+			if err != nil {
+				return nil, err
+			}
+
+			return dateTrunc(unit, ts)
+		},
+	},
+	"date_part": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			part, ts, err := stringAndTimestamp(args)
+			if err != nil {
+				return nil, err
+			}
+
+			return extract(part, ts)
+		},
+	},
+	"strftime": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			ts, err := timestampArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			format, err := stringArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return strftime(ts, format)
+		},
+	},
+	"strptime": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			value, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			format, err := stringArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return strptime(value, format)
+		},
+	},
+	"epoch": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			ts, err := timestampArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return float64(ts.UnixNano()) / float64(time.Second), nil
+		},
+	},
+	"epoch_ms": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			ts, err := timestampArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return ts.UnixMilli(), nil
+		},
+	},
+	"to_timestamp": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			seconds, err := CastValue(args[0], Double)
+			if err != nil {
+				return nil, err
+			}
+
+			return CastValue(seconds, Timestamp)
+		},
+	},
+	"to_timestamp_ms": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			millis, err := CastValue(args[0], BigInt)
+			if err != nil {
+				return nil, err
+			}
+
+			return time.UnixMilli(millis.(int64)).UTC(), nil
+		},
+	},
+	"time_bucket": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return bucketArgs(args, bucketOrigin)
+		},
+	},
+	// groups the same as time_bucket but a group by on it also emits the empty buckets between the first and last
+	"time_bucket_gapfill": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return bucketArgs(args, bucketOrigin)
+		},
+	},
+	"date_bin": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return bucketArgs(args, dateBinOrigin)
+		},
+	},
+}
+
+func isScalarFunction(name string) bool {
+	_, ok := scalarFunctions[strings.ToLower(name)]
+
+	return ok
+}
+
+// finds a builtin or registered scalar function
+func (s *Executor) scalarFunction(name string) (scalarFunction, bool) {
+	if function, ok := scalarFunctions[strings.ToLower(name)]; ok {
+		return function, true
+	}
+
+	if udf, ok := s.registry.scalar(name); ok {
+		return udf.function(strings.ToLower(name)), true
+	}
+
+	return scalarFunction{}, false
+}
+
+func (s *Executor) call(sc *scope, call *Call) (interface{}, error) {
+	function, ok := s.scalarFunction(call.Name)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s is not a valid function", call.Name))
+	}
+
+	if len(call.Args) < function.minArgs || (function.maxArgs >= 0 && len(call.Args) > function.maxArgs) {
+		return nil, errors.New(fmt.Sprintf("wrong number of arguments to %s", call.Name))
+	}
+
+	var args []interface{}
+	for _, arg := range call.Args {
+		value, err := s.eval(sc, &arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil && !function.acceptsNull {
+			return nil, nil
+		}
+
+		args = append(args, value)
+	}
+
+	return function.call(s, args)
+}
+
+func stringArg(value interface{}) (string, error) {
+	if casted, ok := value.(string); ok {
+		return casted, nil
+	}
+
+	return "", errors.New(fmt.Sprintf("expected a string but got %v", value))
+}
+
+// timestamp arguments also accept strings and epoch seconds so raw log fields can be passed straight in
+func timestampArg(value interface{}) (time.Time, error) {
+	ts, err := CastValue(value, Timestamp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ts.(time.Time), nil
+}
+
+func stringAndTimestamp(args []interface{}) (string, time.Time, error) {
+	str, err := stringArg(args[0])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	ts, err := timestampArg(args[1])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return str, ts, nil
+}
+
+// intervals also accept strings like '5 minutes'
+func intervalArg(value interface{}) (IntervalValue, error) {
+	interval, err := CastValue(value, Interval)
+	if err != nil {
+		return IntervalValue{}, err
+	}
+
+	return interval.(IntervalValue), nil
+}
+
+// (width, timestamp, optional origin)
+func bucketArgs(args []interface{}, origin time.Time) (interface{}, error) {
+	width, err := intervalArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := timestampArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) > 2 {
+		origin, err = timestampArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+	} else if width.Months > 0 {
+		// month buckets start on the first of the month
+		origin = dateBinOrigin
+	}
+
+	return timeBucket(width, ts, origin)
+}
+
+// integer arguments accept any whole number
+func intArg(value interface{}) (int, error) {
+	if !isNumeric(normalize(value)) {
+		return 0, errors.New(fmt.Sprintf("expected a number but got %v", value))
+	}
+
+	integer, err := CastValue(value, BigInt)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(integer.(int64)), nil
+}
+
+func stringArgs(args []interface{}) ([]string, error) {
+	var strs []string
+
+	for _, arg := range args {
+		str, err := stringArg(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+// compiles each pattern once per query
+func (s *Executor) regexp(pattern string) (*regexp.Regexp, error) {
+	s.regexpsLock.Lock()
+	defer s.regexpsLock.Unlock()
+
+	if compiled, ok := s.regexps[pattern]; ok {
+		return compiled, nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	s.regexps[pattern] = compiled
+
+	return compiled, nil
+}
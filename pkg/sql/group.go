@@ -0,0 +1,414 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type rowGroup struct {
+	keys []interface{}
+	rows []input.DataRow
+}
+
+// a query is grouped when it has a group by or aggregates any of its computed fields
+func (s *Executor) grouped() bool {
+	if len(s.sql.GroupBy) > 0 {
+		return true
+	}
+
+	for _, field := range s.sql.Fields {
+		if s.containsAggregate(field.Expr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// group by expressions with aliases of computed fields swapped for the expression they alias
+func (s *Executor) groupByExprs() []Expr {
+	var exprs []Expr
+
+	for _, expr := range s.sql.GroupBy {
+		for _, field := range s.sql.Fields {
+			if expr.Column != "" && field.Expr != nil && field.Alias == KeyAlias(expr.Column) {
+				expr = *field.Expr
+				break
+			}
+		}
+
+		exprs = append(exprs, expr)
+	}
+
+	return exprs
+}
+
+// buckets the rows by their group by values and selects one row per group
+func (s *Executor) groupRows(rows []input.DataRow) ([]input.DataRow, error) {
+	exprs := s.groupByExprs()
+
+	var groups []*rowGroup
+	index := map[string]*rowGroup{}
+
+	for _, row := range rows {
+		sc := newScope(row)
+
+		var keys []interface{}
+		for i := range exprs {
+			key, err := s.eval(sc, &exprs[i])
+			if err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, key)
+		}
+
+		id := groupId(keys)
+
+		group, ok := index[id]
+		if !ok {
+			group = &rowGroup{keys: keys}
+			index[id] = group
+			groups = append(groups, group)
+		}
+
+		group.rows = append(group.rows, row)
+	}
+
+	// aggregating without a group by is a single group, even when there are no rows
+	if len(exprs) == 0 && len(groups) == 0 {
+		groups = append(groups, &rowGroup{})
+	}
+
+	groups, err := s.fillGaps(exprs, groups, index)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []input.DataRow
+	for _, group := range groups {
+		row, err := s.selectGroup(exprs, group)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, row)
+	}
+
+	return results, nil
+}
+
+func groupId(keys []interface{}) string {
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%T:%s", key, literalString(key)))
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// selects the fields of a group, aggregates run over every row and anything else reads the group by values, a
+// column that is neither fails
+func (s *Executor) selectGroup(exprs []Expr, group *rowGroup) (input.DataRow, error) {
+	sc := newScope(input.DataRow{})
+	sc.group = group.rows
+	sc.groupKeys = map[string]interface{}{}
+	for i, expr := range exprs {
+		sc.groupKeys[expr.String()] = group.keys[i]
+
+		// grouping by a column's alias groups by the column
+		if expr.Column != "" {
+			sc.groupKeys[NewColumn(keyNameFromAlias(expr.Column, s.sql)).String()] = group.keys[i]
+		}
+	}
+
+	selected := make(input.DataRow)
+
+	for _, field := range s.sql.Fields {
+		switch {
+		case field.Name == "*":
+			return nil, errors.New("* can't be selected with aggregates or a group by, name the grouped columns")
+		case field.Expr != nil:
+			value, err := s.evalField(sc, field)
+			if err != nil {
+				return nil, err
+			}
+
+			selected[string(field.Alias)] = value
+		default:
+			value, err := s.column(sc, field.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			selected[string(field.Alias)] = value
+		}
+	}
+
+	return selected, nil
+}
+
+// runs an aggregate call over every row of the group in scope
+func (s *Executor) aggregate(sc *scope, call *Call) (interface{}, error) {
+	if sc.group == nil && sc.groupKeys == nil {
+		return nil, errors.New(fmt.Sprintf("aggregate %s can only be used in the selected fields", call.Name))
+	}
+
+	call, function, params, err := s.prepareAggregate(call)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.accumulate(sc, call, function, params, sc.group)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.finalize(call, function, state)
+}
+
+// finds the aggregate the call runs and evaluates its parameters, an ordered set call has what it orders by moved
+// into its arguments
+func (s *Executor) prepareAggregate(call *Call) (*Call, aggregateFunction, []interface{}, error) {
+	function, ok := s.aggregateFunction(call.Name)
+	if !ok {
+		return nil, aggregateFunction{}, nil, errors.New(fmt.Sprintf("%s is not a valid aggregate", call.Name))
+	}
+
+	if len(call.WithinGroup) > 0 {
+		descending := call.WithinGroup[0].Desc
+		call = call.orderedSetCall()
+
+		init := function.init
+		function.init = func(params []interface{}) (Aggregate, error) {
+			state, err := init(params)
+			if ordered, ok := state.(*orderedSetAggregator); ok {
+				ordered.descending = descending
+			}
+
+			return state, err
+		}
+	}
+
+	if len(call.Args) != function.arity() {
+		return nil, aggregateFunction{}, nil, errors.New(fmt.Sprintf("wrong number of arguments to %s", call.Name))
+	}
+
+	// parameters are constant so they are evaluated once without a row
+	var params []interface{}
+	for i := len(call.Args) - function.params; i < len(call.Args); i++ {
+		param, err := s.eval(newScope(input.DataRow{}), &call.Args[i])
+		if err != nil {
+			return nil, aggregateFunction{}, nil, err
+		}
+
+		params = append(params, param)
+	}
+
+	return call, function, params, nil
+}
+
+// the result of the aggregate, checked against the declared type of a registered aggregate
+func (s *Executor) finalize(call *Call, function aggregateFunction, state Aggregate) (interface{}, error) {
+	result, err := state.Finalize()
+	if err != nil || function.args == nil {
+		return result, err
+	}
+
+	returned, err := bindValue(result, function.returns, false)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s returned the wrong type: %s", strings.ToLower(call.Name), err))
+	}
+
+	return returned, nil
+}
+
+// accumulates the rows into a single Aggregate, with parallelism the rows are split between workers that each
+// accumulate a partial and the partials are merged in order
+func (s *Executor) accumulate(sc *scope, call *Call, function aggregateFunction, params []interface{}, rows []input.DataRow) (Aggregate, error) {
+	workers := tern(s.workers < len(rows), s.workers, len(rows))
+	if workers <= 1 {
+		state, err := function.init(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return state, s.accumulateRows(sc.resolving, call, function, state, rows)
+	}
+
+	partials := make([]Aggregate, workers)
+	errs := make([]error, workers)
+	size := (len(rows) + workers - 1) / workers
+
+	var wait sync.WaitGroup
+	for i := range partials {
+		var err error
+		if partials[i], err = function.init(params); err != nil {
+			return nil, err
+		}
+
+		chunk := rows[tern(i*size < len(rows), i*size, len(rows)):tern((i+1)*size < len(rows), (i+1)*size, len(rows))]
+
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+
+			errs[i] = s.accumulateRows(maps.Clone(sc.resolving), call, function, partials[i], chunk)
+		}(i)
+	}
+
+	wait.Wait()
+
+	for i := range partials {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+
+		if i > 0 {
+			if err := partials[0].Merge(partials[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return partials[0], nil
+}
+
+// evaluates the aggregate's arguments for every row, resolving holds the aliases being resolved so max(x) as x
+// reads x from the row
+func (s *Executor) accumulateRows(resolving map[KeyAlias]bool, call *Call, function aggregateFunction, state Aggregate, rows []input.DataRow) error {
+	for _, row := range rows {
+		rowScope := newScope(row)
+		rowScope.resolving = resolving
+
+		args, skip, err := s.aggregateArgs(rowScope, call, function)
+		if err != nil {
+			return err
+		}
+
+		if skip {
+			continue
+		}
+
+		if err := state.Accumulate(args); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// builtins get every value as is, registered aggregates get values of their declared types and skip nulls
+func (s *Executor) aggregateArgs(sc *scope, call *Call, function aggregateFunction) (args []interface{}, skip bool, err error) {
+	for i := range call.Args[:len(call.Args)-function.params] {
+		// count(*) counts every row
+		if call.Args[i].Column == "*" && function.args == nil {
+			args = append(args, true)
+			continue
+		}
+
+		value, err := s.eval(sc, &call.Args[i])
+		if err != nil {
+			return nil, false, err
+		}
+
+		if function.args == nil {
+			args = append(args, s.comparisonValue(value))
+			continue
+		}
+
+		if value == nil {
+			return nil, true, nil
+		}
+
+		bound, err := bindValue(value, function.args[i], s.lenient)
+		if err != nil {
+			return nil, false, errors.New(fmt.Sprintf("argument %d of %s: %s", i+1, strings.ToLower(call.Name), err))
+		}
+
+		args = append(args, bound)
+	}
+
+	return args, false, nil
+}
+
+// adds empty groups for every missing bucket of a time_bucket_gapfill between the first and last bucket
+func (s *Executor) fillGaps(exprs []Expr, groups []*rowGroup, index map[string]*rowGroup) ([]*rowGroup, error) {
+	bucket := slices.IndexFunc(exprs, func(expr Expr) bool {
+		return expr.Call != nil && strings.ToLower(expr.Call.Name) == "time_bucket_gapfill"
+	})
+
+	if bucket < 0 || len(groups) == 0 {
+		return groups, nil
+	}
+
+	width, err := s.eval(newScope(input.DataRow{}), &exprs[bucket].Call.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := intervalArg(width)
+	if err != nil {
+		return nil, err
+	}
+
+	var first, last time.Time
+
+	// the other group by values, each gets its own run of buckets
+	var series [][]interface{}
+	seen := map[string]bool{}
+
+	for _, group := range groups {
+		ts, ok := group.keys[bucket].(time.Time)
+		if !ok {
+			continue
+		}
+
+		if first.IsZero() || ts.Before(first) {
+			first = ts
+		}
+
+		if last.IsZero() || ts.After(last) {
+			last = ts
+		}
+
+		others := slices.Delete(slices.Clone(group.keys), bucket, bucket+1)
+		if id := groupId(others); !seen[id] {
+			seen[id] = true
+			series = append(series, others)
+		}
+	}
+
+	if first.IsZero() {
+		return groups, nil
+	}
+
+	var filled []*rowGroup
+	for _, others := range series {
+		for ts := first; !ts.After(last); ts = interval.addTo(ts) {
+			keys := slices.Insert(slices.Clone(others), bucket, interface{}(ts))
+
+			group, ok := index[groupId(keys)]
+			if !ok {
+				group = &rowGroup{keys: keys}
+			}
+
+			filled = append(filled, group)
+		}
+	}
+
+	// groups with a null bucket have nowhere to go in the series so they come last
+	for _, group := range groups {
+		if _, ok := group.keys[bucket].(time.Time); !ok {
+			filled = append(filled, group)
+		}
+	}
+
+	return filled, nil
+}
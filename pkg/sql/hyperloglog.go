@@ -0,0 +1,152 @@
+package sql
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+func init() {
+	aggregateFunctions["approx_count_distinct"] = simpleAggregate(func() Aggregate {
+		return &approxDistinctAggregator{sketch: newHyperLogLog()}
+	})
+}
+
+const (
+	// 2^14 registers estimate within about 0.8%
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+	// small sets keep their exact hashes until they would take more memory than the registers
+	hllSparseLimit = hllRegisters / 8
+)
+
+// hyperLogLog estimates the number of distinct values it has seen in a fixed amount of memory
+type hyperLogLog struct {
+	sparse    map[uint64]bool
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{sparse: map[uint64]bool{}}
+}
+
+// hashes the value the same way group by tells values apart, so 1 and 1.0 are different values
+func hllHash(value interface{}) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(groupId([]interface{}{value})))
+
+	// fnv spreads short keys poorly over the high bits, so the result is mixed with the murmur3 finalizer
+	h := hash.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
+
+func (h *hyperLogLog) add(hash uint64) {
+	if h.registers == nil {
+		h.sparse[hash] = true
+
+		if len(h.sparse) > hllSparseLimit {
+			h.densify()
+		}
+
+		return
+	}
+
+	// the first bits pick a register and the rest count leading zeros
+	register := hash >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank > h.registers[register] {
+		h.registers[register] = rank
+	}
+}
+
+func (h *hyperLogLog) densify() {
+	h.registers = make([]uint8, hllRegisters)
+
+	for hash := range h.sparse {
+		h.add(hash)
+	}
+
+	h.sparse = nil
+}
+
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	if other.registers == nil {
+		for hash := range other.sparse {
+			h.add(hash)
+		}
+
+		return
+	}
+
+	if h.registers == nil {
+		h.densify()
+	}
+
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+func (h *hyperLogLog) estimate() int64 {
+	if h.registers == nil {
+		return int64(len(h.sparse))
+	}
+
+	sum := 0.0
+	zeros := 0
+
+	for _, rank := range h.registers {
+		sum += math.Pow(2, -float64(rank))
+
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(hllRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	// linear counting is more accurate while many registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
+
+// approx_count_distinct(x) counts the distinct values that aren't null
+type approxDistinctAggregator struct {
+	sketch *hyperLogLog
+}
+
+func (a *approxDistinctAggregator) Accumulate(args []interface{}) error {
+	if args[0] != nil {
+		a.sketch.add(hllHash(args[0]))
+	}
+
+	return nil
+}
+
+func (a *approxDistinctAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*approxDistinctAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	a.sketch.merge(partial.sketch)
+
+	return nil
+}
+
+func (a *approxDistinctAggregator) Finalize() (interface{}, error) {
+	return a.sketch.estimate(), nil
+}
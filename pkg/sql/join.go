@@ -0,0 +1,457 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// a source of rows, a table registered with WithTable, a file or a query. The first name in the query that isn't
+// registered reads the piped input
+type Table struct {
+	Name string `json:",omitempty"`
+	// a derived table, from (select ...) t
+	Query *Query `json:",omitempty"`
+	// a newline delimited json or csv file, read when the query runs
+	File string `json:",omitempty"`
+	// unnest(tags), a row for each element of an array of the row it is joined to
+	Unnest *Expr `json:",omitempty"`
+	// a lateral subquery is run for each row it is joined to and can read its columns
+	Lateral bool   `json:",omitempty"`
+	Alias   string `json:",omitempty"`
+}
+
+type JoinKind string
+
+const (
+	InnerJoin JoinKind = "inner"
+	LeftJoin           = "left"
+	RightJoin          = "right"
+	FullJoin           = "full"
+	// every row joined to every row, without a condition
+	CrossJoin = "cross"
+)
+
+// join users u on e.user_id = u.id, or join users using (user_id)
+type Join struct {
+	Kind  JoinKind
+	Table Table
+	On    *PredicateGroup `json:",omitempty"`
+	Using []string        `json:",omitempty"`
+}
+
+// the name columns of the table are qualified with, its alias or otherwise its name or file without the extension
+func (t Table) qualifier() string {
+	switch {
+	case t.Alias != "":
+		return t.Alias
+	case t.File != "":
+		return strings.TrimSuffix(filepath.Base(t.File), filepath.Ext(t.File))
+	case t.Unnest != nil:
+		return "unnest"
+	}
+
+	return t.Name
+}
+
+func (t Table) String() string {
+	name := quoteIdentifier(t.Name)
+
+	switch {
+	case t.Query != nil:
+		name = "(" + t.Query.String() + ")"
+	case t.File != "":
+		name = quoteString(t.File)
+	case t.Unnest != nil:
+		name = "unnest(" + t.Unnest.String() + ")"
+	}
+
+	if t.Lateral {
+		name = "lateral " + name
+	}
+
+	if t.Alias != "" {
+		name += " " + quoteIdentifier(t.Alias)
+	}
+
+	return name
+}
+
+func (j Join) String() string {
+	join := fmt.Sprintf("%s join %s", j.Kind, j.Table)
+	switch {
+	case len(j.Using) > 0:
+		return join + " using (" + quoteIdentifiers(j.Using) + ")"
+	case j.On == nil:
+		return join
+	}
+
+	return join + " on " + j.On.String()
+}
+
+// the rows a query runs over, the from table with every join applied. Once tables are named every column can also
+// be read qualified with the name of its table as table.column
+func (s *Executor) sourceRows(data []input.DataRow) ([]input.DataRow, error) {
+	if s.sql.From == nil && len(s.sql.Joins) == 0 {
+		return data, nil
+	}
+
+	from := Table{}
+	if s.sql.From != nil {
+		from = *s.sql.From
+	}
+
+	rows, err := s.tableRows(from, data)
+	if err != nil {
+		return nil, err
+	}
+
+	qualifiers := []string{from.qualifier()}
+
+	var qualified []input.DataRow
+	for _, row := range rows {
+		qualified = append(qualified, joinRow(nil, from.qualifier(), row))
+	}
+
+	rows = qualified
+
+	for _, join := range s.sql.Joins {
+		if join.Table.Lateral || join.Table.Unnest != nil {
+			if rows, err = s.lateralJoin(rows, join); err != nil {
+				return nil, err
+			}
+
+			qualifiers = append(qualifiers, join.Table.qualifier())
+			continue
+		}
+
+		right, err := s.tableRows(join.Table, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if rows, err = s.join(rows, qualifiers, right, join); err != nil {
+			return nil, err
+		}
+
+		qualifiers = append(qualifiers, join.Table.qualifier())
+	}
+
+	return rows, nil
+}
+
+// reads a table, a derived table runs its query, a common table reads the rows it already found and the name the
+// piped rows are read by reads them. data is what a from without a table reads
+func (s *Executor) tableRows(table Table, data []input.DataRow) ([]input.DataRow, error) {
+	switch {
+	case table.Query != nil:
+		return s.subExecutor(*table.Query, nil).run([]input.DataRow{{}})
+	case table.File != "":
+		return input.ReadFile(table.File)
+	case table.Name == "":
+		return data, nil
+	}
+
+	if rows, ok := s.ctes[table.Name]; ok {
+		return rows, nil
+	}
+
+	if rows, ok := s.tables[table.Name]; ok {
+		return rows, nil
+	}
+
+	if root := s.root(); table.Name == root.piped.name {
+		return root.piped.rows, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("unknown table %s", table.Name))
+}
+
+// the tables of the joins of the query
+func (q *Query) joinTables() []*Table {
+	var tables []*Table
+	for i := range q.Joins {
+		tables = append(tables, &q.Joins[i].Table)
+	}
+
+	return tables
+}
+
+// adds the columns of a row from the right of a join to the row joined so far. Unqualified columns read the
+// leftmost table that has them, so a column of a using clause reads whichever side matched
+func joinRow(left input.DataRow, qualifier string, right input.DataRow) input.DataRow {
+	joined := make(input.DataRow, len(left)+len(right)*2)
+	for key, value := range left {
+		joined[key] = value
+	}
+
+	for key, value := range right {
+		joined[qualifier+"."+key] = value
+
+		if _, ok := joined[key]; !ok {
+			joined[key] = value
+		}
+	}
+
+	return joined
+}
+
+// joins the rows so far with the rows of the next table. Equality between a column of each side is joined by
+// hashing the right side, any other condition compares every pair of rows
+func (s *Executor) join(left []input.DataRow, qualifiers []string, right []input.DataRow, join Join) ([]input.DataRow, error) {
+	qualifier := join.Table.qualifier()
+
+	leftKeys, rightKeys := s.equiKeys(join, qualifiers)
+
+	// the right rows to try against each left row, with a hash of the keys every row is a candidate
+	candidates := func(row input.DataRow) ([]int, error) {
+		all := make([]int, len(right))
+		for i := range all {
+			all[i] = i
+		}
+
+		return all, nil
+	}
+
+	if len(leftKeys) > 0 {
+		index := map[string][]int{}
+
+		for i, row := range right {
+			key, ok, err := s.joinKey(row, rightKeys)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				index[key] = append(index[key], i)
+			}
+		}
+
+		candidates = func(row input.DataRow) ([]int, error) {
+			key, ok, err := s.joinKey(row, leftKeys)
+			if !ok || err != nil {
+				return nil, err
+			}
+
+			return index[key], nil
+		}
+	}
+
+	var joined []input.DataRow
+	matchedRight := make([]bool, len(right))
+
+	for _, row := range left {
+		matches, err := candidates(row)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+
+		for _, i := range matches {
+			candidate := joinRow(row, qualifier, right[i])
+
+			ok, err := s.joinMatches(row, right[i], candidate, join)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				matched = true
+				matchedRight[i] = true
+				joined = append(joined, candidate)
+			}
+		}
+
+		if !matched && (join.Kind == LeftJoin || join.Kind == FullJoin) {
+			joined = append(joined, joinRow(row, qualifier, input.DataRow{}))
+		}
+	}
+
+	if join.Kind == RightJoin || join.Kind == FullJoin {
+		for i, row := range right {
+			if !matchedRight[i] {
+				joined = append(joined, joinRow(input.DataRow{}, qualifier, row))
+			}
+		}
+	}
+
+	return joined, nil
+}
+
+// joins each row so far with the rows the table gives for that row, a lateral subquery or unnest reads the columns
+// of the row it is joined to
+func (s *Executor) lateralJoin(left []input.DataRow, join Join) ([]input.DataRow, error) {
+	qualifier := join.Table.qualifier()
+
+	var joined []input.DataRow
+
+	for _, row := range left {
+		var right []input.DataRow
+		var err error
+
+		if join.Table.Unnest != nil {
+			right, err = s.unnestRows(join.Table, row)
+		} else {
+			right, err = s.subqueryRows(newScope(row), join.Table.Query)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+
+		for _, other := range right {
+			candidate := joinRow(row, qualifier, other)
+
+			ok, err := s.joinMatches(row, other, candidate, join)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				matched = true
+				joined = append(joined, candidate)
+			}
+		}
+
+		if !matched && join.Kind == LeftJoin {
+			joined = append(joined, joinRow(row, qualifier, input.DataRow{}))
+		}
+	}
+
+	return joined, nil
+}
+
+// the join condition checked against a pair of rows and the row they join into
+func (s *Executor) joinMatches(left input.DataRow, right input.DataRow, joined input.DataRow, join Join) (bool, error) {
+	if len(join.Using) == 0 {
+		return s.inPredicateGroup(newScope(joined), join.On)
+	}
+
+	for _, column := range join.Using {
+		value, target := normalize(left[column]), normalize(right[column])
+		if value == nil || target == nil {
+			return false, nil
+		}
+
+		matched, err := s.compare(Eq, value, target)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// the columns of each side a join condition compares for equality, the columns of the right are read from its own
+// rows. The whole condition still has to hold for a pair of rows to join, so these only narrow down the pairs
+func (s *Executor) equiKeys(join Join, qualifiers []string) (leftKeys []string, rightKeys []string) {
+	if len(join.Using) > 0 {
+		return join.Using, join.Using
+	}
+
+	qualifier := join.Table.qualifier()
+
+	// -1 for a column of the tables joined so far and 1 for a column of the table being joined
+	side := func(column string) int {
+		table, _, ok := strings.Cut(column, ".")
+		switch {
+		case !ok:
+			return 0
+		case table == qualifier:
+			return 1
+		case slices.Contains(qualifiers, table):
+			return -1
+		}
+
+		return 0
+	}
+
+	var walk func(group *PredicateGroup)
+	walk = func(group *PredicateGroup) {
+		// only a condition that every leaf of has to hold can be split into keys
+		if group == nil || group.Operator == Or && len(group.Predicate) > 1 {
+			return
+		}
+
+		for _, predicate := range group.Predicate {
+			walk(predicate.Group)
+
+			leaf := predicate.Leaf
+			if leaf == nil || leaf.Compare != Eq || leaf.Right == nil || leaf.Right.Column == "" {
+				continue
+			}
+
+			left := tern(leaf.Left != nil, leaf.Left, NewColumn(leaf.Field)).Column
+			right := leaf.Right.Column
+
+			if side(left) == 1 {
+				left, right = right, left
+			}
+
+			if side(left) == -1 && side(right) == 1 {
+				leftKeys = append(leftKeys, left)
+				rightKeys = append(rightKeys, strings.TrimPrefix(right, qualifier+"."))
+			}
+		}
+	}
+
+	walk(join.On)
+
+	return leftKeys, rightKeys
+}
+
+// hashes the values of the columns so values that compare equal hash the same, ok is false when a value is null
+// as null never joins
+func (s *Executor) joinKey(row input.DataRow, columns []string) (key string, ok bool, err error) {
+	var keys []interface{}
+
+	for _, column := range columns {
+		value := s.comparisonValue(row[column])
+		if value == nil {
+			return "", false, nil
+		}
+
+		// numbers of any type that are equal have to land together
+		switch value.(type) {
+		case float64, DecimalValue:
+			casted, err := CastValue(value, Double)
+			if err != nil {
+				return "", false, err
+			}
+
+			double := casted.(float64)
+			value = tern[interface{}](double == math.Trunc(double) && math.Abs(double) < math.MaxInt64, int64(double), double)
+		}
+
+		keys = append(keys, value)
+	}
+
+	return groupId(keys), true, nil
+}
+
+// a column of a named table read as table.column, which select * leaves out
+func (s *Executor) qualified(key string) bool {
+	table, _, ok := strings.Cut(key, ".")
+	if !ok {
+		return false
+	}
+
+	if s.sql.From != nil && table == s.sql.From.qualifier() {
+		return true
+	}
+
+	for _, join := range s.sql.Joins {
+		if table == join.Table.qualifier() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,329 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	for name, function := range jsonFunctions {
+		scalarFunctions[name] = function
+	}
+
+	aggregateFunctions["object_agg"] = builtinAggregate{
+		values: 2,
+		init: func(params []interface{}) (Aggregate, error) {
+			return &objectAggregator{}, nil
+		},
+	}
+}
+
+var jsonFunctions = map[string]scalarFunction{
+	"json_extract": {
+		minArgs: 2, maxArgs: 2,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			path, err := stringArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return jsonExtract(args[0], path)
+		},
+	},
+	// the keys of an object in order
+	"json_keys": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			object, ok := args[0].(map[string]interface{})
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("json_keys expects an object but got %v", args[0]))
+			}
+
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+
+			array := make([]interface{}, len(keys))
+			for i, key := range keys {
+				array[i] = key
+			}
+
+			return array, nil
+		},
+	},
+	"json_type": {
+		minArgs: 1, maxArgs: 1,
+		acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return jsonType(args[0]), nil
+		},
+	},
+	// json_object('a', 1, 'b', x) takes keys and values in turn
+	"json_object": {
+		maxArgs:     -1,
+		acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			if len(args)%2 != 0 {
+				return nil, errors.New("json_object expects a value for every key")
+			}
+
+			object := make(map[string]interface{}, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				key, err := objectKey(args[i])
+				if err != nil {
+					return nil, err
+				}
+
+				object[key] = args[i+1]
+			}
+
+			return object, nil
+		},
+	},
+	"json_array": {
+		maxArgs:     -1,
+		acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return append([]interface{}{}, args...), nil
+		},
+	},
+	"to_json": {
+		minArgs: 1, maxArgs: 1,
+		acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			encoded, err := json.Marshal(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return string(encoded), nil
+		},
+	},
+	// reads a string that holds json, like a payload logged as a string inside the outer json
+	"parse_json": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			raw, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return parseJson(raw)
+		},
+	},
+	// like parse_json but malformed json is null, the way try_cast is null when it can't cast, so one bad row
+	// doesn't fail the query
+	"try_parse_json": {
+		minArgs: 1, maxArgs: 1,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			raw, ok := args[0].(string)
+			if !ok {
+				return nil, nil
+			}
+
+			if parsed, err := parseJson(raw); err == nil {
+				return parsed, nil
+			}
+
+			return nil, nil
+		},
+	},
+}
+
+// decodes json the way rows are read, numbers are kept exact until they are used
+func parseJson(raw string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid json %s: %s", raw, err))
+	}
+
+	if decoder.More() {
+		return nil, errors.New(fmt.Sprintf("invalid json %s: more than one value", raw))
+	}
+
+	return normalize(parsed), nil
+}
+
+// the name json gives the type of a value
+func jsonType(value interface{}) string {
+	switch normalize(value).(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64, float64, DecimalValue:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	if _, err := arrayArg(value); err == nil {
+		return "array"
+	}
+
+	// timestamps and intervals are written as strings
+	return "string"
+}
+
+// object keys are strings, numbers and other values are written out as strings
+func objectKey(value interface{}) (string, error) {
+	if value == nil {
+		return "", errors.New("object keys can't be null")
+	}
+
+	key, err := CastValue(value, Varchar)
+	if err != nil {
+		return "", err
+	}
+
+	return key.(string), nil
+}
+
+// reads the value at a path like $.a.b[0] or $['a key'][-1], null when there is nothing there
+func jsonExtract(value interface{}, path string) (interface{}, error) {
+	steps, err := parseJsonPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, step := range steps {
+		switch casted := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+
+			value = object[casted]
+		case int:
+			array, err := arrayArg(value)
+			if err != nil {
+				return nil, nil
+			}
+
+			if casted < 0 {
+				casted += len(array)
+			}
+
+			if casted < 0 || casted >= len(array) {
+				return nil, nil
+			}
+
+			value = array[casted]
+		}
+	}
+
+	return normalize(value), nil
+}
+
+// splits a path into object keys and array indexes, indexes count from 0 and negative ones from the end
+func parseJsonPath(path string) ([]interface{}, error) {
+	invalid := errors.New(fmt.Sprintf("invalid json path %s, expected a path like $.a.b[0]", path))
+
+	if !strings.HasPrefix(path, "$") {
+		return nil, invalid
+	}
+
+	var steps []interface{}
+
+	for rest := path[1:]; rest != ""; {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+
+			if end == 1 {
+				return nil, invalid
+			}
+
+			steps = append(steps, rest[1:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, invalid
+			}
+
+			inside := rest[1:end]
+
+			if len(inside) >= 2 && (inside[0] == '\'' || inside[0] == '"') && inside[len(inside)-1] == inside[0] {
+				steps = append(steps, inside[1:len(inside)-1])
+			} else if index, err := strconv.Atoi(inside); err == nil {
+				steps = append(steps, index)
+			} else {
+				return nil, invalid
+			}
+
+			rest = rest[end+1:]
+		default:
+			return nil, invalid
+		}
+	}
+
+	return steps, nil
+}
+
+// object_agg(key, value) builds an object from the rows of a group, a later row replaces the value of a key an
+// earlier row set and rows with a null key are left out
+type objectAggregator struct {
+	object map[string]interface{}
+}
+
+func (a *objectAggregator) Accumulate(args []interface{}) error {
+	if args[0] == nil {
+		return nil
+	}
+
+	key, err := objectKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	if a.object == nil {
+		a.object = map[string]interface{}{}
+	}
+
+	a.object[key] = args[1]
+
+	return nil
+}
+
+func (a *objectAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*objectAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	if a.object == nil && partial.object != nil {
+		a.object = map[string]interface{}{}
+	}
+
+	maps.Copy(a.object, partial.object)
+
+	return nil
+}
+
+func (a *objectAggregator) Finalize() (interface{}, error) {
+	if a.object == nil {
+		return nil, nil
+	}
+
+	return maps.Clone(a.object), nil
+}
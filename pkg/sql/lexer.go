@@ -1,53 +1,150 @@
 package sql
 
 import (
+	"errors"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Tokens []string
 
-func Lex(raw string) Tokens {
+// Token is a single lexed token, Quote is the quote character that wrapped it or 0 if it was bare. Single quotes
+// wrap strings, double quotes and backticks wrap identifiers like column names with spaces or that are keywords
+type Token struct {
+	Value string
+	Quote rune
+}
+
+func (t Token) Quoted() bool {
+	return t.Quote != 0
+}
+
+// true for a 'string', quoted identifiers aren't strings
+func (t Token) StringLiteral() bool {
+	return t.Quote == '\''
+}
+
+// the token as it is compared with keywords, bare words match keywords in any case and quoted words never do
+func (t Token) Keyword() string {
+	if t.Quoted() {
+		return string(t.Quote) + t.Value + string(t.Quote)
+	}
+
+	return strings.ToLower(t.Value)
+}
+
+func Lex(raw string) (Tokens, error) {
+	lexed, err := LexTokens(raw)
+	if err != nil {
+		return nil, err
+	}
+
 	var tokens []string
 
-	parenth := false
+	for _, token := range lexed {
+		tokens = append(tokens, token.Value)
+	}
+
+	return tokens, nil
+}
+
+// LexTokens splits the query into tokens, it fails on a /* comment that is never closed rather than reading the rest
+// of the query as part of it
+func LexTokens(raw string) ([]Token, error) {
+	var tokens []Token
+
+	// the quote character of the string we're in, 0 when not in a quoted string
+	var quote rune
+
+	chars := []rune(raw)
 
 	buff := ""
-	for _, char := range []rune(raw) {
-		if char == '(' || char == ')' {
+	for i := 0; i < len(chars); i++ {
+		char := chars[i]
+
+		// -- comments to the end of the line and /* */ comments are read like a space
+		if quote == 0 && char == '-' && i+1 < len(chars) && chars[i+1] == '-' {
+			for i < len(chars) && chars[i] != '\n' {
+				i++
+			}
+
+			char = ' '
+		}
+
+		if quote == 0 && char == '/' && i+1 < len(chars) && chars[i+1] == '*' {
+			for i += 3; i < len(chars) && !(chars[i-1] == '*' && chars[i] == '/'); i++ {
+			}
+
+			if i >= len(chars) {
+				return nil, errors.New("a /* comment is never closed with */")
+			}
+
+			char = ' '
+		}
+
+		if quote == 0 && (char == '(' || char == ')' || char == ',') {
 			if buff != "" {
-				tokens = append(tokens, strings.Trim(buff, " "))
+				tokens = append(tokens, Token{Value: strings.Trim(buff, " ")})
 			}
 
-			tokens = append(tokens, string(char))
+			tokens = append(tokens, Token{Value: string(char)})
 			buff = ""
 			continue
 		}
 
-		// in a quoted string and ending the quote
-		if char == '"' && parenth {
-			if buff != "" {
-				tokens = append(tokens, strings.Trim(buff, " "))
+		// a backslash escapes the character after it in strings and double quoted names
+		if char == '\\' && (quote == '\'' || quote == '"') && i+1 < len(chars) {
+			escaped, length := unescape(chars[i+1:])
+			buff += escaped
+			i += length
+			continue
+		}
+
+		// in a quoted string and ending the quote, a doubled quote is the quote character itself like 'it''s'
+		if char == quote {
+			if i+1 < len(chars) && chars[i+1] == quote {
+				buff += string(char)
+				i++
+				continue
 			}
-			parenth = false
+
+			tokens = append(tokens, Token{Value: buff, Quote: quote})
+			quote = 0
 			buff = ""
 			continue
 		}
 
 		// not in a quoted string
-		if !parenth {
-			// end of quoted string
-			if char == '"' {
+		if quote == 0 {
+			// start of quoted string
+			if char == '"' || char == '\'' || char == '`' {
+				if buff != "" {
+					tokens = append(tokens, Token{Value: strings.Trim(buff, " ")})
+				}
+
 				buff = ""
-				parenth = true
+				quote = char
 				continue
 			}
 
 			if unicode.IsSpace(char) && buff != "" {
-				tokens = append(tokens, strings.Trim(buff, " "))
+				tokens = append(tokens, Token{Value: strings.Trim(buff, " ")})
 				buff = ""
 				continue
 			}
+
+			if unicode.IsSpace(char) {
+				continue
+			}
+
+			// a minus in front of a name is its own token so -age negates age, a minus inside a word like
+			// user-agent is part of it and -2 is a number
+			if char == '-' && buff == "" && i+1 < len(chars) && (unicode.IsLetter(chars[i+1]) || strings.ContainsRune("_$?", chars[i+1])) {
+				tokens = append(tokens, Token{Value: "-"})
+				continue
+			}
 		}
 
 		// continue lexing
@@ -55,8 +152,28 @@ func Lex(raw string) Tokens {
 	}
 
 	if buff != "" {
-		tokens = append(tokens, strings.Trim(buff, " "))
+		tokens = append(tokens, Token{Value: tern(quote != 0, buff, strings.Trim(buff, " ")), Quote: quote})
+	}
+
+	return tokens, nil
+}
+
+var escapes = map[rune]string{'n': "\n", 't': "\t", 'r': "\r", '\\': "\\", '\'': "'", '"': `"`}
+
+// the character a backslash escapes and the number of characters after the backslash that make it up. \uXXXX and
+// \UXXXXXXXX are unicode code points, an unknown escape like \d keeps its backslash so regular expressions can be
+// written without doubling every backslash
+func unescape(chars []rune) (string, int) {
+	if escaped, ok := escapes[chars[0]]; ok {
+		return escaped, 1
+	}
+
+	digits := map[rune]int{'u': 4, 'U': 8}[chars[0]]
+	if digits > 0 && len(chars) > digits {
+		if code, err := strconv.ParseUint(string(chars[1:digits+1]), 16, 32); err == nil && utf8.ValidRune(rune(code)) {
+			return string(rune(code)), digits + 1
+		}
 	}
 
-	return tokens
+	return "\\" + string(chars[0]), 1
 }
@@ -6,7 +6,10 @@ import (
 )
 
 func TestLexes(t *testing.T) {
-	lexed := Lex(`select foo from bar where zap = "jim jam"`)
+	lexed, err := Lex(`select foo from bar where zap = "jim jam"`)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	result := []string{
 		`select`,
@@ -25,7 +28,10 @@ func TestLexes(t *testing.T) {
 }
 
 func TestLexesWithParenth(t *testing.T) {
-	lexed := Lex(`select foo from bar where (zap = "jim jam" and zip = 1) or boo = 3`)
+	lexed, err := Lex(`select foo from bar where (zap = "jim jam" and zip = 1) or boo = 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	result := []string{
 		`select`,
@@ -54,7 +60,10 @@ func TestLexesWithParenth(t *testing.T) {
 }
 
 func TestLexesWithParenthAlias(t *testing.T) {
-	lexed := Lex(`select average(foo) as avg from bar where (zap = "jim jam" and zip = 1) or boo = 3`)
+	lexed, err := Lex(`select average(foo) as avg from bar where (zap = "jim jam" and zip = 1) or boo = 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	result := []string{
 		`select`,
@@ -86,3 +95,80 @@ func TestLexesWithParenthAlias(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestLexesQuotes(t *testing.T) {
+	lexed, err := LexTokens("select `status code`, \"from\" where name = 'it''s' and x = \"a \"\"b\"\"\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := []Token{
+		{Value: "select"},
+		{Value: "status code", Quote: '`'},
+		{Value: ","},
+		{Value: "from", Quote: '"'},
+		{Value: "where"},
+		{Value: "name"},
+		{Value: "="},
+		{Value: "it's", Quote: '\''},
+		{Value: "and"},
+		{Value: "x"},
+		{Value: "="},
+		{Value: `a "b"`, Quote: '"'},
+	}
+
+	if !slices.Equal(result, lexed) {
+		t.Errorf("expected %v got %v", result, lexed)
+	}
+}
+
+func TestLexesEscapes(t *testing.T) {
+	for raw, expected := range map[string]string{
+		`'say \"hi\"'`:     `say "hi"`,
+		`"say \"hi\""`:     `say "hi"`,
+		`'it\'s'`:          `it's`,
+		`'a\\b'`:           `a\b`,
+		`'a\nb\tc'`:        "a\nb\tc",
+		`'caf\u00e9'`:      "café",
+		`'\U0001F600'`:     "😀",
+		`'\d+\.\w'`:        `\d+\.\w`,
+		`'\uzzzz'`:         `\uzzzz`,
+		"`a\\nb`":          `a\nb`,
+		`'-- not /* a */'`: `-- not /* a */`,
+	} {
+		lexed, err := LexTokens(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(lexed) != 1 || lexed[0].Value != expected {
+			t.Errorf("expected %s to lex to %q got %v", raw, expected, lexed)
+		}
+	}
+}
+
+func TestLexesComments(t *testing.T) {
+	lexed, err := Lex(`-- slow requests
+select host, /* the slowest */ max(ms) as ms -- per host
+from logs /* only
+errors */ where status = 500--end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := []string{`select`, `host`, `,`, `max`, `(`, `ms`, `)`, `as`, `ms`, `from`, `logs`, `where`, `status`, `=`, `500`}
+
+	if !slices.Equal(result, lexed) {
+		t.Errorf("expected %v got %v", result, lexed)
+	}
+
+	for _, raw := range []string{`select a /* note where x = 1`, `select a /*/`} {
+		if lexed, err := Lex(raw); err == nil {
+			t.Errorf("expected an unclosed comment in %s to fail but got %v", raw, lexed)
+		}
+	}
+
+	if _, err := Parse(`select a /* note where x = 1`); err == nil {
+		t.Error("expected a query with an unclosed comment to fail")
+	}
+}
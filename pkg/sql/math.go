@@ -0,0 +1,282 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+func init() {
+	for name, function := range mathFunctions {
+		scalarFunctions[name] = function
+	}
+}
+
+// wraps a function that only takes numeric arguments
+func numericFunction(minArgs int, maxArgs int, call func(args []interface{}) (interface{}, error)) scalarFunction {
+	return scalarFunction{
+		minArgs: minArgs,
+		maxArgs: maxArgs,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			var numbers []interface{}
+
+			for _, arg := range args {
+				number, err := s.numberArg(arg)
+				if err != nil {
+					return nil, err
+				}
+
+				numbers = append(numbers, number)
+			}
+
+			return call(numbers)
+		},
+	}
+}
+
+// wraps a function of doubles, the result is always a double
+func floatFunction(call func(x float64) (float64, error)) scalarFunction {
+	return numericFunction(1, 1, func(args []interface{}) (interface{}, error) {
+		return call(floatArg(args[0]))
+	})
+}
+
+// abs, round, floor, ceil and mod keep integers as integers and decimals exact, the rest work in doubles
+var mathFunctions = map[string]scalarFunction{
+	"abs": numericFunction(1, 1, func(args []interface{}) (interface{}, error) {
+		switch casted := args[0].(type) {
+		case int64:
+			if casted >= 0 {
+				return casted, nil
+			}
+
+			return numericArithmetic("-", int64(0), casted)
+		case float64:
+			return math.Abs(casted), nil
+		}
+
+		decimal := args[0].(DecimalValue)
+
+		return DecimalValue{Rat: new(big.Rat).Abs(decimal.Rat), Scale: decimal.Scale}, nil
+	}),
+	"sign": numericFunction(1, 1, func(args []interface{}) (interface{}, error) {
+		if float, ok := args[0].(float64); ok && math.IsNaN(float) {
+			return nil, nil
+		}
+
+		return int64(compareNumbers(args[0], int64(0))), nil
+	}),
+	// round(x, n) rounds half away from zero to n digits, a negative n rounds to tens, hundreds and so on
+	"round": numericFunction(1, 2, func(args []interface{}) (interface{}, error) {
+		digits := 0
+		if len(args) > 1 {
+			var err error
+
+			digits, err = intArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch casted := args[0].(type) {
+		case int64:
+			if digits >= 0 {
+				return casted, nil
+			}
+
+			rounded := roundRat(new(big.Rat).SetInt64(casted), digits)
+
+			return normalizeNumber(jsonNumber(rounded)), nil
+		case float64:
+			if math.IsInf(casted, 0) || math.IsNaN(casted) {
+				return casted, nil
+			}
+
+			rounded, _ := roundRat(toRat(casted), digits).Float64()
+
+			return rounded, nil
+		}
+
+		decimal := args[0].(DecimalValue)
+
+		return DecimalValue{Rat: roundRat(decimal.Rat, digits), Scale: tern(digits > 0, digits, 0)}, nil
+	}),
+	"floor": numericFunction(1, 1, func(args []interface{}) (interface{}, error) {
+		return roundToward(args[0], math.Floor, -1)
+	}),
+	"ceil": numericFunction(1, 1, func(args []interface{}) (interface{}, error) {
+		return roundToward(args[0], math.Ceil, 1)
+	}),
+	"ceiling": numericFunction(1, 1, func(args []interface{}) (interface{}, error) {
+		return roundToward(args[0], math.Ceil, 1)
+	}),
+	"mod": numericFunction(2, 2, func(args []interface{}) (interface{}, error) {
+		return numericArithmetic("%", args[0], args[1])
+	}),
+	"sqrt": floatFunction(func(x float64) (float64, error) {
+		if x < 0 {
+			return 0, errors.New("cannot take the square root of a negative number")
+		}
+
+		return math.Sqrt(x), nil
+	}),
+	"ln": floatFunction(func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, errors.New("cannot take the logarithm of zero or a negative number")
+		}
+
+		return math.Log(x), nil
+	}),
+	"log10": floatFunction(func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, errors.New("cannot take the logarithm of zero or a negative number")
+		}
+
+		return math.Log10(x), nil
+	}),
+	"exp": floatFunction(func(x float64) (float64, error) {
+		return math.Exp(x), nil
+	}),
+	"pow": numericFunction(2, 2, func(args []interface{}) (interface{}, error) {
+		return power(floatArg(args[0]), floatArg(args[1]))
+	}),
+	"power": numericFunction(2, 2, func(args []interface{}) (interface{}, error) {
+		return power(floatArg(args[0]), floatArg(args[1]))
+	}),
+	// greatest and least skip nulls and are only null when every argument is
+	"greatest": {
+		minArgs: 1, maxArgs: -1, acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return s.extreme(args, 1)
+		},
+	},
+	"least": {
+		minArgs: 1, maxArgs: -1, acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return s.extreme(args, -1)
+		},
+	},
+}
+
+// numeric arguments are normalized, and in lenient mode numeric strings are accepted as well
+func (s *Executor) numberArg(value interface{}) (interface{}, error) {
+	number := s.comparisonValue(value)
+	if !isNumeric(number) {
+		return nil, errors.New(fmt.Sprintf("expected a number but got %v", value))
+	}
+
+	return number, nil
+}
+
+func floatArg(value interface{}) float64 {
+	float, _ := CastValue(value, Double)
+
+	return float.(float64)
+}
+
+func power(base float64, exponent float64) (interface{}, error) {
+	if base == 0 && exponent < 0 {
+		return nil, errors.New("division by zero")
+	}
+
+	if base < 0 && exponent != math.Trunc(exponent) {
+		return nil, errors.New("cannot raise a negative number to a fractional power")
+	}
+
+	return math.Pow(base, exponent), nil
+}
+
+// rounds half away from zero to the given number of digits after the decimal point
+func roundRat(value *big.Rat, digits int) *big.Rat {
+	shift := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(digits))), nil))
+
+	scaled := new(big.Rat).Set(value)
+	if digits < 0 {
+		scaled.Quo(scaled, shift)
+	} else {
+		scaled.Mul(scaled, shift)
+	}
+
+	rounded, _ := new(big.Rat).SetString(scaled.FloatString(0))
+	if digits < 0 {
+		return rounded.Mul(rounded, shift)
+	}
+
+	return rounded.Quo(rounded, shift)
+}
+
+// floors when direction is -1 and ceils when it is 1
+func roundToward(value interface{}, float func(float64) float64, direction int) (interface{}, error) {
+	switch casted := value.(type) {
+	case int64:
+		return casted, nil
+	case float64:
+		return float(casted), nil
+	}
+
+	rat := value.(DecimalValue).Rat
+
+	quotient, remainder := new(big.Int).QuoRem(rat.Num(), rat.Denom(), new(big.Int))
+	if remainder.Sign() != 0 && remainder.Sign() == direction {
+		quotient.Add(quotient, big.NewInt(int64(direction)))
+	}
+
+	return DecimalValue{Rat: new(big.Rat).SetInt(quotient)}, nil
+}
+
+// keeps the largest argument when keep is 1 and the smallest when it is -1, mixed numbers widen to a common type
+func (s *Executor) extreme(args []interface{}, keep int) (interface{}, error) {
+	var kept interface{}
+	var floats, decimals bool
+
+	for _, arg := range args {
+		value := s.comparisonValue(arg)
+		if value == nil {
+			continue
+		}
+
+		switch value.(type) {
+		case float64:
+			floats = true
+		case DecimalValue:
+			decimals = true
+		}
+
+		if kept == nil {
+			kept = value
+			continue
+		}
+
+		result, ok := compareValues(value, kept)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("cannot compare %s with %s", reflect.TypeOf(value), reflect.TypeOf(kept)))
+		}
+
+		if result == keep {
+			kept = value
+		}
+	}
+
+	switch {
+	case !isNumeric(kept):
+		return kept, nil
+	case floats:
+		return CastValue(kept, Double)
+	case decimals:
+		return CastValue(kept, Decimal)
+	}
+
+	return kept, nil
+}
+
+func abs(value int) int {
+	return tern(value < 0, -value, value)
+}
+
+// the whole number a rounded integer rat holds, as a json number so it normalizes to an int64 when it fits
+func jsonNumber(rat *big.Rat) json.Number {
+	return json.Number(rat.FloatString(0))
+}
@@ -0,0 +1,96 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"math"
+	"testing"
+)
+
+func TestMathFunctions(t *testing.T) {
+	cases := map[string]interface{}{
+		`abs(-5)`:                            int64(5),
+		`abs(-2.5)`:                          2.5,
+		`abs(- bar)`:                         int64(3),
+		`abs(cast('-1.50' as decimal))`:      "1.50",
+		`sign(-7)`:                           int64(-1),
+		`sign(0.5)`:                          int64(1),
+		`sign(0)`:                            int64(0),
+		`round(2.5)`:                         3.0,
+		`round(-2.5)`:                        -3.0,
+		`round(3.14159, 2)`:                  3.14,
+		`round(1234, -2)`:                    int64(1200),
+		`round(1250, -2)`:                    int64(1300),
+		`round(7)`:                           int64(7),
+		`round(cast('2.345' as decimal), 2)`: "2.35",
+		`floor(2.7)`:                         2.0,
+		`floor(-2.2)`:                        -3.0,
+		`ceil(2.2)`:                          3.0,
+		`floor(4)`:                           int64(4),
+		`floor(cast('-2.5' as decimal))`:     "-3",
+		`ceil(cast('2.1' as decimal))`:       "3",
+		`mod(7, 3)`:                          int64(1),
+		`mod(7.5, 2)`:                        1.5,
+		`sqrt(16)`:                           4.0,
+		`pow(2, 10)`:                         1024.0,
+		`power(4, 0.5)`:                      2.0,
+		`ln(1)`:                              0.0,
+		`log10(1000)`:                        3.0,
+		`exp(0)`:                             1.0,
+		`greatest(1, 5, 3)`:                  int64(5),
+		`least(1, 5, 3)`:                     int64(1),
+		`greatest(1, 2.5)`:                   2.5,
+		`greatest(3, 2.5)`:                   3.0,
+		`greatest(null, 2, null)`:            int64(2),
+		`least('b', 'a')`:                    "a",
+		`greatest(null, null)`:               nil,
+		`abs(null)`:                          nil,
+		`round(null, 2)`:                     nil,
+		`round(2.5, null)`:                   nil,
+		`abs(9223372036854775807)`:           int64(math.MaxInt64),
+	}
+
+	for expression, expect := range cases {
+		result, err := evalExpression(expression, input.DataRow{"bar": 3})
+		if err != nil {
+			t.Errorf("%s: %s", expression, err)
+			continue
+		}
+
+		if decimal, ok := result.(DecimalValue); ok {
+			result = decimal.String()
+		}
+
+		if result != expect {
+			t.Errorf("%s: expected %v (%T) got %v (%T)", expression, expect, expect, result, result)
+		}
+	}
+
+	for _, expression := range []string{`sqrt(-1)`, `ln(0)`, `mod(1, 0)`, `abs('a')`, `pow(0, -1)`, `greatest(1, 'a')`} {
+		if _, err := evalExpression(expression, input.DataRow{"bar": 3}); err == nil {
+			t.Errorf("expected %s to fail", expression)
+		}
+	}
+}
+
+func TestMathFunctionsAcceptNumericStringsWhenLenient(t *testing.T) {
+	query, err := Parse(`select round(value, 1) as rounded`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []input.DataRow{{"value": "2.25"}}
+
+	if _, err := NewExecutor(*query).QueryData(rows); err == nil {
+		t.Fail()
+	}
+
+	result, err := NewExecutor(*query, LenientTypes()).QueryData(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result[0]["rounded"] != 2.3 {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
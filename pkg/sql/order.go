@@ -0,0 +1,44 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"slices"
+)
+
+// sorts the rows the query selects by its order by, the rows are sorted the way a window's partition is so nulls sort
+// after everything else and rows that order the same keep their order
+func (s *Executor) orderRows(rows []input.DataRow) ([]input.DataRow, error) {
+	if len(s.sql.OrderBy) == 0 {
+		return rows, nil
+	}
+
+	p := &partition{rows: rows, indexes: make([]int, len(rows)), resolving: map[KeyAlias]bool{}}
+
+	// the columns are read from the selected rows rather than the fields of the query
+	if err := s.subExecutor(Query{}, nil).sortPartition(p, s.sql.OrderBy); err != nil {
+		return nil, err
+	}
+
+	return p.rows, nil
+}
+
+// the order by sorts the selected rows, so it can only read the columns the query selects
+func (s *Executor) validateOrderBy() error {
+	keys := outputKeys(s.sql)
+
+	for i := range s.sql.OrderBy {
+		expr := &s.sql.OrderBy[i].Expr
+
+		if err := s.validateExpr(expr, false); err != nil {
+			return err
+		}
+
+		if expr.Column != "" && keys != nil && !slices.Contains(keys, expr.Column) {
+			return errors.New(fmt.Sprintf("%s isn't selected so the query can't be ordered by it", expr.Column))
+		}
+	}
+
+	return nil
+}
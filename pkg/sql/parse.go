@@ -3,18 +3,108 @@ package sql
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 const (
-	sel   = "select"
-	where = "where"
+	with    = "with"
+	sel     = "select"
+	from    = "from"
+	join    = "join"
+	where   = "where"
+	groupBy = "group"
+	orderBy = "order"
+	union   = "union"
+	inter   = "intersect"
+	except  = "except"
 )
 
 func Parse(raw string) (*Query, error) {
-	stream := NewStreamTokenizer(Lex(raw))
+	tokens, err := LexTokens(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := NewStreamTokenizer(tokens)
+
+	query, err := parseQuery(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, err := stream.Peek(); err == nil {
+		return nil, errors.New(fmt.Sprintf("unexpected token '%s'", token))
+	}
+
+	return query, nil
+}
+
+func parseQuery(stream *streamTokenizer) (*Query, error) {
+	var commonTables []CommonTable
+
+	if next, _ := stream.Peek(); next == with {
+		var err error
+		if commonTables, err = parseWith(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	query, err := parseSelect(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	query.With = commonTables
+
+	for {
+		next, _ := stream.Peek()
+
+		operator := SetOperator(next)
+		if operator != Union && operator != Intersect && operator != Except {
+			break
+		}
+
+		stream.Consume()
+
+		set := SetOperation{Operator: operator}
+		if next, _ := stream.Peek(); next == "all" {
+			set.All = true
+			stream.Consume()
+		}
+
+		combined, err := parseSelect(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(combined.Fields) == 0 {
+			return nil, errors.New(fmt.Sprintf("expected a select after %s", operator))
+		}
+
+		set.Query = *combined
+		query.Sets = append(query.Sets, set)
+	}
+
+	// the order by comes after every set operation and sorts their combined rows
+	if next, _ := stream.Peek(); next == orderBy {
+		for _, keyword := range []string{orderBy, "by"} {
+			if err := expect(stream, keyword); err != nil {
+				return nil, err
+			}
+		}
+
+		if query.OrderBy, err = parseOrderBy(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
 
+// select ... from ... join ... where ... group by ...
+func parseSelect(stream *streamTokenizer) (*Query, error) {
 	fields, err := parseFields(stream)
 	if err != nil {
 		if errors.Is(err, eof) {
@@ -25,15 +115,246 @@ func Parse(raw string) (*Query, error) {
 		return nil, err
 	}
 
-	group, err := parseGroup(stream)
+	query := &Query{
+		Fields: fields,
+	}
+
+	if next, _ := stream.Peek(); next == from {
+		stream.Consume()
+
+		table, err := parseTable(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		query.From = &table
+
+		query.Joins, err = parseJoins(stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if next, _ := stream.Peek(); next == where {
+		_, err = stream.Consume()
+		if err != nil {
+			return nil, err
+		}
+
+		query.Group, err = parseGroup(stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if next, _ := stream.Peek(); next == groupBy {
+		for _, keyword := range []string{groupBy, "by"} {
+			if err := expect(stream, keyword); err != nil {
+				return nil, err
+			}
+		}
+
+		query.GroupBy, err = parseExprList(stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
+
+// parses comma separated expressions up to the next clause
+func parseExprList(stream *streamTokenizer) ([]Expr, error) {
+	var exprs []Expr
+
+	for {
+		expr, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		exprs = append(exprs, *expr)
+
+		if next, _ := stream.Peek(); next != "," {
+			return exprs, nil
+		}
+
+		_, err = stream.Consume()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// keywords that start a new clause and end the one before it
+func isClause(token string) bool {
+	switch token {
+	case from, join, string(InnerJoin), LeftJoin, RightJoin, FullJoin, CrossJoin, where, groupBy, union, inter, except, orderBy:
+		return true
+	}
+
+	return false
+}
+
+// a table name, a file when it is quoted or looks like a path, a subquery in brackets or unnest(array), optionally
+// lateral and followed by an alias
+func parseTable(stream *streamTokenizer) (Table, error) {
+	token, err := stream.ConsumeToken()
 	if err != nil {
-		return nil, err
+		return Table{}, err
 	}
 
-	return &Query{
-		Fields: fields,
-		Group:  group,
-	}, nil
+	lateral := token.Keyword() == "lateral"
+	if lateral {
+		if token, err = stream.ConsumeToken(); err != nil {
+			return Table{}, err
+		}
+	}
+
+	table := Table{Name: token.Value}
+
+	switch next, _ := stream.Peek(); {
+	case token.Value == "(" && !token.Quoted():
+		query, err := parseSubquery(stream)
+		if err != nil {
+			return Table{}, err
+		}
+
+		table = Table{Query: query}
+	case token.Keyword() == "unnest" && next == "(":
+		stream.Consume()
+
+		array, err := parseExpr(stream)
+		if err != nil {
+			return Table{}, err
+		}
+
+		if err := expect(stream, ")"); err != nil {
+			return Table{}, err
+		}
+
+		table = Table{Unnest: array}
+	case lateral:
+		return Table{}, errors.New("lateral needs a subquery or unnest")
+	case token.StringLiteral() || strings.ContainsAny(token.Value, "./"):
+		table = Table{File: token.Value}
+	}
+
+	table.Lateral = lateral
+
+	next, err := stream.PeekToken()
+	switch {
+	case err != nil:
+	case next.Keyword() == "as":
+		stream.Consume()
+
+		if table.Alias, err = stream.Consume(); err != nil {
+			return Table{}, err
+		}
+	case !next.StringLiteral() && !isClause(next.Keyword()) && !slices.Contains([]string{"on", "using", ")", ","}, next.Keyword()):
+		stream.Consume()
+		table.Alias = next.Value
+	}
+
+	if table.Query != nil && table.Alias == "" {
+		return Table{}, errors.New("a subquery in from needs an alias")
+	}
+
+	return table, nil
+}
+
+// any number of [inner|left|right|full] join table on ... or using (...), or cross join table
+func parseJoins(stream *streamTokenizer) ([]Join, error) {
+	var joins []Join
+
+	for {
+		next, _ := stream.Peek()
+
+		kind := InnerJoin
+		switch next {
+		case join:
+		case string(InnerJoin), LeftJoin, RightJoin, FullJoin, CrossJoin:
+			stream.Consume()
+			kind = JoinKind(next)
+
+			if outer, _ := stream.Peek(); outer == "outer" && kind != InnerJoin {
+				stream.Consume()
+			}
+		default:
+			return joins, nil
+		}
+
+		if err := expect(stream, join); err != nil {
+			return nil, err
+		}
+
+		table, err := parseTable(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		joined := Join{Kind: kind, Table: table}
+
+		if kind == CrossJoin {
+			joins = append(joins, joined)
+			continue
+		}
+
+		condition, err := stream.ConsumeKeyword()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("join %s needs an on or using clause", table))
+		}
+
+		switch condition {
+		case "on":
+			joined.On, err = parseGroup(stream)
+			if err != nil {
+				return nil, err
+			}
+
+			if joined.On == nil || len(joined.On.Predicate) == 0 {
+				return nil, errors.New("missing condition after on")
+			}
+		case "using":
+			if err := expect(stream, "("); err != nil {
+				return nil, err
+			}
+
+			for {
+				column, err := stream.Consume()
+				if err != nil {
+					return nil, err
+				}
+
+				joined.Using = append(joined.Using, column)
+
+				if next, _ := stream.Peek(); next != "," {
+					break
+				}
+
+				stream.Consume()
+			}
+
+			if err := expect(stream, ")"); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New(fmt.Sprintf("join %s needs an on or using clause", table))
+		}
+
+		joins = append(joins, joined)
+	}
+}
+
+// a predicate group ends at the end of the stream, a closing bracket, the next clause or the then of a case
+func atGroupEnd(stream *streamTokenizer) bool {
+	token, err := stream.Peek()
+
+	return err != nil || endsGroup(token)
+}
+
+func endsGroup(token string) bool {
+	return token == ")" || token == "then" || isClause(token)
 }
 
 func parseGroup(stream *streamTokenizer) (*PredicateGroup, error) {
@@ -51,7 +372,7 @@ func parseGroup(stream *streamTokenizer) (*PredicateGroup, error) {
 	}
 
 	// we've reached the end of a Group, bubble out
-	if token == ")" {
+	if endsGroup(token) {
 		return nil, nil
 	}
 
@@ -74,9 +395,12 @@ func parseGroup(stream *streamTokenizer) (*PredicateGroup, error) {
 	}
 
 	// do the next Leaf
-	leaf, err := parseLeaf(stream)
-	if err != nil && !errors.Is(err, eof) {
-		return nil, err
+	var leaf *Leaf
+	if !atGroupEnd(stream) {
+		leaf, err = parseLeaf(stream)
+		if err != nil && !errors.Is(err, eof) {
+			return nil, err
+		}
 	}
 
 	// if we didn't have an operator try again
@@ -97,9 +421,8 @@ func parseGroup(stream *streamTokenizer) (*PredicateGroup, error) {
 		predicates = append(predicates, NewLeaf(*leaf))
 	}
 
-	// if we're at eof return the tree we have
-	_, err = stream.Peek()
-	if errors.Is(err, eof) {
+	// if we're at the end of the group return the tree we have
+	if atGroupEnd(stream) {
 		return &PredicateGroup{
 			Operator:  *operator,
 			Predicate: predicates,
@@ -153,7 +476,7 @@ func nextOperator(stream *streamTokenizer, token string, err error) (*GroupingOp
 }
 
 func parenthesisGroup(stream *streamTokenizer, token string) (*PredicateGroup, error) {
-	token, err := stream.Consume()
+	token, err := stream.ConsumeKeyword()
 	if token != "(" {
 		return nil, errors.New("missing open parenthesis")
 	}
@@ -164,7 +487,7 @@ func parenthesisGroup(stream *streamTokenizer, token string) (*PredicateGroup, e
 
 	group, err := parseGroup(stream)
 
-	token, err = stream.Consume()
+	token, err = stream.ConsumeKeyword()
 	if err != nil {
 		return nil, err
 	}
@@ -177,27 +500,21 @@ func parenthesisGroup(stream *streamTokenizer, token string) (*PredicateGroup, e
 }
 
 func parseLeaf(stream *streamTokenizer) (*Leaf, error) {
-	field, err := stream.Consume()
+	left, err := parseExpr(stream)
 	if err != nil {
 		return nil, err
 	}
 
-	operator, err := stream.Consume()
-	if err != nil {
-		return nil, err
+	// a boolean expression on its own, like starts_with(name, 'a')
+	if next, _ := stream.Peek(); atGroupEnd(stream) || GroupingOperator(next) == And || GroupingOperator(next) == Or {
+		return &Leaf{Left: left}, nil
 	}
 
-	var value interface{}
-	value, err = stream.Consume()
+	operator, err := stream.ConsumeKeyword()
 	if err != nil {
 		return nil, err
 	}
 
-	float, err := TryToNumeric(value)
-	if err == nil {
-		value = float
-	}
-
 	switch ComparisonOperator(operator) {
 	case Eq:
 		fallthrough
@@ -216,125 +533,836 @@ func parseLeaf(stream *streamTokenizer) (*Leaf, error) {
 		return nil, errors.New(fmt.Sprintf("%s is not a valid Operator", operator))
 	}
 
-	return &Leaf{
-		Field:   field,
-		Value:   value,
-		Compare: ComparisonOperator(operator),
-	}, nil
-}
-
-func parseFields(stream *streamTokenizer) ([]Field, error) {
-	peek, err := stream.Consume()
-	if err != nil || peek != sel {
-		return nil, err
-	}
-
-	var fields []Field
-
-	for {
-		field, err := stream.Consume()
-
-		if field == where {
-			break
+	if ComparisonOperator(operator) == In {
+		var leaf *Leaf
+		if next, _ := stream.PeekToken(); isPlaceholder(next) {
+			// in ? is bound to an array
+			stream.Consume()
+			leaf = &Leaf{Compare: In, Right: stream.param(next)}
+		} else {
+			leaf, err = parseInList(stream)
 		}
 
 		if err != nil {
-			if errors.Is(err, eof) {
-				return fields, err
-			}
-
 			return nil, err
 		}
 
-		next, err := stream.Peek()
-		if err != nil {
-			return nil, err
+		leaf.Field = left.Column
+		if left.Column == "" {
+			leaf.Left = left
 		}
 
-		var alias KeyAlias
-		var function Function
-
-		switch next {
-		case "as":
-			alias, err = parseAlias(stream)
-			if err != nil {
-				return nil, err
-			}
-			// its actually a function
-		case "(":
-			function, err = parseFunction(stream)
-			if err != nil {
-				return nil, err
-			}
+		return leaf, nil
+	}
 
-			// a function has to be followed by an alias
-			alias, err = parseAlias(stream)
-			if err != nil {
-				return nil, err
-			}
-		}
+	quoted, _ := stream.PeekToken()
 
-		fieldName := strings.TrimRight(field, ",")
+	right, err := parseExpr(stream)
+	if err != nil {
+		return nil, err
+	}
 
-		fields = append(fields, Field{
-			Name:     fieldName,
-			Alias:    tern(alias != "", alias, KeyAlias(fieldName)),
-			Function: function,
-		})
+	leaf := &Leaf{
+		Field:   left.Column,
+		Compare: ComparisonOperator(operator),
 	}
 
-	return fields, nil
-}
+	if left.Column == "" {
+		leaf.Left = left
+	}
 
-func tern[T any](pred bool, left T, right T) T {
-	if pred {
-		return left
+	switch {
+	case right.Literal != nil:
+		leaf.Value = right.Literal.Value
+	case right.Column != "" && !strings.Contains(right.Column, ".") && quoted.Quote != '`':
+		// a bare or double quoted word on the right hand side is compared as a string, unless it is qualified like
+		// u.id or wrapped in backticks
+		leaf.Value = right.Column
+	default:
+		leaf.Right = right
 	}
 
-	return right
+	return leaf, nil
 }
 
-func parseAlias(stream *streamTokenizer) (KeyAlias, error) {
-	as, err := stream.Consume()
+// parses a scalar expression, binary operators are left associative and bind by precedence
+func parseExpr(stream *streamTokenizer) (*Expr, error) {
+	return parseBinary(stream, 1)
+}
+
+func parseBinary(stream *streamTokenizer, precedence int) (*Expr, error) {
+	left, err := parsePrimary(stream)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if as != "as" {
-		return "", errors.New("invalid alias token, expected 'as'")
-	}
+	for {
+		token, err := stream.PeekToken()
+		if err != nil {
+			return left, nil
+		}
 
-	alias, err := stream.Consume()
-	if err != nil {
-		return "", err
-	}
+		operatorPrecedence, ok := binaryPrecedence[token.Value]
+		if token.Quoted() || !ok || operatorPrecedence < precedence {
+			return left, nil
+		}
+
+		_, err = stream.Consume()
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := parseBinary(stream, operatorPrecedence+1)
+		if err != nil {
+			return nil, err
+		}
 
-	return KeyAlias(strings.TrimRight(alias, ",")), nil
+		left = &Expr{Binary: &Binary{Operator: token.Value, Left: *left, Right: *right}}
+	}
 }
 
-func parseFunction(stream *streamTokenizer) (Function, error) {
-	_, err := stream.Consume()
+func parsePrimary(stream *streamTokenizer) (*Expr, error) {
+	token, err := stream.ConsumeToken()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	function, err := stream.Consume()
-	if err != nil {
-		return "", err
+	switch {
+	case isPlaceholder(token):
+		return stream.param(token), nil
+	case token.StringLiteral():
+		return NewLiteral(token.Value), nil
+	case token.Quoted():
+		// a quoted identifier is always a column, even when it looks like a keyword or a number
+		return NewColumn(token.Value), nil
 	}
 
-	closeBracket, err := stream.Consume()
+	keyword := token.Keyword()
+	next, _ := stream.Peek()
+
+	switch {
+	case keyword == "(" && (next == sel || next == with):
+		query, err := parseSubquery(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expr{Subquery: query}, nil
+	case keyword == "exists" && next == "(":
+		stream.Consume()
+
+		query, err := parseSubquery(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expr{Exists: query}, nil
+	case keyword == "(":
+		inner, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, expect(stream, ")")
+	case next == "(" && (keyword == "cast" || keyword == "try_cast"):
+		return parseCast(stream, keyword == "try_cast")
+	case next == "(" && keyword == "extract":
+		return parseExtract(stream)
+	case strings.HasPrefix(next, "'") && (keyword == "timestamp" || keyword == "interval"):
+		return parseTypedLiteral(stream, Type(keyword))
+	case keyword == "-":
+		// a negated expression is subtracted from zero so it keeps its type
+		operand, err := parsePrimary(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expr{Binary: &Binary{Operator: "-", Left: *NewLiteral(int64(0)), Right: *operand}}, nil
+	case next == "(":
+		return parseCall(stream, token.Value)
+	case keyword == "case":
+		return parseCase(stream)
+	case keyword == "true" || keyword == "false":
+		return NewLiteral(keyword == "true"), nil
+	case keyword == "null":
+		return NewLiteral(nil), nil
+	}
+
+	if number, err := parseNumber(token.Value); err == nil {
+		return NewLiteral(number), nil
+	}
+
+	return NewColumn(token.Value), nil
+}
+
+// with [recursive] name (columns) as (select ...), ... before a query. The query of a recursive table can end with a
+// union of a query that reads the table
+func parseWith(stream *streamTokenizer) ([]CommonTable, error) {
+	if err := expect(stream, with); err != nil {
+		return nil, err
+	}
+
+	recursive := false
+	if next, _ := stream.Peek(); next == "recursive" {
+		recursive = true
+		stream.Consume()
+	}
+
+	var tables []CommonTable
+
+	for {
+		name, err := stream.Consume()
+		if err != nil {
+			return nil, err
+		}
+
+		table := CommonTable{Name: name}
+
+		if next, _ := stream.Peek(); next == "(" {
+			stream.Consume()
+
+			for {
+				column, err := stream.Consume()
+				if err != nil {
+					return nil, err
+				}
+
+				table.Columns = append(table.Columns, column)
+
+				if next, _ := stream.Peek(); next != "," {
+					break
+				}
+
+				stream.Consume()
+			}
+
+			if err := expect(stream, ")"); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, expected := range []string{"as", "("} {
+			if err := expect(stream, expected); err != nil {
+				return nil, err
+			}
+		}
+
+		query, err := parseQuery(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		table.Query = *query
+
+		// the last union of a recursive table is its recursive term when it reads the table
+		if last := len(query.Sets) - 1; recursive && last >= 0 && query.Sets[last].Operator == Union && readsTable(&query.Sets[last].Query, name) {
+			table.Recursive, table.All = &query.Sets[last].Query, query.Sets[last].All
+			table.Query.Sets = tern(last == 0, nil, query.Sets[:last])
+		}
+
+		if err := expect(stream, ")"); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+
+		if next, _ := stream.Peek(); next != "," {
+			return tables, nil
+		}
+
+		stream.Consume()
+	}
+}
+
+// a query in brackets with the opening bracket already consumed
+func parseSubquery(stream *streamTokenizer) (*Query, error) {
+	if next, _ := stream.Peek(); next != sel && next != with {
+		return nil, errors.New(fmt.Sprintf("expected '%s' but found '%s'", sel, next))
+	}
+
+	query, err := parseQuery(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if next, err := stream.Peek(); err != nil || next != ")" {
+		return nil, errors.New("missing closing bracket after subquery")
+	}
+
+	stream.Consume()
+
+	return query, nil
+}
+
+// in (1, 2, 3) compares with each literal, in (select ...) with each row of the query
+func parseInList(stream *streamTokenizer) (*Leaf, error) {
+	if err := expect(stream, "("); err != nil {
+		return nil, err
+	}
+
+	if next, _ := stream.Peek(); next == sel || next == with {
+		query, err := parseSubquery(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Leaf{Compare: In, Right: &Expr{Subquery: query}}, nil
+	}
+
+	var values []interface{}
+
+	for {
+		expr, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case expr.Literal != nil:
+			values = append(values, expr.Literal.Value)
+		case expr.Param != nil:
+			// bound when the query runs, like in (?, ?)
+			values = append(values, *expr.Param)
+		default:
+			return nil, errors.New(fmt.Sprintf("in lists can only hold literals and placeholders, found %s", expr))
+		}
+
+		next, err := stream.ConsumeKeyword()
+		if err != nil {
+			return nil, errors.New("missing closing bracket after in list")
+		}
+
+		switch next {
+		case ")":
+			return &Leaf{Compare: In, Value: values}, nil
+		case ",":
+		default:
+			return nil, errors.New(fmt.Sprintf("expected ',' or ')' but found '%s'", next))
+		}
+	}
+}
+
+func parseCall(stream *streamTokenizer, name string) (*Expr, error) {
+	if err := expect(stream, "("); err != nil {
+		return nil, err
+	}
+
+	call := &Call{Name: name, Args: []Expr{}}
+
+	for {
+		next, err := stream.Peek()
+		if err != nil {
+			return nil, err
+		}
+
+		if next == ")" {
+			if _, err = stream.Consume(); err != nil {
+				return nil, err
+			}
+
+			if next, _ := stream.Peek(); next == "within" {
+				call.WithinGroup, err = parseWithinGroup(stream)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if next, _ := stream.Peek(); next == "over" {
+				call.Over, err = parseWindow(stream)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return &Expr{Call: call}, nil
+		}
+
+		if len(call.Args) > 0 {
+			if err := expect(stream, ","); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		call.Args = append(call.Args, *arg)
+	}
+}
+
+// case when x > 1 then 'a' else 'b' end, or case x when 1 then 'a' end, with the case already consumed
+func parseCase(stream *streamTokenizer) (*Expr, error) {
+	c := &Case{}
+
+	if next, _ := stream.Peek(); next != "when" {
+		operand, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Operand = operand
+	}
+
+	for {
+		if next, _ := stream.Peek(); next != "when" {
+			break
+		}
+
+		stream.Consume()
+
+		var when When
+
+		if c.Operand != nil {
+			value, err := parseExpr(stream)
+			if err != nil {
+				return nil, err
+			}
+
+			when.Value = value
+		} else {
+			condition, err := parseGroup(stream)
+			if err != nil {
+				return nil, err
+			}
+
+			if condition == nil || len(condition.Predicate) == 0 {
+				return nil, errors.New("missing condition after when")
+			}
+
+			when.Condition = condition
+		}
+
+		if err := expect(stream, "then"); err != nil {
+			return nil, err
+		}
+
+		then, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		when.Then = *then
+		c.Whens = append(c.Whens, when)
+	}
+
+	if len(c.Whens) == 0 {
+		return nil, errors.New("case needs at least one when")
+	}
+
+	if next, _ := stream.Peek(); next == "else" {
+		stream.Consume()
+
+		otherwise, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Else = otherwise
+	}
+
+	return &Expr{Case: c}, expect(stream, "end")
+}
+
+// within group (order by x desc) after an ordered set aggregate
+func parseWithinGroup(stream *streamTokenizer) ([]OrderBy, error) {
+	for _, expected := range []string{"within", "group", "(", "order", "by"} {
+		if err := expect(stream, expected); err != nil {
+			return nil, err
+		}
+	}
+
+	orderBy, err := parseOrderBy(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderBy, expect(stream, ")")
+}
+
+// over (partition by a, b order by c desc rows between 5 preceding and current row)
+func parseWindow(stream *streamTokenizer) (*Window, error) {
+	for _, expected := range []string{"over", "("} {
+		if err := expect(stream, expected); err != nil {
+			return nil, err
+		}
+	}
+
+	window := &Window{}
+
+	if next, _ := stream.Peek(); next == "partition" {
+		for _, expected := range []string{"partition", "by"} {
+			if err := expect(stream, expected); err != nil {
+				return nil, err
+			}
+		}
+
+		partitionBy, err := parseExprList(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		window.PartitionBy = partitionBy
+	}
+
+	if next, _ := stream.Peek(); next == "order" {
+		for _, expected := range []string{"order", "by"} {
+			if err := expect(stream, expected); err != nil {
+				return nil, err
+			}
+		}
+
+		orderBy, err := parseOrderBy(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		window.OrderBy = orderBy
+	}
+
+	if next, _ := stream.Peek(); next == string(Rows) || next == Range {
+		frame, err := parseFrame(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		window.Frame = frame
+	}
+
+	return window, expect(stream, ")")
+}
+
+// rows between <bound> and <bound>, or rows <bound> which ends at the current row
+func parseFrame(stream *streamTokenizer) (*Frame, error) {
+	unit, err := stream.ConsumeKeyword()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := &Frame{Unit: FrameUnit(unit), End: FrameBound{Kind: CurrentRow}}
+
+	between := false
+	if next, _ := stream.Peek(); next == "between" {
+		between = true
+		stream.Consume()
+	}
+
+	if frame.Start, err = parseFrameBound(stream); err != nil {
+		return nil, err
+	}
+
+	if between {
+		if err := expect(stream, "and"); err != nil {
+			return nil, err
+		}
+
+		if frame.End, err = parseFrameBound(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	return frame, nil
+}
+
+// unbounded preceding, 5 preceding, current row, 5 following or unbounded following
+func parseFrameBound(stream *streamTokenizer) (FrameBound, error) {
+	first, err := stream.ConsumeKeyword()
+	if err != nil {
+		return FrameBound{}, err
+	}
+
+	second, err := stream.ConsumeKeyword()
+	if err != nil {
+		return FrameBound{}, err
+	}
+
+	bound := BoundKind(first + " " + second)
+	switch bound {
+	case UnboundedPreceding, CurrentRow, UnboundedFollowing:
+		return FrameBound{Kind: bound}, nil
+	}
+
+	offset, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 || (second != Preceding && second != Following) {
+		return FrameBound{}, errors.New(fmt.Sprintf("%s is not a valid frame bound", bound))
+	}
+
+	return FrameBound{Kind: BoundKind(second), Offset: offset}, nil
+}
+
+// a comma separated list of expressions, each optionally followed by asc or desc
+func parseOrderBy(stream *streamTokenizer) ([]OrderBy, error) {
+	var orderBy []OrderBy
+
+	for {
+		expr, err := parseExpr(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		order := OrderBy{Expr: *expr}
+
+		if next, _ := stream.Peek(); next == "asc" || next == "desc" {
+			order.Desc = next == "desc"
+			stream.Consume()
+		}
+
+		orderBy = append(orderBy, order)
+
+		if next, err := stream.PeekToken(); err != nil || next.Quoted() || next.Value != "," {
+			return orderBy, nil
+		}
+
+		stream.Consume()
+	}
+}
+
+// extract(part from ts) is date_part('part', ts)
+func parseExtract(stream *streamTokenizer) (*Expr, error) {
+	if err := expect(stream, "("); err != nil {
+		return nil, err
+	}
+
+	part, err := stream.Consume()
+	if err != nil {
+		return nil, err
+	}
+
+	part = strings.ToLower(part)
+
+	if err := expect(stream, "from"); err != nil {
+		return nil, err
+	}
+
+	ts, err := parseExpr(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expect(stream, ")"); err != nil {
+		return nil, err
+	}
+
+	return &Expr{Call: &Call{Name: "date_part", Args: []Expr{*NewLiteral(part), *ts}}}, nil
+}
+
+// timestamp '2024-01-01T00:00:00Z' and interval '1 hour' are parsed up front so bad literals fail the parse
+func parseTypedLiteral(stream *streamTokenizer, to Type) (*Expr, error) {
+	raw, err := stream.Consume()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := CastValue(raw, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLiteral(value), nil
+}
+
+// bare whole numbers are bigints so large ids keep their precision, anything else numeric is a double
+func parseNumber(raw string) (interface{}, error) {
+	if integer, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return integer, nil
+	}
+
+	// a whole number too large for a bigint keeps its digits as a decimal rather than rounding to a double
+	if !strings.ContainsAny(raw, ".eE") {
+		if decimal, err := parseDecimal(raw); err == nil {
+			return decimal, nil
+		}
+	}
+
+	return strconv.ParseFloat(raw, 64)
+}
+
+func parseCast(stream *streamTokenizer, try bool) (*Expr, error) {
+	if err := expect(stream, "("); err != nil {
+		return nil, err
+	}
+
+	inner, err := parseExpr(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expect(stream, "as"); err != nil {
+		return nil, err
+	}
+
+	name, err := stream.Consume()
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := ParseType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cast := &Cast{Expr: *inner, Type: to, Try: try}
+
+	// decimal(precision, scale)
+	if next, _ := stream.Peek(); next == "(" {
+		precision, scale, err := parseTypeArguments(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		cast.Precision = &precision
+		cast.Scale = &scale
+	}
+
+	if err := expect(stream, ")"); err != nil {
+		return nil, err
+	}
+
+	return &Expr{Cast: cast}, nil
+}
+
+func parseTypeArguments(stream *streamTokenizer) (int, int, error) {
+	var arguments []int
+
+	for _, token := range []string{"(", "", ",", "", ")"} {
+		if token != "" {
+			if err := expect(stream, token); err != nil {
+				return 0, 0, err
+			}
+
+			continue
+		}
+
+		raw, err := stream.Consume()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		argument, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, 0, errors.New(fmt.Sprintf("%s is not a valid type argument", raw))
+		}
+
+		arguments = append(arguments, argument)
+	}
+
+	return arguments[0], arguments[1], nil
+}
+
+// consumes the next token and errors if it isn't the expected one
+func expect(stream *streamTokenizer, expected string) error {
+	token, err := stream.ConsumeKeyword()
+	if err != nil {
+		return err
+	}
+
+	if token != expected {
+		return errors.New(fmt.Sprintf("expected '%s' but found '%s'", expected, token))
+	}
+
+	return nil
+}
+
+func parseFields(stream *streamTokenizer) ([]Field, error) {
+	peek, err := stream.ConsumeKeyword()
+	if err != nil || peek != sel {
+		return nil, err
+	}
+
+	var fields []Field
+
+	for {
+		next, err := stream.Peek()
+		if err != nil {
+			return fields, err
+		}
+
+		// a subquery's fields can end at its closing bracket
+		if isClause(next) || next == ")" {
+			return fields, nil
+		}
+
+		if next == "," {
+			_, err = stream.Consume()
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		field, err := parseField(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, *field)
+	}
+}
+
+func parseField(stream *streamTokenizer) (*Field, error) {
+	expr, err := parseExpr(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	field := Field{
+		Alias: KeyAlias(tern(expr.Column != "", expr.Column, expr.String())),
+	}
+
+	if expr.Column != "" {
+		field.Name = expr.Column
+	} else {
+		field.Expr = expr
+	}
+
+	if next, _ := stream.Peek(); next == "as" {
+		field.Alias, err = parseAlias(stream)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &field, nil
+}
+
+func tern[T any](pred bool, left T, right T) T {
+	if pred {
+		return left
+	}
+
+	return right
+}
+
+func parseAlias(stream *streamTokenizer) (KeyAlias, error) {
+	as, err := stream.ConsumeKeyword()
 	if err != nil {
 		return "", err
 	}
 
-	if closeBracket != ")" {
-		return "", errors.New("unclosed bracket after function definition")
+	if as != "as" {
+		return "", errors.New("invalid alias token, expected 'as'")
+	}
+
+	alias, err := stream.Consume()
+	if err != nil {
+		return "", err
 	}
 
-	return function, nil
+	return KeyAlias(alias), nil
 }
 
 func TryToNumeric(value interface{}) (float64, error) {
-	return strconv.ParseFloat(fmt.Sprintf("%v", value), 64)
+	number, err := toNumber(value)
+	if err != nil {
+		return 0, err
+	}
+
+	double, err := CastValue(number, Double)
+	if err != nil {
+		return 0, err
+	}
+
+	return double.(float64), nil
 }
@@ -2,9 +2,11 @@ package sql
 
 import (
 	"encoding/json"
+	"example/pkg/input"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParsesInline(t *testing.T) {
@@ -30,7 +32,7 @@ func TestParsesInline(t *testing.T) {
 		t.Fail()
 	}
 
-	if result.Group.Predicate[0].Leaf.Value != float64(2) {
+	if result.Group.Predicate[0].Leaf.Value != int64(2) {
 		t.Fail()
 	}
 
@@ -42,7 +44,7 @@ func TestParsesInline(t *testing.T) {
 		t.Fail()
 	}
 
-	if result.Group.Predicate[1].Leaf.Value != float64(3) {
+	if result.Group.Predicate[1].Leaf.Value != int64(3) {
 		t.Fail()
 	}
 }
@@ -116,7 +118,7 @@ func TestParses(t *testing.T) {
 		t.Fail()
 	}
 
-	if result.Group.Predicate[0].Leaf.Value != float64(2) {
+	if result.Group.Predicate[0].Leaf.Value != int64(2) {
 		t.Fail()
 	}
 
@@ -132,7 +134,9 @@ func TestParsesWithFunctions(t *testing.T) {
 		t.Fail()
 	}
 
-	if result.Fields[0].Function != Average && result.Fields[0].Alias != "fooavg" && result.Fields[0].Name != "foo" {
+	expected := Field{Alias: "fooavg", Expr: &Expr{Call: &Call{Name: "average", Args: []Expr{{Column: "foo"}}}}}
+	if !reflect.DeepEqual(result.Fields[0], expected) {
+		t.Logf("%s", toJson(result.Fields[0], t))
 		t.Fail()
 	}
 }
@@ -159,7 +163,7 @@ func TestParsesWithOperator(t *testing.T) {
 		t.Fail()
 	}
 
-	if result.Group.Predicate[0].Leaf.Value != float64(2) {
+	if result.Group.Predicate[0].Leaf.Value != int64(2) {
 		t.Fail()
 	}
 
@@ -171,7 +175,7 @@ func TestParsesWithOperator(t *testing.T) {
 		t.Fail()
 	}
 
-	if result.Group.Predicate[1].Leaf.Value != float64(3) {
+	if result.Group.Predicate[1].Leaf.Value != int64(3) {
 		t.Fail()
 	}
 }
@@ -218,3 +222,439 @@ func toJson[T any](data T, t *testing.T) string {
 	}
 	return string(rJson)
 }
+
+func TestParsesQuotedValuesAsStrings(t *testing.T) {
+	result, err := Parse(`select id where id = "007" and x = 7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Group.Predicate[0].Leaf.Value != "007" {
+		t.Fail()
+	}
+
+	if result.Group.Predicate[1].Leaf.Value != int64(7) {
+		t.Fail()
+	}
+}
+
+func TestParsesLargeIntegersWithoutLosingPrecision(t *testing.T) {
+	result, err := Parse(`select id where id = 1234567890123456789`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Group.Predicate[0].Leaf.Value != int64(1234567890123456789) {
+		t.Fail()
+	}
+}
+
+func TestParsesCast(t *testing.T) {
+	result, err := Parse(`select cast(id as varchar) as sid, try_cast(price as decimal(10, 2)) where cast(id as bigint) = 7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Alias != "sid" || result.Fields[0].Expr.Cast.Type != Varchar || result.Fields[0].Expr.Cast.Expr.Column != "id" {
+		t.Fail()
+	}
+
+	if result.Fields[1].Alias != "try_cast(price as decimal(10, 2))" || !result.Fields[1].Expr.Cast.Try || *result.Fields[1].Expr.Cast.Scale != 2 {
+		t.Logf("%s", toJson(result.Fields[1], t))
+		t.Fail()
+	}
+
+	leaf := result.Group.Predicate[0].Leaf
+	if leaf.Left.Cast.Type != BigInt || leaf.Left.Cast.Expr.Column != "id" || leaf.Value != int64(7) {
+		t.Logf("%s", toJson(leaf, t))
+		t.Fail()
+	}
+}
+
+func TestParsesInvalidCastType(t *testing.T) {
+	_, err := Parse(`select cast(id as potato)`)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestParsesTimeExpressions(t *testing.T) {
+	result, err := Parse(`select extract(hour from ts) as h, date_trunc('day', ts) where ts > now() - interval '1 hour' and ts < timestamp '2024-01-01T00:00:00Z'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Expr.String() != "extract(hour from ts)" {
+		t.Error(result.Fields[0].Expr.String())
+	}
+
+	if result.Fields[1].Alias != "date_trunc('day', ts)" {
+		t.Error(result.Fields[1].Alias)
+	}
+
+	if result.Group.Predicate[0].Leaf.Right.String() != "now() - interval '1 hour'" {
+		t.Error(result.Group.Predicate[0].Leaf.Right.String())
+	}
+
+	if !result.Group.Predicate[1].Leaf.Value.(time.Time).Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fail()
+	}
+
+	if _, err := Parse(`select * where ts > timestamp 'yesterday'`); err == nil {
+		t.Fail()
+	}
+}
+
+func TestParsesArithmeticPrecedence(t *testing.T) {
+	result, err := Parse(`select a + b * c - d as x, (a + b) * c as y, a - (b - c) as z`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expect := range []string{"a + b * c - d", "(a + b) * c", "a - (b - c)"} {
+		if result.Fields[i].Expr.String() != expect {
+			t.Errorf("expected %s got %s", expect, result.Fields[i].Expr.String())
+		}
+	}
+}
+
+func TestParsesGroupBy(t *testing.T) {
+	result, err := Parse(`select time_bucket('5 minutes', ts) as bucket, count(*) as n where status >= 500 group by bucket, host`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.GroupBy) != 2 || result.GroupBy[0].Column != "bucket" || result.GroupBy[1].Column != "host" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	if result.Group.Predicate[0].Leaf.Field != "status" {
+		t.Fail()
+	}
+
+	if result.Fields[1].Expr.Call.Name != "count" || result.Fields[1].Expr.Call.Args[0].Column != "*" {
+		t.Fail()
+	}
+
+	result, err = Parse(`select host, count(*) group by host`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Group != nil || result.GroupBy[0].Column != "host" || result.Fields[1].Alias != "count(*)" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	if _, err := Parse(`select host group host`); err == nil {
+		t.Fail()
+	}
+}
+
+func TestParsesNestedFunctions(t *testing.T) {
+	result, err := Parse(`select lower(trim(name)) as n, first || ' ' || last as full where starts_with(lower(name), 'a') and length(name) > 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Expr.String() != "lower(trim(name))" || result.Fields[1].Expr.String() != "first || ' ' || last" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	if result.Group.Predicate[0].Leaf.Left.String() != "starts_with(lower(name), 'a')" || result.Group.Predicate[0].Leaf.Compare != "" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	if _, err := Parse(`select * where x potato 3`); err == nil {
+		t.Fail()
+	}
+}
+
+func TestParsesNegation(t *testing.T) {
+	result, err := Parse(`select abs(- bar) as a, -2 as b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Expr.String() != "abs(0 - bar)" || result.Fields[1].Expr.Literal.Value != int64(-2) {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	// a minus written against a name or a bracket negates it, one inside a name is part of it
+	result, err = Parse(`select -age as n, -(a + b) as m, user-agent, x -y as d from t where -x > 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"0 - age", "0 - (a + b)", "", "x - y"} {
+		if field := result.Fields[i]; field.Expr != nil && field.Expr.String() != expected || field.Expr == nil && field.Name != "user-agent" {
+			t.Errorf("expected %s got %s", expected, field)
+		}
+	}
+
+	if leaf := result.Group.Predicate[0].Leaf; leaf.Left == nil || leaf.Left.String() != "0 - x" || leaf.Value != int64(1) {
+		t.Errorf("expected -x > 1 got %s", leaf)
+	}
+
+	rows, err := NewExecutor(*result, WithTable("t", []input.DataRow{{"age": 3, "a": 1, "b": 2, "x": -5, "y": 1, "user-agent": "go"}, {"x": 5}})).QueryData(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []input.DataRow{{"n": int64(-3), "m": int64(-3), "user-agent": "go", "d": int64(-6)}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v got %v", expected, rows)
+	}
+}
+
+func TestParsesJoins(t *testing.T) {
+	result, err := Parse(`select e.id, u.name from events e left outer join users.ndjson as u on e.user_id = u.id and u.active = true join 'hosts.csv' h using (host, dc) where u.name = 'ann'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *result.From != (Table{Name: "events", Alias: "e"}) || len(result.Joins) != 2 {
+		t.Logf("%s", toJson(result, t))
+		t.FailNow()
+	}
+
+	if result.Joins[0].Kind != LeftJoin || result.Joins[0].Table != (Table{File: "users.ndjson", Alias: "u"}) || result.Joins[0].String() != "left join 'users.ndjson' u on e.user_id = u.id and u.active = true" {
+		t.Logf("%s", result.Joins[0])
+		t.Fail()
+	}
+
+	if result.Joins[1].Kind != InnerJoin || result.Joins[1].Table.qualifier() != "h" || !reflect.DeepEqual(result.Joins[1].Using, []string{"host", "dc"}) {
+		t.Logf("%s", result.Joins[1])
+		t.Fail()
+	}
+
+	if result.Group.Predicate[0].Leaf.Value != "ann" {
+		t.Logf("%s", toJson(result.Group, t))
+		t.Fail()
+	}
+
+	// an unnest or a lateral subquery reads the rows it is joined to
+	result, err = Parse("select id, tag from logs l cross join unnest(l.tags) tag cross join lateral (select count(*) as n from events e where e.log_id = l.id) t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Joins[0].Table.Unnest == nil || result.Joins[0].Table.Unnest.Column != "l.tags" || !result.Joins[1].Table.Lateral || result.Joins[1].Table.Query == nil {
+		t.Logf("%s", toJson(result.Joins, t))
+		t.Fail()
+	}
+}
+
+func TestParsesSubqueries(t *testing.T) {
+	result, err := Parse("select ms - (select avg(ms) from logs) as delta from logs l where user_id in (select id from admins) and exists (select id from admins a where a.id = l.user_id) and id in (1, 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Expr.Binary == nil || result.Fields[0].Expr.Binary.Right.Subquery == nil {
+		t.Errorf("expected a scalar subquery got %s", toJson(result.Fields[0], t))
+	}
+
+	in := result.Group.Predicate[0].Leaf
+	if in.Compare != In || in.Right == nil || in.Right.Subquery == nil || in.Right.Subquery.From.Name != "admins" {
+		t.Errorf("expected an in subquery got %s", toJson(in, t))
+	}
+
+	exists := result.Group.Predicate[1].Group.Predicate[0].Leaf
+	if exists.Left == nil || exists.Left.Exists == nil {
+		t.Errorf("expected exists got %s", toJson(exists, t))
+	}
+
+	list := result.Group.Predicate[1].Group.Predicate[1].Leaf
+	if !reflect.DeepEqual(list.Value, []interface{}{int64(1), "x"}) {
+		t.Errorf("expected an in list got %s", toJson(list, t))
+	}
+}
+
+func TestParsesCommonTables(t *testing.T) {
+	result, err := Parse("with recursive a (x) as (select 1 as n union select x + 1 from a), b as (select x from a) select x from b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.With) != 2 || result.With[0].Name != "a" || !reflect.DeepEqual(result.With[0].Columns, []string{"x"}) {
+		t.Logf("%s", toJson(result.With, t))
+		t.FailNow()
+	}
+
+	// the union of a recursive table is its recursive term rather than a set operation
+	if result.With[0].Recursive == nil || result.With[0].All || len(result.With[0].Query.Sets) != 0 || result.With[1].Recursive != nil {
+		t.Logf("%s", toJson(result.With, t))
+		t.Fail()
+	}
+}
+
+func TestParsesSetOperationsAndOrderBy(t *testing.T) {
+	result, err := Parse("select host from logs group by host union all select host from logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Sets) != 1 || result.Sets[0].Operator != Union || !result.Sets[0].All || len(result.GroupBy) != 1 {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	result, err = Parse("select host, ms from logs where ms > 10 ORDER BY host DESC, ms * 2 asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []OrderBy{{Expr: *NewColumn("host"), Desc: true}, {Expr: Expr{Binary: &Binary{Operator: "*", Left: *NewColumn("ms"), Right: *NewLiteral(int64(2))}}}}
+	if !reflect.DeepEqual(result.OrderBy, expected) {
+		t.Errorf("expected %v got %v", expected, result.OrderBy)
+	}
+
+	// the order by of a combined query sorts all of it
+	result, err = Parse("select host from logs union select host from old order by host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.OrderBy) != 1 || len(result.Sets[0].Query.OrderBy) != 0 {
+		t.Errorf("expected the order by on the outer query but got %s", toJson(result, t))
+	}
+}
+
+func TestParsesPlaceholders(t *testing.T) {
+	statement, err := Prepare("select id, ms * ? as scaled from logs where (status = ? or ms > $min) and user = $user and id in $ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Param{{Position: 1}, {Position: 2}, {Name: "min"}, {Name: "user"}, {Name: "ids"}}
+	if !reflect.DeepEqual(statement.Params(), expected) {
+		t.Errorf("expected %v got %v", expected, statement.Params())
+	}
+
+	// a name used twice is one parameter
+	statement, err = Prepare("select id from logs where ms in (?, $max) or id in (?, 1) or ms < $max")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []Param{{Position: 1}, {Name: "max"}, {Position: 2}}
+	if !reflect.DeepEqual(statement.Params(), expected) {
+		t.Errorf("expected %v got %v", expected, statement.Params())
+	}
+
+	// quoted they are a column and a string
+	result, err := Parse("select `$user` where x = '?'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Name != "$user" || result.Group.Predicate[0].Leaf.Value != "?" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+}
+
+func TestParsesKeywordsInAnyCase(t *testing.T) {
+	result, err := Parse("SELECT Name, COUNT(*) AS n FROM logs L INNER JOIN users U ON L.id = U.id WHERE id > 1 AND (id < 5 OR Name IN ('a', 'b')) GROUP BY Name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "select Name, COUNT(*) as n from logs L inner join users U on L.id = U.id where id > 1 and (id < 5 or Name in ('a', 'b')) group by Name"
+	if result.String() != expected {
+		t.Errorf("expected %s got %s", expected, result)
+	}
+}
+
+func TestParsesQuotedIdentifiers(t *testing.T) {
+	for _, test := range []struct {
+		sql      string
+		expected string
+	}{
+		{`select "from", "select" as s from logs`, "select `from`, `select` as s from logs"},
+		{"select upper(`from`) as `the source` from `my logs` `l`", "select upper(`from`) as `the source` from `my logs` l"},
+		{"select id from logs where Name = 'it''s'", "select id from logs where Name = 'it''s'"},
+	} {
+		result, err := Parse(test.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.String() != test.expected {
+			t.Errorf("expected %s got %s", test.expected, result)
+		}
+	}
+
+	// a backtick on the right compares with a column, a double quoted word is still a string
+	result, err := Parse("select id where `status code` = `id` and x = \"id\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Group.Predicate[0].Leaf.Right == nil || result.Group.Predicate[1].Leaf.Value != "id" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+}
+
+func TestParsesEscapesAndComments(t *testing.T) {
+	result, err := Parse(`
+		-- requests with a quote in the path
+		select path /* , ms */
+		from logs
+		where path = 'a\\b\'c' or path = "say \"hi\"" -- either
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `select path from logs where path = 'a\\b''c' or path = 'say "hi"'`
+	if result.String() != expected {
+		t.Errorf("expected %s got %s", expected, result)
+	}
+
+	if result.Group.Predicate[0].Leaf.Value != `a\b'c` || result.Group.Predicate[1].Leaf.Value != `say "hi"` {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+}
+
+func TestParsesInvalidQueries(t *testing.T) {
+	for _, sql := range []string{
+		// joins
+		`select * from events e join users u`,
+		`select * from events e join users u on`,
+		`select * from events e join users u using (id`,
+		`select * from events e left users u on e.id = u.id`,
+		"select id from logs cross join lateral users u",
+		"select id from logs cross join unnest(tags",
+		"select id from logs join unnest(tags) t",
+		// subqueries
+		"select x from (select 1 as y)",
+		"select x where x in (select y",
+		"select x where x in (y, 2)",
+		"select x where x in (1 2)",
+		"select x where x in ()",
+		"select x where exists (1)",
+		// common tables
+		"with a as select 1 as n select n from a",
+		"with a (x as (select 1 as n) select x from a",
+		"with a as (select 1 as n union) select n from a",
+		"with a as (select 1 as n select n from a",
+		// set operations and order by
+		"select host from logs union",
+		"select host from logs union all",
+		"select host from logs intersect host",
+		"select host from logs order by",
+		"select host from logs order host",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
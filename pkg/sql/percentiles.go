@@ -0,0 +1,145 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+func init() {
+	aggregateFunctions["percentile_cont"] = builtinAggregate{params: 1, orderedSet: true, init: newPercentileCont}
+	aggregateFunctions["percentile_disc"] = builtinAggregate{params: 1, orderedSet: true, init: newPercentileDisc}
+	aggregateFunctions["median"] = builtinAggregate{
+		orderedSet: true,
+		init: func(params []interface{}) (Aggregate, error) {
+			return newPercentileCont([]interface{}{0.5})
+		},
+	}
+	aggregateFunctions["mode"] = builtinAggregate{
+		orderedSet: true,
+		init: func(params []interface{}) (Aggregate, error) {
+			return &orderedSetAggregator{finalize: mostCommon}, nil
+		},
+	}
+}
+
+// orderedSetAggregator keeps every value of the group and sorts them once they are all in, so the result is exact
+type orderedSetAggregator struct {
+	// only numbers can be interpolated between
+	numeric    bool
+	descending bool
+	values     []interface{}
+	finalize   func(sorted []interface{}) (interface{}, error)
+}
+
+// percentile_cont(p) interpolates between the two values either side of the percentile
+func newPercentileCont(params []interface{}) (Aggregate, error) {
+	percentile, err := percentileParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderedSetAggregator{
+		numeric: true,
+		finalize: func(sorted []interface{}) (interface{}, error) {
+			position := percentile * float64(len(sorted)-1)
+
+			lower, _ := CastValue(sorted[int(math.Floor(position))], Double)
+			upper, _ := CastValue(sorted[int(math.Ceil(position))], Double)
+
+			return lower.(float64) + (upper.(float64)-lower.(float64))*(position-math.Floor(position)), nil
+		},
+	}, nil
+}
+
+// percentile_disc(p) is the first value at or past the percentile, so it is always one of the values
+func newPercentileDisc(params []interface{}) (Aggregate, error) {
+	percentile, err := percentileParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderedSetAggregator{
+		finalize: func(sorted []interface{}) (interface{}, error) {
+			index := int(math.Ceil(percentile*float64(len(sorted)))) - 1
+
+			return sorted[tern(index < 0, 0, index)], nil
+		},
+	}, nil
+}
+
+// the most frequent value, ties go to the value that sorts first
+func mostCommon(sorted []interface{}) (interface{}, error) {
+	var common interface{}
+	best, run := 0, 0
+
+	for i := range sorted {
+		if i > 0 && compareSorted(sorted[i-1], sorted[i]) == 0 {
+			run++
+		} else {
+			run = 1
+		}
+
+		if run > best {
+			best, common = run, sorted[i]
+		}
+	}
+
+	return common, nil
+}
+
+func compareSorted(left interface{}, right interface{}) int {
+	result, _ := compareValues(left, right)
+
+	return result
+}
+
+func (a *orderedSetAggregator) Accumulate(args []interface{}) error {
+	if args[0] == nil {
+		return nil
+	}
+
+	if a.numeric && !isNumeric(args[0]) {
+		return errors.New(fmt.Sprintf("cannot take a percentile of non numeric value %v", args[0]))
+	}
+
+	a.values = append(a.values, args[0])
+
+	return nil
+}
+
+func (a *orderedSetAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*orderedSetAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	a.values = append(a.values, partial.values...)
+
+	return nil
+}
+
+func (a *orderedSetAggregator) Finalize() (interface{}, error) {
+	if len(a.values) == 0 {
+		return nil, nil
+	}
+
+	var err error
+
+	sort.SliceStable(a.values, func(i, j int) bool {
+		result, ok := compareValues(a.values[i], a.values[j])
+		if !ok && err == nil {
+			err = errors.New(fmt.Sprintf("cannot compare %s with %s", reflect.TypeOf(a.values[i]), reflect.TypeOf(a.values[j])))
+		}
+
+		return tern(a.descending, result > 0, result < 0)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a.finalize(a.values)
+}
@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"reflect"
+	"testing"
+)
+
+func TestParsesWithinGroup(t *testing.T) {
+	result, err := Parse(`select percentile_cont(0.95) within group (order by ms desc) as p95, mode() within group (order by host) as host`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Fields[0].Expr.String() != "percentile_cont(0.95) within group (order by ms desc)" || !result.Fields[0].Expr.Call.WithinGroup[0].Desc {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	if result.Fields[1].Expr.String() != "mode() within group (order by host)" {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+}
+
+func TestExactPercentiles(t *testing.T) {
+	query, err := Parse(`select route,
+		percentile_cont(0.5) within group (order by ms) as p50,
+		percentile_cont(0.95) within group (order by ms) as p95,
+		percentile_disc(0.95) within group (order by ms) as disc95,
+		percentile_disc(0.5) within group (order by ms desc) as discDesc,
+		percentile_cont(0.25) within group (order by ms desc) as contDesc,
+		median(ms) as median,
+		mode() within group (order by status) as status,
+		mode(ms) as commonMs
+		group by route`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []input.DataRow
+	for i := 1; i <= 100; i++ {
+		rows = append(rows, input.DataRow{"route": "/a", "ms": i, "status": tern(i%10 == 0, 500, 200)})
+	}
+
+	rows = append(rows,
+		input.DataRow{"route": "/b", "ms": 3, "status": 404},
+		input.DataRow{"route": "/b", "ms": 1, "status": 200},
+		input.DataRow{"route": "/b", "ms": 2, "status": 404},
+		input.DataRow{"route": "/b", "ms": 2, "status": 200},
+		input.DataRow{"route": "/b"},
+	)
+
+	expected := []input.DataRow{
+		{"route": "/a", "p50": 50.5, "p95": 95.05, "disc95": int64(95), "discDesc": int64(51), "contDesc": 75.25, "median": 50.5, "status": int64(200), "commonMs": int64(1)},
+		{"route": "/b", "p50": 2.0, "p95": 2.85, "disc95": int64(3), "discDesc": int64(2), "contDesc": 2.25, "median": 2.0, "status": int64(200), "commonMs": int64(2)},
+	}
+
+	for _, workers := range []int{1, 4} {
+		result, err := NewExecutor(*query, Parallelism(workers)).QueryData(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range expected {
+			for key, value := range expected[i] {
+				got := result[i][key]
+				if float, ok := got.(float64); ok {
+					got = float64(int64(float*1e9+0.5)) / 1e9
+				}
+
+				if !reflect.DeepEqual(got, value) {
+					t.Errorf("%d workers %s %s: expected %v got %v", workers, expected[i]["route"], key, value, result[i][key])
+				}
+			}
+		}
+	}
+}
+
+func TestPercentileFailures(t *testing.T) {
+	for _, sql := range []string{
+		`select percentile_cont(0.5) within group (order by name) as p`,
+		`select percentile_cont(1.5) within group (order by ms) as p`,
+		`select sum(1) within group (order by ms) as p`,
+		`select percentile_cont(0.5) within group (order by ms, name) as p`,
+		`select percentile_cont(ms) within group (order by ms) as p`,
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query).QueryData([]input.DataRow{{"ms": 1, "name": "a"}}); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+
+	if _, err := Parse(`select percentile_cont(0.5) within (order by ms) as p`); err == nil {
+		t.Error("expected a missing group to fail the parse")
+	}
+}
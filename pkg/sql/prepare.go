@@ -0,0 +1,300 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Param is a placeholder for a value bound when the query runs, ? takes the next positional argument and $name the
+// argument of that name
+type Param struct {
+	Name string `json:",omitempty"`
+	// the position of a ? counting from 1
+	Position int `json:",omitempty"`
+}
+
+func (p Param) String() string {
+	if p.Name != "" {
+		return "$" + p.Name
+	}
+
+	return "?"
+}
+
+// the way a param is named in errors, ? alone doesn't say which one
+func (p Param) describe() string {
+	if p.Name != "" {
+		return "$" + p.Name
+	}
+
+	return fmt.Sprintf("? %d", p.Position)
+}
+
+// true for ? and $name
+func isPlaceholder(token Token) bool {
+	if token.Quoted() {
+		return false
+	}
+
+	if token.Value == "?" {
+		return true
+	}
+
+	name, ok := strings.CutPrefix(token.Value, "$")
+
+	return ok && name != "" && strings.IndexFunc(name, func(char rune) bool {
+		return !(char == '_' || unicode.IsLetter(char) || unicode.IsDigit(char))
+	}) < 0
+}
+
+// NamedArg binds the value of a $name placeholder
+type NamedArg struct {
+	Name  string
+	Value interface{}
+}
+
+func Named(name string, value interface{}) NamedArg {
+	return NamedArg{Name: strings.TrimPrefix(name, "$"), Value: value}
+}
+
+// Statement is a query parsed once and run any number of times with different values for its placeholders
+type Statement struct {
+	query Query
+	// every placeholder in the order it first appears, a name used twice is one placeholder
+	params []Param
+	// placeholders on the right of in, which have to be bound to an array
+	lists map[Param]bool
+}
+
+// Prepare parses a query with placeholders, values are never written into the query so they need no quoting
+func Prepare(raw string) (*Statement, error) {
+	query, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{query: *query, lists: map[Param]bool{}}
+
+	// a placeholder on the right of in
+	list := func(leaf *Leaf) {
+		if leaf.Compare == In && leaf.Right != nil && leaf.Right.Param != nil {
+			statement.lists[*leaf.Right.Param] = true
+		}
+	}
+
+	walkQuery(query, func(query *Query) {
+		walkLeaves(query.Group, list)
+
+		for _, join := range query.Joins {
+			walkLeaves(join.On, list)
+		}
+	}, func(expr *Expr) {
+		if expr.Param != nil && !slices.Contains(statement.params, *expr.Param) {
+			statement.params = append(statement.params, *expr.Param)
+		}
+
+		if expr.Case != nil {
+			for _, when := range expr.Case.Whens {
+				walkLeaves(when.Condition, list)
+			}
+		}
+	})
+
+	return statement, nil
+}
+
+// the parsed query, its placeholders are Param expressions
+func (s *Statement) Query() Query {
+	return s.query
+}
+
+// the placeholders of the query in the order they appear
+func (s *Statement) Params() []Param {
+	return slices.Clone(s.params)
+}
+
+// Executor binds the arguments to the placeholders and returns an executor for the query. Positional arguments fill
+// the ? placeholders in order and Named arguments fill $name ones, every placeholder has to be bound exactly once
+func (s *Statement) Executor(args []interface{}, options ...ExecutorOption) (*Executor, error) {
+	params, err := s.bind(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewExecutor(s.query, append(options, func(e *Executor) {
+		e.params = params
+	})...), nil
+}
+
+// QueryData runs the query over data with the arguments bound to its placeholders
+func (s *Statement) QueryData(data []input.DataRow, args ...interface{}) ([]input.DataRow, error) {
+	executor, err := s.Executor(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return executor.QueryData(data)
+}
+
+func (s *Statement) bind(args []interface{}) (map[Param]interface{}, error) {
+	params := map[Param]interface{}{}
+	position := 0
+
+	for _, arg := range args {
+		param := Param{}
+
+		if named, ok := arg.(NamedArg); ok {
+			param.Name, arg = named.Name, named.Value
+		} else {
+			position++
+			param.Position = position
+		}
+
+		if !slices.Contains(s.params, param) {
+			if param.Name != "" {
+				return nil, errors.New(fmt.Sprintf("the query has no placeholder $%s", param.Name))
+			}
+
+			return nil, errors.New(fmt.Sprintf("the query has %d ? placeholders but %d positional arguments were given", s.positional(), position))
+		}
+
+		if _, ok := params[param]; ok {
+			return nil, errors.New(fmt.Sprintf("%s is bound more than once", param.describe()))
+		}
+
+		value, err := paramValue(arg)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("can't bind %s: %s", param.describe(), err))
+		}
+
+		if _, ok := value.([]interface{}); s.lists[param] && !ok {
+			return nil, errors.New(fmt.Sprintf("%s is compared with in so it has to be bound to an array but got %v", param.describe(), arg))
+		}
+
+		params[param] = value
+	}
+
+	for _, param := range s.params {
+		if _, ok := params[param]; !ok {
+			return nil, errors.New(fmt.Sprintf("%s is not bound", param.describe()))
+		}
+	}
+
+	return params, nil
+}
+
+// the number of ? placeholders
+func (s *Statement) positional() int {
+	count := 0
+	for _, param := range s.params {
+		count += tern(param.Name == "", 1, 0)
+	}
+
+	return count
+}
+
+// the value a go value is bound as, it keeps its type so a bound 7 is a number and "7" a string. Slices are bound
+// as arrays and maps with string keys as objects
+func paramValue(value interface{}) (interface{}, error) {
+	value = normalize(value)
+
+	switch casted := value.(type) {
+	case nil, bool, int64, float64, DecimalValue, string, time.Time, IntervalValue:
+		return value, nil
+	case time.Duration:
+		return IntervalValue{Duration: casted}, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(casted))
+		for key, element := range casted {
+			bound, err := paramValue(element)
+			if err != nil {
+				return nil, err
+			}
+
+			object[key] = bound
+		}
+
+		return object, nil
+	}
+
+	array, err := arrayArg(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unsupported type %T", value))
+	}
+
+	array = slices.Clone(array)
+
+	for i, element := range array {
+		if array[i], err = paramValue(element); err != nil {
+			return nil, err
+		}
+	}
+
+	return array, nil
+}
+
+// the placeholders in the in list of the leaf, like in (?, ?)
+func listParams(leaf *Leaf) []Param {
+	values, ok := leaf.Value.([]interface{})
+	if leaf.Compare != In || !ok {
+		return nil
+	}
+
+	var params []Param
+	for _, value := range values {
+		if param, ok := value.(Param); ok {
+			params = append(params, param)
+		}
+	}
+
+	return params
+}
+
+// the in list with its placeholders replaced by their bound values
+func (s *Executor) bindList(values []interface{}) ([]interface{}, error) {
+	bound := make([]interface{}, len(values))
+
+	for i, value := range values {
+		param, ok := value.(Param)
+		if !ok {
+			bound[i] = value
+			continue
+		}
+
+		var err error
+		if bound[i], err = s.param(param); err != nil {
+			return nil, err
+		}
+	}
+
+	return bound, nil
+}
+
+func walkLeaves(group *PredicateGroup, visit func(*Leaf)) {
+	if group == nil {
+		return
+	}
+
+	for _, predicate := range group.Predicate {
+		if predicate.Leaf != nil {
+			visit(predicate.Leaf)
+		}
+
+		walkLeaves(predicate.Group, visit)
+	}
+}
+
+// the value bound to a placeholder, subqueries read the values bound to the query they are in
+func (s *Executor) param(param Param) (interface{}, error) {
+	value, ok := s.root().params[param]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s is not bound, queries with placeholders are run with sql.Prepare", param.describe()))
+	}
+
+	return value, nil
+}
@@ -1,15 +1,17 @@
 package sql
 
 import (
-	"cmp"
 	"errors"
 	"example/pkg/input"
 	"example/pkg/util"
 	"fmt"
-	"golang.org/x/exp/constraints"
 	"log/slog"
 	"reflect"
+	"regexp"
 	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type GroupingOperator string
@@ -35,6 +37,9 @@ type Leaf struct {
 	Field   string
 	Compare ComparisonOperator
 	Value   interface{}
+	// Left replaces Field and Right replaces Value when a side isn't a plain field or literal
+	Left  *Expr `json:",omitempty"`
+	Right *Expr `json:",omitempty"`
 }
 
 type Tree struct {
@@ -61,6 +66,49 @@ type PredicateGroup struct {
 	Predicate []Tree
 }
 
+func (l *Leaf) String() string {
+	left := tern(l.Left != nil, l.Left, NewColumn(l.Field)).String()
+	if l.Compare == "" {
+		return left
+	}
+
+	right := literalString(l.Value)
+	switch {
+	case l.Right != nil && l.Right.Column != "" && !strings.Contains(l.Right.Column, "."):
+		// a bare word on the right is a string, so a column there is always quoted
+		right = "`" + strings.ReplaceAll(l.Right.Column, "`", "``") + "`"
+	case l.Right != nil:
+		right = l.Right.String()
+	case reflect.TypeOf(l.Value) != nil && reflect.TypeOf(l.Value).Kind() == reflect.Slice:
+		var values []string
+
+		in := reflect.ValueOf(l.Value)
+		for i := 0; i < in.Len(); i++ {
+			values = append(values, literalString(in.Index(i).Interface()))
+		}
+
+		right = "(" + strings.Join(values, ", ") + ")"
+	}
+
+	return fmt.Sprintf("%s %s %s", left, l.Compare, right)
+}
+
+// nested groups are bracketed so the string parses back to the same tree
+func (g *PredicateGroup) String() string {
+	var parts []string
+
+	for _, predicate := range g.Predicate {
+		switch {
+		case predicate.Leaf != nil:
+			parts = append(parts, predicate.Leaf.String())
+		case predicate.Group != nil:
+			parts = append(parts, "("+predicate.Group.String()+")")
+		}
+	}
+
+	return strings.Join(parts, " "+string(tern(g.Operator == "", And, g.Operator))+" ")
+}
+
 type Function = string
 
 const (
@@ -72,70 +120,250 @@ const (
 type KeyAlias string
 
 type Field struct {
-	Name     string
-	Alias    KeyAlias
+	Name  string
+	Alias KeyAlias
+	// Function averages Name over every row without grouping them, each row keeps its own fields like the window
+	// average(x) over () does. A parsed average(x) is an aggregate and makes the query one row
 	Function Function `json:",omitempty"`
+	// Expr is set instead of Name when the field is computed
+	Expr *Expr `json:",omitempty"`
 }
 
-// select foo where ...
+// with ... select foo from ... join ... where ... group by ... union ...
 type Query struct {
-	Fields []Field
-	Group  *PredicateGroup `json:",omitempty"`
+	With    []CommonTable `json:",omitempty"`
+	Fields  []Field
+	From    *Table          `json:",omitempty"`
+	Joins   []Join          `json:",omitempty"`
+	Group   *PredicateGroup `json:",omitempty"`
+	GroupBy []Expr          `json:",omitempty"`
+	// the queries combined with this one, in order
+	Sets []SetOperation `json:",omitempty"`
+	// sorts the rows of the query once they are combined
+	OrderBy []OrderBy `json:",omitempty"`
+}
+
+func (f Field) String() string {
+	field, key := quoteIdentifier(f.Name), f.Name
+
+	switch {
+	case f.Expr != nil:
+		field = f.Expr.String()
+		key = field
+	case f.Function != "":
+		field = fmt.Sprintf("%s(%s)", f.Function, f.Name)
+		key = field
+	}
+
+	if f.Alias != "" && string(f.Alias) != key {
+		field += " as " + quoteIdentifier(string(f.Alias))
+	}
+
+	return field
+}
+
+func (q Query) String() string {
+	var fields []string
+	for _, field := range q.Fields {
+		fields = append(fields, field.String())
+	}
+
+	query := "select " + strings.Join(fields, ", ")
+	if len(q.With) > 0 {
+		query = withString(q.With) + " " + query
+	}
+
+	if q.From != nil {
+		query += " from " + q.From.String()
+	}
+
+	for _, join := range q.Joins {
+		query += " " + join.String()
+	}
+
+	if q.Group != nil && len(q.Group.Predicate) > 0 {
+		query += " where " + q.Group.String()
+	}
+
+	if len(q.GroupBy) > 0 {
+		var exprs []string
+		for _, expr := range q.GroupBy {
+			exprs = append(exprs, expr.String())
+		}
+
+		query += " group by " + strings.Join(exprs, ", ")
+	}
+
+	for _, set := range q.Sets {
+		query += " " + set.String()
+	}
+
+	if len(q.OrderBy) > 0 {
+		query += " order by " + orderByString(q.OrderBy)
+	}
+
+	return query
 }
 
 type Executor struct {
-	sql Query
+	sql     Query
+	lenient bool
+	// the time now() returns, fixed for the whole query
+	now     time.Time
+	regexps map[string]*regexp.Regexp
+	// guards regexps when aggregates run in parallel
+	regexpsLock sync.Mutex
+	registry    *Registry
+	// the number of goroutines each aggregate is split between
+	workers int
+	// tables the query can read by name
+	tables map[string][]input.DataRow
+	// the rows piped into the query and the name of the table that reads them
+	piped struct {
+		name string
+		rows []input.DataRow
+	}
+	// the executor of the query a subquery is inside, and the scope of the row it is run for when it reads the row
+	parent *Executor
+	outer  *scope
+	// the rows of the common tables of the query and the queries it is inside
+	ctes map[string][]input.DataRow
+	// the rows of subqueries that don't read the outer row, they are the same for every row
+	subqueries     map[*Query][]input.DataRow
+	subqueriesLock sync.Mutex
+	// the values bound to the placeholders of a prepared statement
+	params map[Param]interface{}
 }
 
-func sliceCompare[T comparable](source []T, value T, op ComparisonOperator) (bool, error) {
-	switch op {
-	case In:
-		return slices.Contains(source, value), nil
-	default:
-		return false, errors.New("Cannot process arrays without in clause")
+type ExecutorOption func(*Executor)
+
+// LenientTypes coerces any value that looks like a number into a number before comparing it, so "007" equals 7
+func LenientTypes() ExecutorOption {
+	return func(e *Executor) {
+		e.lenient = true
+	}
+}
+
+// FixedNow pins what now() returns, useful for repeatable queries and tests
+func FixedNow(now time.Time) ExecutorOption {
+	return func(e *Executor) {
+		e.now = now
+	}
+}
+
+// Parallelism splits the rows of every aggregate between workers goroutines and merges their partial results,
+// registered functions must be safe to call concurrently when workers is more than one
+func Parallelism(workers int) ExecutorOption {
+	return func(e *Executor) {
+		e.workers = workers
+	}
+}
+
+// WithTable makes rows readable by name, so they can be joined as join name on ...
+func WithTable(name string, rows []input.DataRow) ExecutorOption {
+	return func(e *Executor) {
+		e.tables[name] = rows
+	}
+}
+
+// normalizes a value for comparison, in lenient mode strings that look like numbers are numbers too
+func (s *Executor) comparisonValue(value interface{}) interface{} {
+	if s.lenient {
+		if numeric, err := toNumber(value); err == nil {
+			return numeric
+		}
+	}
+
+	// arrays of any type compare like arrays read from json
+	if kind := reflect.TypeOf(value); kind != nil && kind.Kind() == reflect.Slice {
+		if array, err := arrayArg(value); err == nil {
+			return array
+		}
 	}
+
+	return normalize(value)
+}
+
+func coerceTimestamps(value interface{}, other interface{}) interface{} {
+	if _, ok := other.(time.Time); ok {
+		if ts, err := CastValue(value, Timestamp); err == nil {
+			return ts
+		}
+	}
+
+	return value
+}
+
+// a string compared with a timestamp in strict mode is a mistake rather than a value that never matches, the error
+// suggests casting the expression the string came from
+func stringComparedToTimestamp(value interface{}, other interface{}, valueExpr string, otherExpr string) error {
+	for _, pair := range [][3]interface{}{{value, other, valueExpr}, {other, value, otherExpr}} {
+		str, isString := pair[0].(string)
+		if _, isTime := pair[1].(time.Time); isString && isTime {
+			expr := tern(pair[2].(string) != "", pair[2].(string), quoteString(str))
+			return errors.New(fmt.Sprintf("can't compare the string %s with a timestamp, use cast(%s as timestamp) or --lenient-types", quoteString(str), expr))
+		}
+	}
+
+	return nil
 }
 
 // A Leaf comparison of the data row to know if it should be included in the final result or not
-func (s *Executor) compare(predicate *Leaf, value interface{}) (bool, error) {
-	if value == nil && predicate.Value != nil {
+func (s *Executor) compare(op ComparisonOperator, value interface{}, target interface{}) (bool, error) {
+	// null never matches anything, not even null
+	if value == nil {
 		return false, nil
 	}
 
 	slog.Debug("Processing Predicate",
-		"Predicate-Value", fmt.Sprintf("%s", reflect.TypeOf(predicate.Value)),
+		"Predicate-Value", fmt.Sprintf("%s", reflect.TypeOf(target)),
 		"Value", fmt.Sprintf("%s", reflect.TypeOf(value)),
 	)
 
-	if reflect.TypeOf(predicate.Value).Kind() == reflect.Slice {
+	value = s.comparisonValue(value)
+
+	switch value.(type) {
+	case string, int64, float64, DecimalValue, bool, time.Time, IntervalValue, []interface{}:
+	default:
+		return false, errors.New(fmt.Sprintf("unsupported type %s", reflect.TypeOf(value)))
+	}
+
+	if target == nil {
+		return false, nil
+	}
+
+	if op == In {
 		// in clause if the Predicate is an array
-		switch in := predicate.Value.(type) {
-		case []string:
-			return sliceCompare(in, value.(string), predicate.Compare)
-		case []int:
-			return sliceCompare(in, value.(int), predicate.Compare)
-		case []float64:
-			return sliceCompare(in, value.(float64), predicate.Compare)
-		case []interface{}:
-			return sliceCompare(in, value, predicate.Compare)
+		if reflect.TypeOf(target).Kind() != reflect.Slice {
+			return false, errors.New("in needs a list of values")
 		}
 
-		return false, errors.New("unsupported array type")
+		in := reflect.ValueOf(target)
+		for i := 0; i < in.Len(); i++ {
+			if result, ok := compareValues(value, s.comparisonValue(in.Index(i).Interface())); ok && result == 0 {
+				return true, nil
+			}
+		}
+
+		return false, nil
 	}
 
-	var result int
-	switch casted := value.(type) {
-	case string:
-		result = cmp.Compare(casted, predicate.Value.(string))
-	case int:
-		result = cmp.Compare(casted, predicate.Value.(int))
-	case float64:
-		result = cmp.Compare(casted, predicate.Value.(float64))
-	default:
-		return false, errors.New(fmt.Sprintf("unsupported type %s", reflect.TypeOf(value)))
+	target = s.comparisonValue(target)
+
+	// lenient mode reads strings compared against timestamps as timestamps, strict mode fails rather than match nothing
+	if s.lenient {
+		value, target = coerceTimestamps(value, target), coerceTimestamps(target, value)
+	} else if err := stringComparedToTimestamp(value, target, "", ""); err != nil {
+		return false, err
 	}
 
-	switch predicate.Compare {
+	result, ok := compareValues(value, target)
+	if !ok {
+		// values of different types never match, a string is never equal to a number
+		return false, nil
+	}
+
+	switch op {
 	case Neq:
 		return result != 0, nil
 	case Eq:
@@ -153,7 +381,66 @@ func (s *Executor) compare(predicate *Leaf, value interface{}) (bool, error) {
 	return false, errors.New("invalid Predicate")
 }
 
-func (s *Executor) inPredicateGroup(row input.DataRow, group *PredicateGroup) (bool, error) {
+// evaluates both sides of a Leaf against the row and compares them
+func (s *Executor) compareLeaf(sc *scope, leaf *Leaf) (bool, error) {
+	left := tern(leaf.Left != nil, leaf.Left, NewColumn(leaf.Field))
+
+	// any(tags) = 'x' compares each element of the array
+	leftAny, rightAny := anyArg(left), anyArg(leaf.Right)
+
+	value, err := s.eval(sc, tern(leftAny != nil, leftAny, left))
+	if err != nil {
+		return false, err
+	}
+
+	// a leaf without a comparison is a boolean expression
+	if leaf.Compare == "" {
+		return value == true, nil
+	}
+
+	target := leaf.Value
+	switch {
+	case leaf.Compare == In && leaf.Right != nil && leaf.Right.Subquery != nil:
+		// in compares with every value the subquery selects
+		target, err = s.subqueryValues(sc, leaf.Right.Subquery)
+		if err != nil {
+			return false, err
+		}
+	case leaf.Right != nil:
+		target, err = s.eval(sc, tern(rightAny != nil, rightAny, leaf.Right))
+		if err != nil {
+			return false, err
+		}
+	case len(listParams(leaf)) > 0:
+		target, err = s.bindList(target.([]interface{}))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	switch {
+	case leftAny != nil:
+		return anyElement(value, func(element interface{}) (bool, error) {
+			return s.compare(leaf.Compare, element, target)
+		})
+	case rightAny != nil:
+		return anyElement(target, func(element interface{}) (bool, error) {
+			return s.compare(leaf.Compare, value, element)
+		})
+	}
+
+	if !s.lenient && leaf.Compare != In {
+		if err := stringComparedToTimestamp(value, target, left.String(), tern(leaf.Right != nil, leaf.Right, &Expr{}).String()); err != nil {
+			return false, err
+		}
+	}
+
+	return s.compare(leaf.Compare, value, target)
+}
+
+// evaluates the group as a boolean expression against the scope, the where clause runs it against each row and
+// case against whatever the case is evaluated in
+func (s *Executor) inPredicateGroup(sc *scope, group *PredicateGroup) (bool, error) {
 	// no Predicate, just select everything
 	if group == nil {
 		return true, nil
@@ -161,17 +448,11 @@ func (s *Executor) inPredicateGroup(row input.DataRow, group *PredicateGroup) (b
 
 	exists := func(predicate Tree) (bool, error) {
 		if predicate.Leaf != nil {
-			numeric, err := TryToNumeric(row[keyNameFromAlias(predicate.Leaf.Field, s.sql)])
-
-			stringValue := row[keyNameFromAlias(predicate.Leaf.Field, s.sql)]
-
-			value := tern[interface{}](err == nil, numeric, stringValue)
-
-			return s.compare(predicate.Leaf, value)
+			return s.compareLeaf(sc, predicate.Leaf)
 		}
 
 		if predicate.Group != nil {
-			return s.inPredicateGroup(row, predicate.Group)
+			return s.inPredicateGroup(sc, predicate.Group)
 		}
 
 		return false, nil
@@ -189,19 +470,35 @@ func (s *Executor) inPredicateGroup(row input.DataRow, group *PredicateGroup) (b
 	return false, errors.New("invalid Predicate Operator")
 }
 
-// extracts selected Fields
-func selectFields(row input.DataRow, sql Query) input.DataRow {
+// extracts selected Fields and evaluates the computed ones
+func (s *Executor) selectFields(row input.DataRow, windows map[string]interface{}) (input.DataRow, error) {
 	selected := make(input.DataRow)
 
-	allFieldNames := FieldNames(sql)
+	allFieldNames := FieldNames(s.sql)
 
 	for key := range row {
-		if slices.Contains(allFieldNames, key) || slices.Contains(allFieldNames, "*") {
-			selected[string(keyAliasFromName(key, sql))] = row[key]
+		if slices.Contains(allFieldNames, key) || (slices.Contains(allFieldNames, "*") && !s.qualified(key)) {
+			selected[string(keyAliasFromName(key, s.sql))] = row[key]
+		}
+	}
+
+	sc := newScope(row)
+	sc.windows = windows
+
+	for _, field := range s.sql.Fields {
+		if field.Expr == nil {
+			continue
+		}
+
+		value, err := s.evalField(sc, field)
+		if err != nil {
+			return nil, err
 		}
+
+		selected[string(field.Alias)] = value
 	}
 
-	return selected
+	return selected, nil
 }
 
 func keyAliasFromName(key string, sql Query) KeyAlias {
@@ -220,7 +517,7 @@ func keyAliasFromName(key string, sql Query) KeyAlias {
 
 func keyNameFromAlias(alias string, sql Query) string {
 	for _, field := range sql.Fields {
-		if field.Alias == KeyAlias(alias) {
+		if field.Alias == KeyAlias(alias) && field.Name != "" {
 			return field.Name
 		}
 	}
@@ -232,82 +529,144 @@ func FieldNames(sql Query) []string {
 	var names []string
 
 	for _, field := range sql.Fields {
-		names = append(names, field.Name)
+		if field.Name != "" {
+			names = append(names, field.Name)
+		}
 	}
 
 	return names
 }
 
-func (s *Executor) QueryData(data []input.DataRow) ([]input.DataRow, error) {
-	var results []input.DataRow
+// ReadsPipedRows is whether the query reads the rows given to QueryData, either through a from without a table or
+// a table name that isn't registered, a common table or a file
+func (s *Executor) ReadsPipedRows() bool {
+	if s.pipedName() != "" {
+		return true
+	}
 
-	for _, row := range data {
-		if exists, err := s.inPredicateGroup(row, s.sql.Group); err == nil {
-			if exists {
-				selectedFields := selectFields(row, s.sql)
+	// the queries combined with set operations read the same rows as the query they follow
+	queries := []*Query{&s.sql}
+	for i := range s.sql.Sets {
+		queries = append(queries, &s.sql.Sets[i].Query)
+	}
 
-				results = append(results, selectedFields)
-			}
-		} else {
-			return results, err
+	for _, query := range queries {
+		if query.From == nil {
+			return true
 		}
 	}
 
-	return s.processFunctionGroupings(results)
+	return false
 }
 
-func (s *Executor) processFunctionGroupings(results []input.DataRow) ([]input.DataRow, error) {
-	var functionFields []Field
-	for _, field := range s.sql.Fields {
-		if field.Function != "" {
-			functionFields = append(functionFields, field)
-		}
+func (s *Executor) QueryData(data []input.DataRow) ([]input.DataRow, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
 	}
 
-	if len(functionFields) == 0 {
-		return results, nil
+	s.piped.name, s.piped.rows = s.pipedName(), data
+	s.subqueries = map[*Query][]input.DataRow{}
+
+	return s.run(data)
+}
+
+// runs a query that has been validated
+func (s *Executor) run(data []input.DataRow) ([]input.DataRow, error) {
+	if err := s.commonTables(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.selectRows(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.setOperations(s.unnestFields(rows), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orderRows(rows)
+}
+
+// the rows of the select, before it is combined with any other query
+func (s *Executor) selectRows(data []input.DataRow) ([]input.DataRow, error) {
+	rows, err := s.sourceRows(data)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, field := range functionFields {
-		var fieldValues []float64
-		for _, row := range results {
-			numeric, err := TryToNumeric(row[string(field.Alias)])
-			if err != nil {
-				return nil, err
+	var matched []input.DataRow
+
+	for _, row := range rows {
+		if exists, err := s.inPredicateGroup(newScope(row), s.sql.Group); err == nil {
+			if exists {
+				matched = append(matched, row)
 			}
-			fieldValues = append(fieldValues, numeric)
+		} else {
+			return nil, err
 		}
+	}
 
-		switch field.Function {
-		case Average:
-			avg := average(fieldValues)
+	if s.grouped() {
+		return s.groupRows(matched)
+	}
 
-			// assign the result to each row
-			for _, row := range results {
-				row[string(field.Alias)] = avg
-			}
+	windows, err := s.windows(matched)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []input.DataRow
+
+	for i, row := range matched {
+		selectedFields, err := s.selectFields(row, windows[i])
+		if err != nil {
+			return nil, err
 		}
+
+		results = append(results, selectedFields)
 	}
 
 	return results, nil
 }
 
-type Number interface {
-	constraints.Float | constraints.Integer
-}
+// fields that only name a Function are from before computed fields, ungrouped they average over every row as
+// the window function average(x) over ()
+func (s *Executor) legacyFunctions() {
+	fields := slices.Clone(s.sql.Fields)
+	grouped := s.grouped()
+
+	for i, field := range fields {
+		if field.Function == "" || field.Expr != nil {
+			continue
+		}
 
-func average[T Number](numbers []T) float64 {
-	var sum float64
+		call := &Call{Name: field.Function, Args: []Expr{*NewColumn(field.Name)}}
+		if !grouped {
+			call.Over = &Window{}
+		}
 
-	for _, number := range numbers {
-		sum += float64(number)
+		fields[i] = Field{Alias: field.Alias, Expr: &Expr{Call: call}}
 	}
 
-	return sum / float64(len(numbers))
+	s.sql.Fields = fields
 }
 
-func NewExecutor(sql Query) *Executor {
-	return &Executor{
-		sql: sql,
+func NewExecutor(sql Query, options ...ExecutorOption) *Executor {
+	executor := &Executor{
+		sql:        sql,
+		now:        time.Now().UTC(),
+		regexps:    map[string]*regexp.Regexp{},
+		tables:     map[string][]input.DataRow{},
+		subqueries: map[*Query][]input.DataRow{},
+	}
+
+	for _, option := range options {
+		option(executor)
 	}
+
+	executor.legacyFunctions()
+
+	return executor
 }
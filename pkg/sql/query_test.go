@@ -1,9 +1,15 @@
 package sql
 
 import (
+	"bufio"
+	"encoding/json"
 	"example/pkg/input"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestQueries(t *testing.T) {
@@ -134,8 +140,8 @@ func TestCompoundQueries(t *testing.T) {
 	}
 
 	var data = []input.DataRow{
-		{"foo": 1, "bar": "2"},
-		{"foo": 2, "bar": "3"},
+		{"foo": 1, "bar": 2},
+		{"foo": 2, "bar": 3},
 		{"foo": 3},
 	}
 
@@ -175,8 +181,8 @@ func TestCompoundTreeQueries(t *testing.T) {
 	}
 
 	var data = []input.DataRow{
-		{"foo": float64(1), "bar": "2"},
-		{"foo": float64(2), "bar": "3"},
+		{"foo": float64(1), "bar": 2},
+		{"foo": float64(2), "bar": 3},
 		{"foo": float64(3)},
 		{"foo": float64(4), "baz": 5},
 		{"foo": float64(5), "baz": 5, "id": 1},
@@ -228,3 +234,1224 @@ func TestInClause(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestStrictTypesDontCoerceStrings(t *testing.T) {
+	var sql = Query{
+		Fields: []Field{{Name: "id"}},
+		Group: &PredicateGroup{Predicate: []Tree{
+			NewLeaf(Leaf{Value: float64(7), Compare: Eq, Field: "id"}),
+		}},
+	}
+
+	var data = []input.DataRow{
+		{"id": "007"},
+		{"id": "7"},
+		{"id": 7},
+	}
+
+	result, err := NewExecutor(sql).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"id": 7},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	result, err = NewExecutor(sql, LenientTypes()).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"id": "007"},
+		{"id": "7"},
+		{"id": 7},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesCast(t *testing.T) {
+	query, err := Parse(`select id, cast(id as bigint) as numeric, try_cast(name as double) as score where cast(id as bigint) >= 7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = []input.DataRow{
+		{"id": "007", "name": "1.5"},
+		{"id": "3", "name": "bob"},
+		{"id": "12", "name": "bob"},
+	}
+
+	result, err := NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"id": "007", "numeric": int64(7), "score": float64(1.5)},
+		{"id": "12", "numeric": int64(12), "score": nil},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	query, err = Parse(`select cast(name as double) as score`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewExecutor(*query).QueryData(data)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestQueriesLargeIntegers(t *testing.T) {
+	query, err := Parse(`select id where id = 1234567890123456789`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = []input.DataRow{
+		{"id": int64(1234567890123456789)},
+		{"id": int64(1234567890123456788)},
+	}
+
+	result, err := NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"id": int64(1234567890123456789)},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesIdsLargerThanBigints(t *testing.T) {
+	data, err := input.NewStdinReader().Parse(bufio.NewReader(strings.NewReader(
+		`{"id": 12345678901234567890}` + "\n" + `{"id": 12345678901234567891}` + "\n" + `{"id": 1}` + "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		sql      string
+		expected int
+	}{
+		{`select id where id = 12345678901234567890`, 1},
+		{`select id where id in (12345678901234567890, 1)`, 2},
+		{`select id where id > 12345678901234567890`, 1},
+	} {
+		query, err := Parse(test.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the literal reads back with all of its digits
+		if query.String() != test.sql {
+			t.Errorf("expected %s got %s", test.sql, query)
+		}
+
+		result, err := NewExecutor(*query).QueryData(data)
+		if err != nil {
+			t.Fatalf("%s: %s", test.sql, err)
+		}
+
+		if len(result) != test.expected {
+			t.Errorf("%s: expected %d rows got %v", test.sql, test.expected, result)
+		}
+	}
+}
+
+func TestQueriesCastAliasedToItself(t *testing.T) {
+	// the where reads the column of the row, not the field aliased as it
+	query, err := Parse(`select cast(bar as bigint) as bar where bar = '3'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{
+		{"foo": 1},
+		{"bar": "3"},
+		{"bar": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"bar": int64(3)},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesWhereOnColumnsShadowedByAliases(t *testing.T) {
+	query, err := Parse(`with recursive r as (select 1 as n union all select n + 1 as n from r where n < 3) select n from r`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []input.DataRow{{"n": int64(1)}, {"n": int64(2)}, {"n": int64(3)}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v got %v", expected, result)
+	}
+
+	// an alias the rows don't have a column for is still read in the where
+	query, err = Parse(`select ms * 2 as double where double > 10`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = NewExecutor(*query).QueryData([]input.DataRow{{"ms": int64(4)}, {"ms": int64(6)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []input.DataRow{{"double": int64(12)}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v got %v", expected, result)
+	}
+}
+
+func TestQueriesJsonNumbers(t *testing.T) {
+	query, err := Parse(`select * where id = 1234567890123456789 or price = 1.5 or huge > 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = []input.DataRow{
+		{"id": json.Number("1234567890123456789")},
+		{"id": json.Number("1234567890123456788")},
+		{"price": json.Number("1.50")},
+		{"huge": json.Number("99999999999999999999999")},
+	}
+
+	result, err := NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(output) != `[{"id":1234567890123456789},{"price":1.50},{"huge":99999999999999999999999}]` {
+		t.Log(string(output))
+		t.Fail()
+	}
+}
+
+func TestQueriesTimes(t *testing.T) {
+	query, err := Parse(`select id, date_trunc('hour', cast(ts as timestamp)) as hour where cast(ts as timestamp) > now() - interval '1 hour'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = []input.DataRow{
+		{"id": 1, "ts": "2024-01-01T11:30:00Z"},
+		{"id": 2, "ts": "2024-01-01T10:59:00Z"},
+		{"id": 3, "ts": "not a time"},
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err = NewExecutor(*query, FixedNow(now)).QueryData(data)
+	if err == nil {
+		t.Fatal("expected casting an invalid timestamp to fail")
+	}
+
+	result, err := NewExecutor(*query, FixedNow(now)).QueryData(data[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"id": 1, "hour": time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	query, err = Parse(`select id where ts >= timestamp '2024-01-01T11:00:00Z'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = NewExecutor(*query, LenientTypes()).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"id": 1},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	// strict mode points at the cast rather than matching nothing
+	for _, sql := range []string{
+		`select id where ts > now() - interval '1 hour'`,
+		`select id where cast(ts as timestamp) < '2024-01-01T11:00:00Z'`,
+	} {
+		query, err = Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = NewExecutor(*query, FixedNow(now)).QueryData(data[:2])
+		if err == nil || !strings.Contains(err.Error(), "as timestamp)") {
+			t.Errorf("%s: expected a type error got %v", sql, err)
+		}
+
+	}
+}
+
+func TestQueriesGroupBy(t *testing.T) {
+	query, err := Parse(`select host, count(*) as n, sum(ms) as total, avg(ms) as mean, min(ms) as low, max(ms) as high group by host`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = []input.DataRow{
+		{"host": "a", "ms": 1},
+		{"host": "b", "ms": 10},
+		{"host": "a", "ms": 3},
+		{"host": "a"},
+	}
+
+	result, err := NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"host": "a", "n": int64(3), "total": int64(4), "mean": float64(2), "low": int64(1), "high": int64(3)},
+		{"host": "b", "n": int64(1), "total": int64(10), "mean": float64(10), "low": int64(10), "high": int64(10)},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesAggregatesWithoutGroupBy(t *testing.T) {
+	query, err := Parse(`select count(*) as n, sum(ms) as total where ms > 100`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{{"ms": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"n": int64(0), "total": nil},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesFunctionFieldsAsWindows(t *testing.T) {
+	var data = []input.DataRow{{"foo": 2, "bar": "a"}, {"foo": 4, "bar": "b"}}
+
+	// a Function field keeps every row
+	result, err := NewExecutor(Query{Fields: []Field{{Name: "foo", Alias: "avg", Function: Average}, {Name: "bar", Alias: "bar"}}}).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{{"avg": float64(3), "bar": "a"}, {"avg": float64(3), "bar": "b"}}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	// the parsed call aggregates them into one
+	query, err := Parse(`select average(foo) as avg`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{{"avg": float64(3)}}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesGroupsOnlyReadGroupedColumns(t *testing.T) {
+	var data = []input.DataRow{{"host": "a", "ms": 1}, {"host": "a", "ms": 3}, {"host": "b", "ms": 2}}
+
+	query, err := Parse(`select host as h, upper(host) as up, count(*) as n group by h`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{{"h": "a", "up": "A", "n": int64(2)}, {"h": "b", "up": "B", "n": int64(1)}}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	for _, sql := range []string{
+		`select average(ms) as a, host`,
+		`select host, ms, count(*) as n group by host`,
+		`select host, max(ms) - ms as d group by host`,
+		`select *, count(*) as n group by host`,
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query).QueryData(data); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
+
+func TestQueriesTimeBuckets(t *testing.T) {
+	query, err := Parse(`select time_bucket_gapfill('5 minutes', ts) as bucket, count(*) as n group by bucket`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = []input.DataRow{
+		{"ts": "2024-01-01T10:01:00Z"},
+		{"ts": "2024-01-01T10:14:59Z"},
+		{"ts": "2024-01-01T10:04:00Z"},
+	}
+
+	result, err := NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := func(minute int) time.Time {
+		return time.Date(2024, 1, 1, 10, minute, 0, 0, time.UTC)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"bucket": bucket(0), "n": int64(2)},
+		{"bucket": bucket(5), "n": int64(0)},
+		{"bucket": bucket(10), "n": int64(1)},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+
+	query, err = Parse(`select date_bin('5 minutes', ts) as bucket, count(*) as n group by bucket`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = NewExecutor(*query).QueryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+func TestQueriesStringFunctions(t *testing.T) {
+	query, err := Parse(`select upper(name) as shout where starts_with(lower(trim(name)), 'a') or contains(name, 'z')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{
+		{"name": " Alice "},
+		{"name": "bob"},
+		{"name": "liz"},
+		{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"shout": " ALICE "},
+		{"shout": "LIZ"},
+	}) {
+		t.Logf("%s", result)
+		t.Fail()
+	}
+}
+
+// rows that log a request to a host, shared by the tests of queries over a whole table
+var hostLogs = []input.DataRow{
+	{"host": "web1", "status": 200, "ms": 10},
+	{"host": "web1", "status": 500, "ms": 900},
+	{"host": "web2", "status": 500, "ms": 20},
+	{"host": "web2", "status": 500, "ms": 700},
+	{"host": "web3", "status": 200, "ms": 800},
+}
+
+type queryTest struct {
+	sql      string
+	expected []input.DataRow
+}
+
+// runs each query over the rows and fails unless it selects the expected rows, no rows at all is nil
+func runQueryTests(t *testing.T, tests []queryTest, rows []input.DataRow, options ...ExecutorOption) {
+	for _, test := range tests {
+		query, err := Parse(test.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := NewExecutor(*query, options...).QueryData(rows)
+		if err != nil {
+			t.Fatalf("%s: %s", test.sql, err)
+		}
+
+		if len(result) == 0 && len(test.expected) == 0 {
+			continue
+		}
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.sql, test.expected, result)
+		}
+	}
+}
+
+// runs each query over the rows and fails unless it errors
+func runInvalidQueries(t *testing.T, sqls []string, rows []input.DataRow, options ...ExecutorOption) {
+	for _, sql := range sqls {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query, options...).QueryData(rows); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
+
+func TestQueriesJoins(t *testing.T) {
+	var events = []input.DataRow{
+		{"id": 1, "user_id": 1, "action": "login"},
+		{"id": 2, "user_id": 2, "action": "logout"},
+		{"id": 3, "user_id": 9, "action": "login"},
+		{"id": 4, "user_id": nil, "action": "ping"},
+	}
+
+	var users = []input.DataRow{
+		{"id": json.Number("1"), "user_id": 1, "name": "ann"},
+		{"id": 2.0, "user_id": 2, "name": "bob"},
+		{"id": 3, "user_id": 3, "name": "cat"},
+		{"id": nil, "user_id": nil, "name": "nobody"},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			// numbers of any type match and a null matches nothing, not even another null
+			"select e.id, u.name from events e join users u on e.user_id = u.id",
+			[]input.DataRow{{"e.id": 1, "u.name": "ann"}, {"e.id": 2, "u.name": "bob"}},
+		},
+		{
+			// the columns of a table without a match are missing like any other missing column
+			"select e.id, u.name from events e left join users u on e.user_id = u.id",
+			[]input.DataRow{{"e.id": 1, "u.name": "ann"}, {"e.id": 2, "u.name": "bob"}, {"e.id": 3}, {"e.id": 4}},
+		},
+		{
+			"select e.id, u.name from events e full join users u on e.user_id = u.id",
+			[]input.DataRow{{"e.id": 1, "u.name": "ann"}, {"e.id": 2, "u.name": "bob"}, {"e.id": 3}, {"e.id": 4}, {"u.name": "cat"}, {"u.name": "nobody"}},
+		},
+		{
+			// the null user_id on both sides stay two rows
+			"select user_id, name from events full join users using (user_id)",
+			[]input.DataRow{{"user_id": 1, "name": "ann"}, {"user_id": 2, "name": "bob"}, {"user_id": 9}, {"user_id": nil}, {"user_id": 3, "name": "cat"}, {"user_id": nil, "name": "nobody"}},
+		},
+		{
+			// not an equality so every pair is compared
+			"select e.id, u.name from events e join users u on e.user_id > u.id",
+			[]input.DataRow{{"e.id": 2, "u.name": "ann"}, {"e.id": 3, "u.name": "ann"}, {"e.id": 3, "u.name": "bob"}, {"e.id": 3, "u.name": "cat"}},
+		},
+		{
+			"select e.id, u.name from events e join users u on e.user_id = u.id or e.id = 4 and u.id = 3",
+			[]input.DataRow{{"e.id": 1, "u.name": "ann"}, {"e.id": 2, "u.name": "bob"}, {"e.id": 4, "u.name": "cat"}},
+		},
+		{
+			"select * from events e join users u on e.user_id = u.id where u.name = 'bob'",
+			[]input.DataRow{{"id": 2, "user_id": 2, "action": "logout", "name": "bob"}},
+		},
+		{
+			"select upper(u.name) as name from events e join users u on e.user_id = u.id join events again on again.id = u.id where again.action = 'logout'",
+			[]input.DataRow{{"name": "BOB"}},
+		},
+		{
+			// an empty table joins nothing, a left join keeps its rows
+			"select e.id from events e join empty x on e.id = x.id",
+			nil,
+		},
+		{
+			"select e.id, x.id from events e left join empty x on e.id = x.id where e.id < 3",
+			[]input.DataRow{{"e.id": 1}, {"e.id": 2}},
+		},
+	}, events, WithTable("users", users), WithTable("events", events), WithTable("empty", []input.DataRow{}))
+
+	runInvalidQueries(t, []string{
+		`select * from events join events on events.id = events.id`,
+		`select * from events e join potato p on e.id = p.id`,
+		`select * from events e join users u on count(*) = 1`,
+		`select * from events e join 'missing.ndjson' m on e.id = m.id`,
+	}, events, WithTable("users", users))
+}
+
+func TestQueriesFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "hosts.csv"), []byte("host,dc\nweb1,eu\nweb2,us\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "empty.ndjson"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := filepath.Join(dir, "hosts.csv")
+
+	runQueryTests(t, []queryTest{
+		{"select ms, dc from logs join '" + hosts + "' on logs.host = hosts.host where ms < 30", []input.DataRow{{"ms": 10, "dc": "eu"}, {"ms": 20, "dc": "us"}}},
+		{"select host from '" + hosts + "' where dc = 'eu'", []input.DataRow{{"host": "web1"}}},
+		{"select ms from logs where host in (select host from '" + hosts + "' where dc = 'us')", []input.DataRow{{"ms": 20}, {"ms": 700}}},
+		{"select count(*) as n from '" + filepath.Join(dir, "empty.ndjson") + "'", []input.DataRow{{"n": int64(0)}}},
+	}, hostLogs)
+}
+
+func TestReadsPipedRows(t *testing.T) {
+	for sql, expected := range map[string]bool{
+		"select foo":                                  true,
+		"select foo from logs":                        true,
+		"select foo from 'a.ndjson'":                  false,
+		"select foo from 'a.ndjson' union select foo": true,
+		"select a.id from 'a.ndjson' a join 'b.csv' b on a.id = b.id":                                        false,
+		"select id from 'a.ndjson' where id in (select id from admins)":                                      false,
+		"select id from 'a.ndjson' where id in (select id from logs)":                                        true,
+		"with t as (select id from 'a.ndjson') select id from t join (select 1 as id) o on t.id = o.id":      false,
+		"with t as (select id from logs) select id from t":                                                   true,
+		"select l.id from 'a.ndjson' l cross join lateral (select count(*) as n from logs where x = l.id) t": true,
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reads := NewExecutor(*query, WithTable("admins", nil)).ReadsPipedRows(); reads != expected {
+			t.Errorf("%s: expected %t got %t", sql, expected, reads)
+		}
+	}
+}
+
+func TestQueriesSubqueries(t *testing.T) {
+	var admins = []input.DataRow{
+		{"id": 1, "name": "ann"},
+		{"id": 3, "name": "cat"},
+		{"id": nil, "name": "root"},
+	}
+
+	var data = []input.DataRow{
+		{"user_id": 1, "ms": 10},
+		{"user_id": 2, "ms": 20},
+		{"user_id": 3, "ms": 60},
+		{"user_id": 1, "ms": 30},
+		{"user_id": nil, "ms": 5},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			// the null id of root matches no row, the row without a user matches no id
+			"select ms where user_id in (select id from admins)",
+			[]input.DataRow{{"ms": 10}, {"ms": 60}, {"ms": 30}},
+		},
+		{
+			"select ms where user_id in (1, null)",
+			[]input.DataRow{{"ms": 10}, {"ms": 30}},
+		},
+		{
+			"select ms where user_id in (select id from admins where name = 'nobody')",
+			nil,
+		},
+		{
+			"select ms from logs l where exists (select id from admins a where a.id = l.user_id and a.name = 'cat')",
+			[]input.DataRow{{"ms": 60}},
+		},
+		{
+			"select ms where exists (select id from admins where name = 'nobody')",
+			nil,
+		},
+		{
+			// the from table of the outer query can be read by name
+			"select ms, ms - (select avg(ms) from logs) as delta from logs where ms > (select avg(ms) from logs)",
+			[]input.DataRow{{"ms": 60, "delta": 35.0}, {"ms": 30, "delta": 5.0}},
+		},
+		{
+			// a scalar subquery without rows is null
+			"select user_id, (select name from admins a where a.id = l.user_id) as name from logs l where ms < 30",
+			[]input.DataRow{{"user_id": 1, "name": "ann"}, {"user_id": 2, "name": nil}, {"user_id": nil, "name": nil}},
+		},
+		{
+			"select t.user_id, t.total from (select user_id, sum(ms) as total from logs group by user_id) t where t.total > 20",
+			[]input.DataRow{{"t.user_id": int64(1), "t.total": int64(40)}, {"t.user_id": int64(3), "t.total": int64(60)}},
+		},
+		{
+			"select name, total from admins a join (select user_id, sum(ms) as total from logs group by user_id) t on a.id = t.user_id",
+			[]input.DataRow{{"name": "ann", "total": int64(40)}, {"name": "cat", "total": int64(60)}},
+		},
+	}, data, WithTable("admins", admins))
+
+	runInvalidQueries(t, []string{
+		"select ms where user_id in (select id, name from admins)",
+		"select ms where user_id in (select * from admins)",
+		"select (select id from admins) as id",
+		"select (select potato(id) from admins) as id",
+		// the first name that isn't registered is the piped rows, every other name has to be registered
+		"select ms from logs where user_id in (select id from potatoes)",
+		"select t.x from (select potato(x) as x) t",
+	}, data, WithTable("admins", admins))
+}
+
+func TestQueriesCommonTables(t *testing.T) {
+	var spans = []input.DataRow{
+		{"id": 1, "parent": nil, "name": "request", "ms": 100},
+		{"id": 2, "parent": 1, "name": "auth", "ms": 10},
+		{"id": 3, "parent": 1, "name": "query", "ms": 70},
+		{"id": 4, "parent": 3, "name": "connect", "ms": 20},
+		{"id": 5, "parent": nil, "name": "health", "ms": 1},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			"with a (span, took) as (select id, ms from spans where parent = 1), b as (select span from a where took > 20) select span from b",
+			[]input.DataRow{{"span": 3}},
+		},
+		{
+			// a table used twice is read twice but only run once
+			"with roots as (select id, name from spans where id in (1, 5)) select a.name, b.name from roots a join roots b on a.id < b.id",
+			[]input.DataRow{{"a.name": "request", "b.name": "health"}},
+		},
+		{
+			"with none as (select id from spans where ms > 1000) select count(*) as n from none",
+			[]input.DataRow{{"n": int64(0)}},
+		},
+		{
+			"with recursive tree (id, depth) as (select id, 0 as depth from spans where id = 1 union all select s.id, t.depth + 1 from spans s join tree t on s.parent = t.id) select id, depth from tree",
+			[]input.DataRow{{"id": 1, "depth": int64(0)}, {"id": 2, "depth": int64(1)}, {"id": 3, "depth": int64(1)}, {"id": 4, "depth": int64(2)}},
+		},
+		{
+			// the ancestors of connect stop at the null parent of the root
+			"with recursive up as (select id, parent from spans where name = 'connect' union select s.id, s.parent from spans s join up u on s.id = u.parent) select id from up",
+			[]input.DataRow{{"id": 4}, {"id": 3}, {"id": 1}},
+		},
+		{
+			// a recursive table that starts without rows never runs its recursive term
+			"with recursive tree as (select id from spans where id > 10 union all select s.id from spans s join tree t on s.parent = t.id) select id from tree",
+			nil,
+		},
+		{
+			// union leaves out rows the table already has, so walking a cycle finishes
+			"with recursive loop (n) as (select 1 as n union select (n + 1) % 3 from loop) select n from loop",
+			[]input.DataRow{{"n": int64(1)}, {"n": int64(2)}, {"n": int64(0)}},
+		},
+	}, spans)
+
+	runInvalidQueries(t, []string{
+		"with a as (select id from spans), a as (select id from spans) select id from a",
+		"with a (x, y) as (select id from spans) select x from a",
+		"with a (x) as (select * from spans) select x from a",
+		"with a as (select potato(id) as id from spans) select id from a",
+		"with recursive a as (select id from spans union all select id, name from a) select id from a",
+		// union all keeps finding the same rows so it never finishes
+		"with recursive loop (n) as (select 1 as n union all select (n + 1) % 3 from loop) select n from loop",
+	}, spans)
+}
+
+func TestQueriesSetOperations(t *testing.T) {
+	runQueryTests(t, []queryTest{
+		{
+			"select host from logs where status = 500 union select host from logs where ms > 500",
+			[]input.DataRow{{"host": "web1"}, {"host": "web2"}, {"host": "web3"}},
+		},
+		{
+			"select host from logs where status = 500 union all select host from logs where ms > 500",
+			[]input.DataRow{{"host": "web1"}, {"host": "web2"}, {"host": "web2"}, {"host": "web1"}, {"host": "web2"}, {"host": "web3"}},
+		},
+		{
+			"select host from logs where status = 500 intersect all select host from logs where ms > 500",
+			[]input.DataRow{{"host": "web1"}, {"host": "web2"}},
+		},
+		{
+			"select host from logs except all select host from logs where status = 500",
+			[]input.DataRow{{"host": "web1"}, {"host": "web3"}},
+		},
+		{
+			// the columns are named after the first query
+			"select host as name, ms from logs where ms > 800 union select upper(host), status from logs where status = 200",
+			[]input.DataRow{{"name": "web1", "ms": 900}, {"name": "WEB1", "ms": 200}, {"name": "WEB3", "ms": 200}},
+		},
+		{
+			// intersect is applied before union
+			"select host from logs where ms > 850 union select host from logs where status = 200 intersect select host from logs where status = 500",
+			[]input.DataRow{{"host": "web1"}},
+		},
+		{
+			// nulls are the same as each other, so union keeps one
+			"select case when ms > 5000 then host end as slow from logs where ms < 30 union select null from logs where ms > 850",
+			[]input.DataRow{{"slow": nil}},
+		},
+		{
+			// an empty side leaves a union with the other side and an intersect with nothing
+			"select host from logs where ms > 5000 union select host from logs where ms > 850",
+			[]input.DataRow{{"host": "web1"}},
+		},
+		{
+			"select host from logs intersect select host from logs where ms > 5000",
+			nil,
+		},
+		{
+			"select host, count(*) as n from logs group by host union select 'all', count(*) from logs",
+			[]input.DataRow{{"host": "web1", "n": int64(2)}, {"host": "web2", "n": int64(2)}, {"host": "web3", "n": int64(1)}, {"host": "all", "n": int64(5)}},
+		},
+	}, hostLogs)
+
+	runInvalidQueries(t, []string{
+		"select host from logs union select host, ms from logs",
+		"select * from logs except select host from logs",
+		"select host from logs intersect select potato(host) from logs",
+	}, hostLogs)
+}
+
+func TestQueriesOrderBy(t *testing.T) {
+	var data = []input.DataRow{
+		{"id": 1, "host": "web2", "ms": 20},
+		{"id": 2, "host": "web1", "ms": nil},
+		{"id": 3, "host": "web1", "ms": 900},
+		{"id": 4, "host": "web3", "ms": 20},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			// nulls sort last and rows that order the same keep their order
+			"select id, ms from logs order by ms",
+			[]input.DataRow{{"id": 1, "ms": 20}, {"id": 4, "ms": 20}, {"id": 3, "ms": 900}, {"id": 2, "ms": nil}},
+		},
+		{
+			// descending reverses the whole order, nulls included
+			"select id, ms from logs order by ms desc, id desc",
+			[]input.DataRow{{"id": 2, "ms": nil}, {"id": 3, "ms": 900}, {"id": 4, "ms": 20}, {"id": 1, "ms": 20}},
+		},
+		{
+			"select host, count(*) as n from logs group by host order by n desc, host",
+			[]input.DataRow{{"host": "web1", "n": int64(2)}, {"host": "web2", "n": int64(1)}, {"host": "web3", "n": int64(1)}},
+		},
+		{
+			"select id as x from logs where id < 3 union all select id * 10 from logs where id > 2 order by x desc",
+			[]input.DataRow{{"x": int64(40)}, {"x": int64(30)}, {"x": 2}, {"x": 1}},
+		},
+		{
+			"select id from (select id, ms from logs order by ms desc) t where id != 3",
+			[]input.DataRow{{"id": 2}, {"id": 1}, {"id": 4}},
+		},
+		{
+			"select id from logs where id > 10 order by id",
+			nil,
+		},
+	}, data)
+
+	runInvalidQueries(t, []string{
+		"select id from logs order by ms",
+		"select host, count(*) as n from logs group by host order by count(*)",
+		"select id from logs order by potato(id)",
+	}, data)
+}
+
+func TestQueriesArrays(t *testing.T) {
+	var data = []input.DataRow{
+		{"id": 1, "tags": []interface{}{"web", "slow"}, "events": []interface{}{map[string]interface{}{"name": "start", "ms": json.Number("5")}}},
+		{"id": 2, "tags": []interface{}{}, "events": nil},
+		{"id": 3, "tags": []string{"db"}, "events": []interface{}{map[string]interface{}{"name": "start", "ms": json.Number("1")}, map[string]interface{}{"name": "stop", "ms": json.Number("9")}}},
+		{"id": 4, "tags": nil},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			// empty and null arrays have no elements to select
+			"select id, unnest(tags) as tag from logs",
+			[]input.DataRow{{"id": 1, "tag": "web"}, {"id": 1, "tag": "slow"}, {"id": 3, "tag": "db"}},
+		},
+		{
+			"select tag, count(*) as n from logs cross join lateral unnest(tags) tag where tag != 'web' group by tag",
+			[]input.DataRow{{"tag": "slow", "n": int64(1)}, {"tag": "db", "n": int64(1)}},
+		},
+		{
+			// the keys of objects are columns of the unnested table
+			"select l.id, e.name from logs l left join unnest(l.events) e on e.ms > 2",
+			[]input.DataRow{{"l.id": 1, "e.name": "start"}, {"l.id": 2}, {"l.id": 3, "e.name": "stop"}, {"l.id": 4}},
+		},
+		{
+			"select l.id, t.n from logs l cross join lateral (select count(*) as n from logs o where o.id < l.id) t where l.id > 2",
+			[]input.DataRow{{"l.id": 3, "t.n": int64(2)}, {"l.id": 4, "t.n": int64(3)}},
+		},
+		{
+			"select id from logs where any(tags) = 'slow' or 'db' = any(tags)",
+			[]input.DataRow{{"id": 1}, {"id": 3}},
+		},
+		{
+			"select id, array_length(tags) as n, element_at(tags, 1) as first, element_at(tags, -1) as last, element_at(tags, 5) as none from logs",
+			[]input.DataRow{
+				{"id": 1, "n": int64(2), "first": "web", "last": "slow", "none": nil},
+				{"id": 2, "n": int64(0), "first": nil, "last": nil, "none": nil},
+				{"id": 3, "n": int64(1), "first": "db", "last": "db", "none": nil},
+				{"id": 4, "n": nil, "first": nil, "last": nil, "none": nil},
+			},
+		},
+		{
+			"select id, element_at(element_at(events, 1), 'name') as first from logs where array_contains(tags, 'db') = true",
+			[]input.DataRow{{"id": 3, "first": "start"}},
+		},
+		{
+			"select array_agg(id) as ids from logs where id != 2",
+			[]input.DataRow{{"ids": []interface{}{int64(1), int64(3), int64(4)}}},
+		},
+		{
+			"select array_agg(id) as ids from logs where id > 10",
+			[]input.DataRow{{"ids": nil}},
+		},
+		{
+			// arrays compare element by element
+			"select a.id from logs a join logs b on a.tags > b.tags where b.id = 3",
+			[]input.DataRow{{"a.id": 1}},
+		},
+	}, data)
+
+	runInvalidQueries(t, []string{
+		"select id from logs where unnest(tags) = 'x'",
+		"select any(tags) from logs",
+		"select id from logs where any(tags) = any(tags)",
+		"select id from logs where any(tags)",
+		"select id from unnest(tags) t",
+		"select id from logs right join unnest(tags) t on t = 'x'",
+		"select id, array_length(id) as n from logs",
+		"select id, element_at(tags, 0) as n from logs where id = 1",
+		"select id, unnest(id) as n from logs",
+	}, data)
+
+	// the values of a parallel array_agg keep the order of the rows
+	var rows []input.DataRow
+	for i := 0; i < 100; i++ {
+		rows = append(rows, input.DataRow{"n": i})
+	}
+
+	query, err := Parse("select array_agg(n) as ns from logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query, Parallelism(4)).QueryData(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns := result[0]["ns"].([]interface{})
+	for i, n := range ns {
+		if n != int64(i) {
+			t.Fatalf("expected the values in order but got %v", ns)
+		}
+	}
+}
+
+func TestQueriesJson(t *testing.T) {
+	var data = []input.DataRow{
+		{
+			"id":      1,
+			"request": map[string]interface{}{"path": "/a", "headers": map[string]interface{}{"x-id": "r1"}, "sizes": []interface{}{json.Number("10"), json.Number("20")}},
+			"payload": `{"user": {"id": 7, "roles": ["admin"]}, "ok": true}`,
+		},
+		{
+			"id":      2,
+			"request": map[string]interface{}{"path": "/b", "sizes": []interface{}{}},
+			"payload": `{"user": null, "ok": false}`,
+		},
+		{"id": 3, "request": nil, "payload": nil},
+		{"id": 4, "request": map[string]interface{}{}, "payload": `{"user": `},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			// a path that isn't there and a null document are both null
+			"select json_extract(request, '$.path') as path, json_extract(request, '$.sizes[-1]') as last, json_extract(request, '$.headers.x-id') as rid from logs where id < 4",
+			[]input.DataRow{{"path": "/a", "last": int64(20), "rid": "r1"}, {"path": "/b", "last": nil, "rid": nil}, {"path": nil, "last": nil, "rid": nil}},
+		},
+		{
+			// a payload logged as a string is read with parse_json, the malformed one is filtered out first
+			"select id, json_extract(parse_json(payload), '$.user.roles[0]') as role from logs where json_extract(try_parse_json(payload), '$.ok') = true",
+			[]input.DataRow{{"id": 1, "role": "admin"}},
+		},
+		{
+			// malformed json in one row is null rather than failing every row
+			"select id, json_extract(try_parse_json(payload), '$.ok') as ok, try_parse_json(id) as n from logs",
+			[]input.DataRow{{"id": 1, "ok": true, "n": nil}, {"id": 2, "ok": false, "n": nil}, {"id": 3, "ok": nil, "n": nil}, {"id": 4, "ok": nil, "n": nil}},
+		},
+		{
+			"select json_keys(request) as keys, json_type(json_extract(request, '$.headers')) as headers from logs where id < 3",
+			[]input.DataRow{
+				{"keys": []interface{}{"headers", "path", "sizes"}, "headers": "object"},
+				{"keys": []interface{}{"path", "sizes"}, "headers": "null"},
+			},
+		},
+		{
+			"select json_type(id) as a, json_type(payload) as b, json_type(parse_json(payload)) as c, json_type(json_extract(request, '$.sizes')) as d, json_type(json_extract(parse_json(payload), '$.ok')) as e from logs where id = 1",
+			[]input.DataRow{{"a": "number", "b": "string", "c": "object", "d": "array", "e": "boolean"}},
+		},
+		{
+			"select to_json(json_object('id', id, 'path', json_extract(request, '$.path'), 'tags', json_array('a', 1, null))) as out from logs where id = 2",
+			[]input.DataRow{{"out": `{"id":2,"path":"/b","tags":["a",1,null]}`}},
+		},
+		{
+			"select to_json(null) as a, to_json('x') as b, to_json(request) as c from logs where id = 2",
+			[]input.DataRow{{"a": "null", "b": `"x"`, "c": `{"path":"/b","sizes":[]}`}},
+		},
+		{
+			"select object_agg(json_extract(request, '$.path'), id) as paths from logs where id < 3",
+			[]input.DataRow{{"paths": map[string]interface{}{"/a": int64(1), "/b": int64(2)}}},
+		},
+	}, data)
+
+	runInvalidQueries(t, []string{
+		"select json_extract(request, 'path') as x from logs",
+		"select parse_json(id) as x from logs",
+		"select parse_json(payload) as x from logs",
+		"select json_keys(id) as x from logs",
+		"select json_object('a') as x from logs",
+		"select json_object(null, 1) as x from logs",
+		"select object_agg(id) as x from logs",
+	}, data)
+
+	for path, expected := range map[string][]interface{}{
+		"$":                 nil,
+		"$.a.b[0]":          {"a", "b", 0},
+		"$['a key'][-1].c":  {"a key", -1, "c"},
+		`$["x.y"]`:          {"x.y"},
+		"$.headers.x-id[1]": {"headers", "x-id", 1},
+	} {
+		steps, err := parseJsonPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(steps, expected) {
+			t.Errorf("%s: expected %v got %v", path, expected, steps)
+		}
+	}
+
+	for _, path := range []string{"a.b", "$.", "$[0", "$[x]", "$a"} {
+		if _, err := parseJsonPath(path); err == nil {
+			t.Errorf("expected %s to fail", path)
+		}
+	}
+}
+
+func TestQueriesPrepared(t *testing.T) {
+	var data = []input.DataRow{
+		{"id": 1, "user": "bob", "status": 200, "ms": 10},
+		{"id": 2, "user": "o'neil", "status": 500, "ms": 900},
+		{"id": 3, "user": "bob", "status": 500, "ms": 20},
+		{"id": 4, "user": "007", "status": 200, "ms": 700},
+		{"id": 5, "user": nil, "status": 200, "ms": 1},
+	}
+
+	for _, test := range []struct {
+		sql      string
+		args     []interface{}
+		expected []input.DataRow
+	}{
+		{
+			// values are never quoted into the query
+			"select id from logs where user = $user",
+			[]interface{}{Named("user", "o'neil")},
+			[]input.DataRow{{"id": 2}},
+		},
+		{
+			// a bound string stays a string
+			"select id from logs where user = ?",
+			[]interface{}{"007"},
+			[]input.DataRow{{"id": 4}},
+		},
+		{
+			"select id from logs where user = ?",
+			[]interface{}{7},
+			nil,
+		},
+		{
+			// a bound null matches nothing, like a null literal
+			"select id from logs where user = ?",
+			[]interface{}{nil},
+			nil,
+		},
+		{
+			"select id, ms * ? as scaled from logs where ms > $min and status = $status and ms < $min * 50",
+			[]interface{}{2, Named("$min", 15), Named("status", int32(500))},
+			[]input.DataRow{{"id": 3, "scaled": int64(40)}},
+		},
+		{
+			"select id from logs where id in ? and user != $user",
+			[]interface{}{[]int{1, 2, 3}, Named("user", "bob")},
+			[]input.DataRow{{"id": 2}},
+		},
+		{
+			"select id from logs where id in ?",
+			[]interface{}{[]int{}},
+			nil,
+		},
+		{
+			// placeholders in an in list are bound one by one
+			"select id from logs where id in (?, $id, 4) and user != ?",
+			[]interface{}{1, Named("id", 3), "bob"},
+			[]input.DataRow{{"id": 4}},
+		},
+		{
+			"select id from logs where ms > (select avg(ms) from logs where status = ?)",
+			[]interface{}{500},
+			[]input.DataRow{{"id": 2}, {"id": 4}},
+		},
+		{
+			"select id, case when status = $status then 'bad' else 'good' end as health from logs where id < 3",
+			[]interface{}{Named("status", 500)},
+			[]input.DataRow{{"id": 1, "health": "good"}, {"id": 2, "health": "bad"}},
+		},
+		{
+			"select id from logs where ms < epoch(?) - 1700000000",
+			[]interface{}{time.Unix(1700000015, 0).UTC()},
+			[]input.DataRow{{"id": 1}, {"id": 5}},
+		},
+	} {
+		statement, err := Prepare(test.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := statement.QueryData(data, test.args...)
+		if err != nil {
+			t.Fatalf("%s: %s", test.sql, err)
+		}
+
+		if (len(result) > 0 || len(test.expected) > 0) && !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.sql, test.expected, result)
+		}
+	}
+
+	// a statement is bound again for every executor
+	statement, err := Prepare("select count(*) as n from logs where user = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for user, n := range map[string]int64{"bob": 2, "o'neil": 1, "alice": 0} {
+		executor, err := statement.Executor([]interface{}{user}, WithTable("logs", data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := executor.QueryData(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result[0]["n"] != n {
+			t.Errorf("%s: expected %d got %v", user, n, result)
+		}
+	}
+
+	for _, test := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"select id from logs where user = ?", nil},
+		{"select id from logs where user = ?", []interface{}{"bob", "alice"}},
+		{"select id from logs where user = $user", []interface{}{"bob"}},
+		{"select id from logs where user = $user", []interface{}{Named("name", "bob")}},
+		{"select id from logs where user = $user", []interface{}{Named("user", "bob"), Named("user", "alice")}},
+		{"select id from logs where user = ?", []interface{}{struct{}{}}},
+		{"select id from logs where id in ?", []interface{}{1}},
+		{"select id from logs where id in (?, ?)", []interface{}{1}},
+		{"select id from logs where id in (?, $id)", []interface{}{1}},
+		{"select id from logs where id in ?", []interface{}{[]interface{}{1, make(chan int)}}},
+	} {
+		statement, err := Prepare(test.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := statement.QueryData(data, test.args...); err == nil {
+			t.Errorf("expected %s with %v to fail", test.sql, test.args)
+		}
+	}
+
+	// a query with placeholders that wasn't prepared has nothing bound
+	runInvalidQueries(t, []string{"select id from logs where user = ?"}, data)
+
+	if _, err := Prepare("select id from logs where id in (1, ms + ?)"); err == nil {
+		t.Error("expected an expression in an in list to fail")
+	}
+}
+
+func TestQueriesQuotedNames(t *testing.T) {
+	var data = []input.DataRow{
+		{"id": 1, "status code": 200, "from": "web", "Name": "it's"},
+		{"id": 2, "status code": 500, "from": "db", "Name": "bob"},
+		{"id": 3, "status code": 500, "from": "web", "Name": "o'neil"},
+		{"id": 4, "status code": nil, "from": nil, "name": "lower"},
+	}
+
+	runQueryTests(t, []queryTest{
+		{
+			"SELECT id, `status code` FROM logs WHERE `status code` = 500 AND \"from\" = 'web'",
+			[]input.DataRow{{"id": 3, "status code": 500}},
+		},
+		{
+			"Select id From logs Where Name = 'it''s' Or Name = 'o''neil'",
+			[]input.DataRow{{"id": 1}, {"id": 3}},
+		},
+		{
+			// a null is grouped like any other value
+			"select `from`, count(*) as `total rows` from logs group by `from`",
+			[]input.DataRow{{"from": "web", "total rows": int64(2)}, {"from": "db", "total rows": int64(1)}, {"from": nil, "total rows": int64(1)}},
+		},
+		{
+			// names keep their case, so Name is missing from the row that only has name
+			"select Name from logs where id > 2",
+			[]input.DataRow{{"Name": "o'neil"}, {}},
+		},
+	}, data)
+}
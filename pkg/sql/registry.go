@@ -0,0 +1,221 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Registry holds the functions library users add on top of the builtins, pass it to an executor with WithRegistry
+type Registry struct {
+	scalars    map[string]ScalarUDF
+	aggregates map[string]AggregateUDF
+}
+
+// ScalarUDF is a user defined function called once per row. Arguments arrive as the go representation of their
+// declared type and a null argument makes the result null without calling the function
+type ScalarUDF struct {
+	Args []Type
+	// Variadic lets the last argument repeat any number of times
+	Variadic bool
+	Returns  Type
+	Call     func(args []interface{}) (interface{}, error)
+}
+
+// AggregateUDF is a user defined aggregate, Init makes an empty Aggregate for every group. Arguments arrive as the go
+// representation of their declared type and rows with a null argument are skipped
+type AggregateUDF struct {
+	Args    []Type
+	Returns Type
+	Init    func() Aggregate
+}
+
+var functionName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+func NewRegistry() *Registry {
+	return &Registry{scalars: map[string]ScalarUDF{}, aggregates: map[string]AggregateUDF{}}
+}
+
+// WithRegistry makes the functions of the registry callable from the query
+func WithRegistry(registry *Registry) ExecutorOption {
+	return func(e *Executor) {
+		e.registry = registry
+	}
+}
+
+// RegisterScalar adds a scalar function, names are case insensitive and can't shadow a builtin
+func (r *Registry) RegisterScalar(name string, udf ScalarUDF) error {
+	name, err := r.checkName(name)
+	if err != nil {
+		return err
+	}
+
+	if udf.Call == nil {
+		return errors.New(fmt.Sprintf("%s has no implementation", name))
+	}
+
+	if udf.Variadic && len(udf.Args) == 0 {
+		return errors.New(fmt.Sprintf("variadic function %s needs at least one argument type", name))
+	}
+
+	if udf.Args, udf.Returns, err = signature(name, udf.Args, udf.Returns); err != nil {
+		return err
+	}
+
+	r.scalars[name] = udf
+
+	return nil
+}
+
+// RegisterAggregate adds an aggregate function, names are case insensitive and can't shadow a builtin
+func (r *Registry) RegisterAggregate(name string, udf AggregateUDF) error {
+	name, err := r.checkName(name)
+	if err != nil {
+		return err
+	}
+
+	if udf.Init == nil {
+		return errors.New(fmt.Sprintf("%s has no implementation", name))
+	}
+
+	if len(udf.Args) == 0 {
+		return errors.New(fmt.Sprintf("aggregate %s needs at least one argument", name))
+	}
+
+	if udf.Args, udf.Returns, err = signature(name, udf.Args, udf.Returns); err != nil {
+		return err
+	}
+
+	r.aggregates[name] = udf
+
+	return nil
+}
+
+// checks every type of a signature, aliases like int are stored as the type they alias
+func signature(name string, args []Type, returns Type) ([]Type, Type, error) {
+	parsed := make([]Type, len(args))
+
+	for i, arg := range args {
+		var err error
+
+		if parsed[i], err = ParseType(string(arg)); err != nil {
+			return nil, "", errors.New(fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	returns, err := ParseType(string(returns))
+	if err != nil {
+		return nil, "", errors.New(fmt.Sprintf("%s: %s", name, err))
+	}
+
+	return parsed, returns, nil
+}
+
+func (r *Registry) checkName(name string) (string, error) {
+	name = strings.ToLower(name)
+
+	if !functionName.MatchString(name) {
+		return "", errors.New(fmt.Sprintf("%s is not a valid function name", name))
+	}
+
+	if _, ok := scalarFunctions[name]; ok || isAggregate(name) || name == "cast" || name == "try_cast" || name == "extract" {
+		return "", errors.New(fmt.Sprintf("%s is a builtin function", name))
+	}
+
+	_, scalar := r.scalars[name]
+	_, aggregate := r.aggregates[name]
+
+	if scalar || aggregate {
+		return "", errors.New(fmt.Sprintf("%s is already registered", name))
+	}
+
+	return name, nil
+}
+
+func (r *Registry) scalar(name string) (ScalarUDF, bool) {
+	if r == nil {
+		return ScalarUDF{}, false
+	}
+
+	udf, ok := r.scalars[strings.ToLower(name)]
+
+	return udf, ok
+}
+
+func (r *Registry) aggregate(name string) (AggregateUDF, bool) {
+	if r == nil {
+		return AggregateUDF{}, false
+	}
+
+	udf, ok := r.aggregates[strings.ToLower(name)]
+
+	return udf, ok
+}
+
+func (u ScalarUDF) maxArgs() int {
+	return tern(u.Variadic, -1, len(u.Args))
+}
+
+// the declared type of the ith argument, variadic functions repeat their last one
+func (u ScalarUDF) argType(i int) Type {
+	if i >= len(u.Args) {
+		return u.Args[len(u.Args)-1]
+	}
+
+	return u.Args[i]
+}
+
+// adapts the udf to a builtin, binding each argument to its declared type and checking what it returns
+func (u ScalarUDF) function(name string) scalarFunction {
+	return scalarFunction{
+		minArgs: len(u.Args),
+		maxArgs: u.maxArgs(),
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			var bound []interface{}
+
+			for i, arg := range args {
+				value, err := bindValue(arg, u.argType(i), s.lenient)
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("argument %d of %s: %s", i+1, name, err))
+				}
+
+				bound = append(bound, value)
+			}
+
+			result, err := u.Call(bound)
+			if err != nil {
+				return nil, err
+			}
+
+			returned, err := bindValue(result, u.Returns, false)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("%s returned the wrong type: %s", name, err))
+			}
+
+			return returned, nil
+		},
+	}
+}
+
+// converts a value to a declared type, numbers widen to doubles and decimals and lenient mode casts anything that can be
+func bindValue(value interface{}, to Type, lenient bool) (interface{}, error) {
+	value = normalize(value)
+
+	from, ok := typeOf(value)
+	if value == nil || (ok && from == to) {
+		return value, nil
+	}
+
+	if lenient || (ok && assignable(from, to)) {
+		return CastValue(value, to)
+	}
+
+	return nil, errors.New(fmt.Sprintf("expected %s but got %v (%s)", to, value, tern(ok, string(from), reflect.TypeOf(value).String())))
+}
+
+// true when a value of one type can be passed where another is declared without losing its meaning
+func assignable(from Type, to Type) bool {
+	return from == to || (from == BigInt && (to == Double || to == Decimal)) || (from == Decimal && to == Double)
+}
@@ -0,0 +1,313 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tenantRegistry(t *testing.T) *Registry {
+	registry := NewRegistry()
+
+	err := registry.RegisterScalar("tenant_of", ScalarUDF{
+		Args:    []Type{Varchar},
+		Returns: Varchar,
+		Call: func(args []interface{}) (interface{}, error) {
+			return strings.SplitN(args[0].(string), ":", 2)[0], nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = registry.RegisterScalar("clamp", ScalarUDF{
+		Args:    []Type{Double, Double, Double},
+		Returns: "float",
+		Call: func(args []interface{}) (interface{}, error) {
+			value, low, high := args[0].(float64), args[1].(float64), args[2].(float64)
+			return tern(value < low, low, tern(value > high, high, value)), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = registry.RegisterScalar("join_all", ScalarUDF{
+		Args:     []Type{Varchar},
+		Variadic: true,
+		Returns:  Varchar,
+		Call: func(args []interface{}) (interface{}, error) {
+			var parts []string
+			for _, arg := range args {
+				parts = append(parts, arg.(string))
+			}
+
+			return strings.Join(parts, "+"), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return registry
+}
+
+func TestQueriesRegisteredFunctions(t *testing.T) {
+	query, err := Parse(`select tenant_of(id) as tenant, count(*) as n, max(clamp(ms, 0, 100)) as ms where tenant_of(id) != 'c' group by tenant`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query, WithRegistry(tenantRegistry(t))).QueryData([]input.DataRow{
+		{"id": "a:1", "ms": 50},
+		{"id": "a:2", "ms": 500},
+		{"id": "b:1", "ms": 5.5},
+		{"id": "c:1", "ms": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{
+		{"tenant": "a", "n": int64(2), "ms": 100.0},
+		{"tenant": "b", "n": int64(1), "ms": 5.5},
+	}) {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
+
+func TestRegisteredFunctionsAreVariadicAndPropagateNulls(t *testing.T) {
+	query, err := Parse(`select JOIN_ALL('a', b, 'c') as joined`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query, WithRegistry(tenantRegistry(t))).QueryData([]input.DataRow{{"b": "b"}, {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result[0]["joined"] != "a+b+c" || result[1]["joined"] != nil {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
+
+func TestRegisteringRejectsBadFunctions(t *testing.T) {
+	registry := tenantRegistry(t)
+	call := func(args []interface{}) (interface{}, error) { return nil, nil }
+
+	for name, udf := range map[string]ScalarUDF{
+		"lower":     {Args: []Type{Varchar}, Returns: Varchar, Call: call},
+		"count":     {Args: []Type{Varchar}, Returns: Varchar, Call: call},
+		"tenant_of": {Args: []Type{Varchar}, Returns: Varchar, Call: call},
+		"bad name":  {Args: []Type{Varchar}, Returns: Varchar, Call: call},
+		"no_call":   {Args: []Type{Varchar}, Returns: Varchar},
+		"bad_type":  {Args: []Type{"potato"}, Returns: Varchar, Call: call},
+		"variadic":  {Variadic: true, Returns: Varchar, Call: call},
+	} {
+		if err := registry.RegisterScalar(name, udf); err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestValidatesBeforeReadingRows(t *testing.T) {
+	for _, sql := range []string{
+		`select potato(x) as y`,
+		`select tenant_of(x, y) as y`,
+		`select tenant_of(1) as y`,
+		`select clamp(x, 'low', 1) as y`,
+		`select tenant_of(cast(x as bigint)) as y`,
+		`select x where count(x) > 1`,
+		`select x group by sum(x)`,
+		`select sum(count(x)) as y`,
+		`select x where lower(x, y) = 'a'`,
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := NewExecutor(*query, WithRegistry(tenantRegistry(t))).Validate(); err == nil {
+			t.Errorf("expected %s to fail validation", sql)
+		}
+	}
+
+	query, err := Parse(`select tenant_of(x) as y, clamp(1, 2.5, cast(z as decimal)) as z`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewExecutor(*query, WithRegistry(tenantRegistry(t))).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisteredFunctionsCheckTypesPerRow(t *testing.T) {
+	registry := tenantRegistry(t)
+
+	err := registry.RegisterScalar("liar", ScalarUDF{
+		Args:    []Type{BigInt},
+		Returns: BigInt,
+		Call: func(args []interface{}) (interface{}, error) {
+			return "not a number", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = registry.RegisterScalar("broken", ScalarUDF{
+		Args:    []Type{BigInt},
+		Returns: BigInt,
+		Call: func(args []interface{}) (interface{}, error) {
+			return nil, errors.New("broken")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sql := range []string{`select tenant_of(x) as y`, `select liar(1) as y`, `select broken(1) as y`} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query, WithRegistry(registry)).QueryData([]input.DataRow{{"x": 1}}); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+
+	query, err := Parse(`select tenant_of(x) as y`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query, WithRegistry(registry), LenientTypes()).QueryData([]input.DataRow{{"x": 1}})
+	if err != nil || result[0]["y"] != "1" {
+		t.Logf("%v %v", result, err)
+		t.Fail()
+	}
+}
+
+type weightedAverage struct {
+	total  float64
+	weight float64
+}
+
+func (w *weightedAverage) Accumulate(args []interface{}) error {
+	w.total += args[0].(float64) * args[1].(float64)
+	w.weight += args[1].(float64)
+
+	return nil
+}
+
+func (w *weightedAverage) Merge(other Aggregate) error {
+	partial := other.(*weightedAverage)
+
+	w.total += partial.total
+	w.weight += partial.weight
+
+	return nil
+}
+
+func (w *weightedAverage) Finalize() (interface{}, error) {
+	if w.weight == 0 {
+		return nil, nil
+	}
+
+	return w.total / w.weight, nil
+}
+
+func TestQueriesRegisteredAggregates(t *testing.T) {
+	registry := tenantRegistry(t)
+
+	err := registry.RegisterAggregate("weighted_avg", AggregateUDF{
+		Args:    []Type{Double, Double},
+		Returns: Double,
+		Init:    func() Aggregate { return &weightedAverage{} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := Parse(`select tenant_of(id) as tenant, weighted_avg(ms, n) as ms group by tenant`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []input.DataRow{
+		{"id": "a:1", "ms": 10, "n": 1},
+		{"id": "a:2", "ms": 40, "n": 2},
+		{"id": "a:3", "ms": 1000},
+		{"id": "b:1", "ms": 5.5, "n": 4},
+		{"id": "b:2", "ms": 7.5, "n": 4},
+	}
+
+	expected := []input.DataRow{
+		{"tenant": "a", "ms": 30.0},
+		{"tenant": "b", "ms": 6.5},
+	}
+
+	for _, workers := range []int{1, 2, 8} {
+		result, err := NewExecutor(*query, WithRegistry(registry), Parallelism(workers)).QueryData(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Logf("%d workers: %v", workers, result)
+			t.Fail()
+		}
+	}
+
+	for _, sql := range []string{`select weighted_avg(ms) as x`, `select weighted_avg('a', 1) as x`, `select x where weighted_avg(x, 1) > 1`} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := NewExecutor(*query, WithRegistry(registry)).Validate(); err == nil {
+			t.Errorf("expected %s to fail validation", sql)
+		}
+	}
+
+	if err := registry.RegisterAggregate("weighted_avg", AggregateUDF{Args: []Type{Double}, Returns: Double, Init: func() Aggregate { return &weightedAverage{} }}); err == nil {
+		t.Error("expected a duplicate aggregate to be rejected")
+	}
+}
+
+func TestParallelBuiltinAggregatesMatchSequential(t *testing.T) {
+	var rows []input.DataRow
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, input.DataRow{"g": i % 3, "x": i, "f": float64(i) / 4, "s": fmt.Sprintf("%04d", i)})
+	}
+
+	rows = append(rows, input.DataRow{"g": 1})
+
+	query, err := Parse(`select g, count(*) as n, count(x) as xs, sum(x) as sum, avg(f) as avg, min(s) as min, max(x) as max, sum(x) / count(x) as mean, lower(min(s)) as lower group by g`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sequential, err := NewExecutor(*query).QueryData(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parallel, err := NewExecutor(*query, Parallelism(7)).QueryData(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(sequential, parallel) || sequential[1]["n"] != int64(334) || sequential[1]["xs"] != int64(333) {
+		t.Logf("%v\n%v", sequential, parallel)
+		t.Fail()
+	}
+}
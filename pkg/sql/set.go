@@ -0,0 +1,144 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"slices"
+)
+
+type SetOperator string
+
+const (
+	Union     SetOperator = "union"
+	Intersect SetOperator = "intersect"
+	Except    SetOperator = "except"
+)
+
+// union, intersect or except with the query before it. Without all the result has no duplicate rows
+type SetOperation struct {
+	Operator SetOperator
+	All      bool `json:",omitempty"`
+	Query    Query
+}
+
+func (o SetOperation) String() string {
+	return string(o.Operator) + tern(o.All, " all ", " ") + o.Query.String()
+}
+
+type setTerm struct {
+	operator SetOperator
+	all      bool
+	rows     []input.DataRow
+}
+
+// combines the rows of the query with the rows of the queries it is combined with. Intersect is applied before union
+// and except, which are applied from left to right. Every query reads the same rows and the columns of each are named
+// after the columns of the first
+func (s *Executor) setOperations(rows []input.DataRow, data []input.DataRow) ([]input.DataRow, error) {
+	if len(s.sql.Sets) == 0 {
+		return rows, nil
+	}
+
+	keys := outputKeys(s.sql)
+	terms := []setTerm{{rows: rows}}
+
+	for _, set := range s.sql.Sets {
+		next, err := s.subExecutor(set.Query, nil).run(data)
+		if err != nil {
+			return nil, err
+		}
+
+		next = renameColumns(next, outputKeys(set.Query), keys)
+
+		if set.Operator == Intersect {
+			last := &terms[len(terms)-1]
+			last.rows = intersectRows(last.rows, next, set.All)
+			continue
+		}
+
+		terms = append(terms, setTerm{operator: set.Operator, all: set.All, rows: next})
+	}
+
+	result := terms[0].rows
+
+	for _, term := range terms[1:] {
+		switch term.operator {
+		case Union:
+			result = append(result, term.rows...)
+			if !term.all {
+				result = distinctRows(result, map[string]bool{})
+			}
+		case Except:
+			result = exceptRows(result, term.rows, term.all)
+		}
+	}
+
+	return result, nil
+}
+
+// the rows of left that are in right. With all a row is kept as many times as it is in both
+func intersectRows(left []input.DataRow, right []input.DataRow, all bool) []input.DataRow {
+	counts := rowCounts(right)
+
+	var rows []input.DataRow
+	for _, row := range left {
+		if id := rowId(row); counts[id] > 0 {
+			if all {
+				counts[id]--
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	return tern(all, rows, distinctRows(rows, map[string]bool{}))
+}
+
+// the rows of left that aren't in right. With all each row of right takes away a single row of left
+func exceptRows(left []input.DataRow, right []input.DataRow, all bool) []input.DataRow {
+	counts := rowCounts(right)
+
+	var rows []input.DataRow
+	for _, row := range left {
+		if id := rowId(row); counts[id] > 0 {
+			if all {
+				counts[id]--
+			}
+
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	return tern(all, rows, distinctRows(rows, map[string]bool{}))
+}
+
+// how many times each row is in rows
+func rowCounts(rows []input.DataRow) map[string]int {
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[rowId(row)]++
+	}
+
+	return counts
+}
+
+// renames the keys of every row to the columns in the same position, rows selected with * are left as they are
+func renameColumns(rows []input.DataRow, keys []string, columns []string) []input.DataRow {
+	if keys == nil || columns == nil || slices.Equal(keys, columns) {
+		return rows
+	}
+
+	renamed := make([]input.DataRow, len(rows))
+	for i, row := range rows {
+		renamed[i] = input.DataRow{}
+
+		for j, key := range keys {
+			if value, ok := row[key]; ok {
+				renamed[i][columns[j]] = value
+			}
+		}
+	}
+
+	return renamed
+}
@@ -0,0 +1,264 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	for name, function := range stringFunctions {
+		scalarFunctions[name] = function
+	}
+}
+
+// wraps a function that only takes string arguments
+func stringFunction(minArgs int, maxArgs int, call func(args []string) (interface{}, error)) scalarFunction {
+	return scalarFunction{
+		minArgs: minArgs,
+		maxArgs: maxArgs,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			strs, err := stringArgs(args)
+			if err != nil {
+				return nil, err
+			}
+
+			return call(strs)
+		},
+	}
+}
+
+var stringFunctions = map[string]scalarFunction{
+	"lower": stringFunction(1, 1, func(args []string) (interface{}, error) {
+		return strings.ToLower(args[0]), nil
+	}),
+	"upper": stringFunction(1, 1, func(args []string) (interface{}, error) {
+		return strings.ToUpper(args[0]), nil
+	}),
+	"length": stringFunction(1, 1, func(args []string) (interface{}, error) {
+		return int64(utf8.RuneCountInString(args[0])), nil
+	}),
+	"trim": stringFunction(1, 2, func(args []string) (interface{}, error) {
+		return strings.Trim(args[0], trimCharacters(args)), nil
+	}),
+	"ltrim": stringFunction(1, 2, func(args []string) (interface{}, error) {
+		return strings.TrimLeft(args[0], trimCharacters(args)), nil
+	}),
+	"rtrim": stringFunction(1, 2, func(args []string) (interface{}, error) {
+		return strings.TrimRight(args[0], trimCharacters(args)), nil
+	}),
+	"replace": stringFunction(3, 3, func(args []string) (interface{}, error) {
+		return strings.ReplaceAll(args[0], args[1], args[2]), nil
+	}),
+	"starts_with": stringFunction(2, 2, func(args []string) (interface{}, error) {
+		return strings.HasPrefix(args[0], args[1]), nil
+	}),
+	"ends_with": stringFunction(2, 2, func(args []string) (interface{}, error) {
+		return strings.HasSuffix(args[0], args[1]), nil
+	}),
+	"contains": stringFunction(2, 2, func(args []string) (interface{}, error) {
+		return strings.Contains(args[0], args[1]), nil
+	}),
+	// substr(s, start, length) counts characters from 1
+	"substr": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			str, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			start, err := intArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			runes := []rune(str)
+
+			length := len(runes)
+			if len(args) > 2 {
+				length, err = intArg(args[2])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			from := tern(start > 0, start-1, 0)
+			to := tern(length < 0, from, start-1+length)
+
+			from = tern(from > len(runes), len(runes), from)
+			to = tern(to > len(runes), len(runes), tern(to < from, from, to))
+
+			return string(runes[from:to]), nil
+		},
+	},
+	// split_part(s, delimiter, n) is the nth piece counting from 1, or an empty string past the end
+	"split_part": {
+		minArgs: 3, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			strs, err := stringArgs(args[:2])
+			if err != nil {
+				return nil, err
+			}
+
+			index, err := intArg(args[2])
+			if err != nil {
+				return nil, err
+			}
+
+			parts := strings.Split(strs[0], strs[1])
+			if index < 1 || index > len(parts) {
+				return "", nil
+			}
+
+			return parts[index-1], nil
+		},
+	},
+	// concat skips nulls and renders anything that isn't a string as varchar
+	"concat": {
+		minArgs: 1, maxArgs: -1, acceptsNull: true,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			var builder strings.Builder
+
+			for _, arg := range args {
+				str, err := CastValue(arg, Varchar)
+				if err != nil {
+					return nil, err
+				}
+
+				if str != nil {
+					builder.WriteString(str.(string))
+				}
+			}
+
+			return builder.String(), nil
+		},
+	},
+	"lpad": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return pad(args, true)
+		},
+	},
+	"rpad": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			return pad(args, false)
+		},
+	},
+	// regexp_extract(s, pattern, group) is the group of the first match, or null when nothing matches
+	"regexp_extract": {
+		minArgs: 2, maxArgs: 3,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			strs, err := stringArgs(args[:2])
+			if err != nil {
+				return nil, err
+			}
+
+			group := 0
+			if len(args) > 2 {
+				group, err = intArg(args[2])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			pattern, err := s.regexp(strs[1])
+			if err != nil {
+				return nil, err
+			}
+
+			if group < 0 || group > pattern.NumSubexp() {
+				return nil, errors.New(fmt.Sprintf("%s has no group %d", strs[1], group))
+			}
+
+			match := pattern.FindStringSubmatch(strs[0])
+			if match == nil {
+				return nil, nil
+			}
+
+			return match[group], nil
+		},
+	},
+	// regexp_replace(s, pattern, replacement, 'g') replaces the first match, or every match with the g flag.
+	// The replacement refers to groups as \1 or $1
+	"regexp_replace": {
+		minArgs: 3, maxArgs: 4,
+		call: func(s *Executor, args []interface{}) (interface{}, error) {
+			strs, err := stringArgs(args)
+			if err != nil {
+				return nil, err
+			}
+
+			pattern, err := s.regexp(strs[1])
+			if err != nil {
+				return nil, err
+			}
+
+			replacement := backReference.ReplaceAllString(strs[2], "$${$1}")
+
+			if len(strs) > 3 && strings.Contains(strs[3], "g") {
+				return pattern.ReplaceAllString(strs[0], replacement), nil
+			}
+
+			match := pattern.FindStringSubmatchIndex(strs[0])
+			if match == nil {
+				return strs[0], nil
+			}
+
+			replaced := pattern.ExpandString(nil, replacement, strs[0], match)
+
+			return strs[0][:match[0]] + string(replaced) + strs[0][match[1]:], nil
+		},
+	},
+}
+
+var backReference = regexp.MustCompile(`\\(\d)`)
+
+func trimCharacters(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+
+	return " \t\n\r"
+}
+
+// pads or truncates a string to a length in characters, padding with spaces unless a fill is given
+func pad(args []interface{}, left bool) (interface{}, error) {
+	str, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := intArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	fill := " "
+	if len(args) > 2 {
+		fill, err = stringArg(args[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runes := []rune(str)
+	if length <= len(runes) {
+		return string(runes[:tern(length < 0, 0, length)]), nil
+	}
+
+	if fill == "" {
+		return str, nil
+	}
+
+	padding := []rune(strings.Repeat(fill, length))[:length-len(runes)]
+
+	if left {
+		return string(padding) + str, nil
+	}
+
+	return str + string(padding), nil
+}
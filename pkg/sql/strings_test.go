@@ -0,0 +1,73 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"testing"
+)
+
+func TestStringFunctions(t *testing.T) {
+	cases := map[string]interface{}{
+		`lower('AbC')`:                                      "abc",
+		`upper('AbC')`:                                      "ABC",
+		`length('héllo')`:                                   int64(5),
+		`substr('hello', 2, 3)`:                             "ell",
+		`substr('hello', 3)`:                                "llo",
+		`substr('hello', 10)`:                               "",
+		`trim('  hi  ')`:                                    "hi",
+		`trim('xxhixx', 'x')`:                               "hi",
+		`ltrim('  hi  ')`:                                   "hi  ",
+		`replace('a-b-c', '-', '+')`:                        "a+b+c",
+		`split_part('a,b,c', ',', 2)`:                       "b",
+		`split_part('a,b,c', ',', 4)`:                       "",
+		`starts_with('hello', 'he')`:                        true,
+		`ends_with('hello', 'he')`:                          false,
+		`contains('hello', 'ell')`:                          true,
+		`concat('a', null, 1, true)`:                        "a1true",
+		`lpad('7', 3, '0')`:                                 "007",
+		`rpad('ab', 5, 'xy')`:                               "abxyx",
+		`lpad('hello', 2)`:                                  "he",
+		`regexp_extract('user=42;', 'user=(\d+)', 1)`:       "42",
+		`regexp_extract('nothing', '\d+')`:                  nil,
+		`regexp_replace('a1b2', '\d', '#')`:                 "a#b2",
+		`regexp_replace('a1b2', '(\d)', '<\1>', 'g')`:       "a<1>b<2>",
+		`lower(trim('  MIXED  '))`:                          "mixed",
+		`'a' || 'b' || 1`:                                   "ab1",
+		`upper(null)`:                                       nil,
+		`split_part(lower('A:B'), ':', 1) || upper('done')`: "aDONE",
+	}
+
+	for expression, expect := range cases {
+		result, err := evalExpression(expression, input.DataRow{})
+		if err != nil {
+			t.Errorf("%s: %s", expression, err)
+			continue
+		}
+
+		if result != expect {
+			t.Errorf("%s: expected %v got %v", expression, expect, result)
+		}
+	}
+
+	if _, err := evalExpression(`lower(1)`, input.DataRow{}); err == nil {
+		t.Error("expected lower to reject a number")
+	}
+
+	if _, err := evalExpression(`regexp_extract('a', '(')`, input.DataRow{}); err == nil {
+		t.Error("expected an invalid pattern to fail")
+	}
+}
+
+// evaluates a single expression against a row
+func evalExpression(expression string, row input.DataRow) (interface{}, error) {
+	query, err := Parse("select " + expression + " as result")
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{row})
+	if err != nil {
+		return nil, err
+	}
+
+	return result[0]["result"], nil
+}
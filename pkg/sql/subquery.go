@@ -0,0 +1,317 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// an executor for a query inside this one. It reads the same tables and reads the columns of outer through the
+// names of the tables outer reads, so e.id inside reads the row of e outside
+func (s *Executor) subExecutor(query Query, outer *scope) *Executor {
+	child := &Executor{
+		sql:        query,
+		lenient:    s.lenient,
+		now:        s.now,
+		regexps:    map[string]*regexp.Regexp{},
+		registry:   s.registry,
+		workers:    s.workers,
+		tables:     s.tables,
+		ctes:       s.ctes,
+		parent:     s,
+		outer:      outer,
+		subqueries: map[*Query][]input.DataRow{},
+	}
+
+	child.legacyFunctions()
+
+	return child
+}
+
+// runs a subquery, one that doesn't read the outer row gives the same rows every time so it only runs once
+func (s *Executor) subqueryRows(sc *scope, query *Query) ([]input.DataRow, error) {
+	correlated := s.correlated(query)
+
+	if !correlated {
+		s.subqueriesLock.Lock()
+		rows, ok := s.subqueries[query]
+		s.subqueriesLock.Unlock()
+
+		if ok {
+			return rows, nil
+		}
+	}
+
+	// without a from a subquery selects from a single empty row, like select 1
+	rows, err := s.subExecutor(*query, tern(correlated, sc, nil)).run([]input.DataRow{{}})
+	if err != nil {
+		return nil, err
+	}
+
+	if !correlated {
+		s.subqueriesLock.Lock()
+		s.subqueries[query] = rows
+		s.subqueriesLock.Unlock()
+	}
+
+	return rows, nil
+}
+
+// the executor of the outermost query, which holds the piped rows
+func (s *Executor) root() *Executor {
+	if s.parent == nil {
+		return s
+	}
+
+	return s.parent.root()
+}
+
+// the name the piped rows are read by, the first table anywhere in the query that isn't registered, a common table or
+// a file. Every other name has to be one of those
+func (s *Executor) pipedName() string {
+	name := ""
+	ctes := map[string]bool{}
+
+	walkQuery(&s.sql, func(q *Query) {
+		for _, cte := range q.With {
+			ctes[cte.Name] = true
+		}
+	}, nil)
+
+	walkQuery(&s.sql, func(q *Query) {
+		for _, table := range append([]*Table{q.From}, q.joinTables()...) {
+			if table == nil || table.Name == "" || name != "" {
+				continue
+			}
+
+			if _, ok := s.tables[table.Name]; !ok && !ctes[table.Name] {
+				name = table.Name
+			}
+		}
+	}, nil)
+
+	return name
+}
+
+// the values of the single column a subquery selects
+func (s *Executor) subqueryValues(sc *scope, query *Query) ([]interface{}, error) {
+	column, err := subqueryColumn(query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.subqueryRows(sc, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	for _, row := range rows {
+		values = append(values, normalize(row[column]))
+	}
+
+	return values, nil
+}
+
+// the value of a subquery used as an expression, null when it has no rows
+func (s *Executor) subqueryValue(sc *scope, query *Query) (interface{}, error) {
+	values, err := s.subqueryValues(sc, query)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(values) > 1:
+		return nil, errors.New(fmt.Sprintf("subquery (%s) returned more than one row", query))
+	case len(values) == 0:
+		return nil, nil
+	}
+
+	return values[0], nil
+}
+
+func (s *Executor) exists(sc *scope, query *Query) (bool, error) {
+	rows, err := s.subqueryRows(sc, query)
+
+	return len(rows) > 0, err
+}
+
+// the key of the only field a subquery selects
+func subqueryColumn(query *Query) (string, error) {
+	if len(query.Fields) != 1 || query.Fields[0].Name == "*" {
+		return "", errors.New(fmt.Sprintf("subquery (%s) must select a single column", query))
+	}
+
+	return outputKey(query.Fields[0]), nil
+}
+
+// the names of the tables a query reads, every column can be qualified with one of these
+func (q *Query) qualifiers() []string {
+	var qualifiers []string
+
+	if q.From != nil {
+		qualifiers = append(qualifiers, q.From.qualifier())
+	}
+
+	for _, join := range q.Joins {
+		qualifiers = append(qualifiers, join.Table.qualifier())
+	}
+
+	return qualifiers
+}
+
+// true when a column is qualified with a table of an enclosing query rather than one of this query
+func (s *Executor) outerColumn(name string) bool {
+	table, _, ok := strings.Cut(name, ".")
+	if !ok || s.outer == nil || slices.Contains(s.sql.qualifiers(), table) {
+		return false
+	}
+
+	for parent := s.parent; parent != nil; parent = parent.parent {
+		if slices.Contains(parent.sql.qualifiers(), table) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// true when the query, or a query inside it, reads a column of a table of this query or one enclosing it
+func (s *Executor) correlated(query *Query) bool {
+	var inner, outer []string
+
+	walkQuery(query, func(q *Query) {
+		inner = append(inner, q.qualifiers()...)
+	}, nil)
+
+	for executor := s; executor != nil; executor = executor.parent {
+		outer = append(outer, executor.sql.qualifiers()...)
+	}
+
+	correlated := false
+
+	walkQuery(query, nil, func(expr *Expr) {
+		table, _, ok := strings.Cut(expr.Column, ".")
+		if ok && !slices.Contains(inner, table) && slices.Contains(outer, table) {
+			correlated = true
+		}
+	})
+
+	return correlated
+}
+
+// calls visitQuery with the query and every query inside it, and visitExpr with every expression of them
+func walkQuery(query *Query, visitQuery func(*Query), visitExpr func(*Expr)) {
+	if query == nil {
+		return
+	}
+
+	if visitQuery != nil {
+		visitQuery(query)
+	}
+
+	for i := range query.With {
+		walkQuery(&query.With[i].Query, visitQuery, visitExpr)
+		walkQuery(query.With[i].Recursive, visitQuery, visitExpr)
+	}
+
+	var walk func(expr *Expr)
+	walk = func(expr *Expr) {
+		if expr == nil {
+			return
+		}
+
+		if visitExpr != nil {
+			visitExpr(expr)
+		}
+
+		switch {
+		case expr.Cast != nil:
+			walk(&expr.Cast.Expr)
+		case expr.Binary != nil:
+			walk(&expr.Binary.Left)
+			walk(&expr.Binary.Right)
+		case expr.Case != nil:
+			walk(expr.Case.Operand)
+
+			for i := range expr.Case.Whens {
+				walkGroup(expr.Case.Whens[i].Condition, walk)
+				walk(expr.Case.Whens[i].Value)
+				walk(&expr.Case.Whens[i].Then)
+			}
+
+			walk(expr.Case.Else)
+		case expr.Subquery != nil:
+			walkQuery(expr.Subquery, visitQuery, visitExpr)
+		case expr.Exists != nil:
+			walkQuery(expr.Exists, visitQuery, visitExpr)
+		case expr.Call != nil:
+			for i := range expr.Call.Args {
+				walk(&expr.Call.Args[i])
+			}
+
+			for i := range expr.Call.WithinGroup {
+				walk(&expr.Call.WithinGroup[i].Expr)
+			}
+
+			if over := expr.Call.Over; over != nil {
+				for i := range over.PartitionBy {
+					walk(&over.PartitionBy[i])
+				}
+
+				for i := range over.OrderBy {
+					walk(&over.OrderBy[i].Expr)
+				}
+			}
+		}
+	}
+
+	for i := range query.Sets {
+		walkQuery(&query.Sets[i].Query, visitQuery, visitExpr)
+	}
+
+	for _, field := range query.Fields {
+		walk(tern(field.Expr != nil, field.Expr, NewColumn(field.Name)))
+	}
+
+	if query.From != nil {
+		walkQuery(query.From.Query, visitQuery, visitExpr)
+	}
+
+	for _, join := range query.Joins {
+		walkQuery(join.Table.Query, visitQuery, visitExpr)
+		walk(join.Table.Unnest)
+		walkGroup(join.On, walk)
+	}
+
+	walkGroup(query.Group, walk)
+
+	for i := range query.GroupBy {
+		walk(&query.GroupBy[i])
+	}
+
+	for i := range query.OrderBy {
+		walk(&query.OrderBy[i].Expr)
+	}
+}
+
+// calls walk with both sides of every leaf of the group, a side that is a field is walked as a column
+func walkGroup(group *PredicateGroup, walk func(*Expr)) {
+	if group == nil {
+		return
+	}
+
+	for _, predicate := range group.Predicate {
+		if leaf := predicate.Leaf; leaf != nil {
+			walk(tern(leaf.Left != nil, leaf.Left, NewColumn(leaf.Field)))
+			walk(leaf.Right)
+
+			for _, param := range listParams(leaf) {
+				walk(&Expr{Param: &param})
+			}
+		}
+
+		walkGroup(predicate.Group, walk)
+	}
+}
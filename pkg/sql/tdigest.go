@@ -0,0 +1,213 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+func init() {
+	aggregateFunctions["approx_percentile"] = builtinAggregate{params: 1, init: newApproxPercentile}
+	aggregateFunctions["approx_median"] = builtinAggregate{
+		init: func(params []interface{}) (Aggregate, error) {
+			return newApproxPercentile([]interface{}{0.5})
+		},
+	}
+}
+
+const (
+	// higher compression keeps more centroids, 100 keeps a few hundred and is accurate to well under 1% at the tails
+	tdigestCompression = 100
+	// points are buffered and merged into the centroids in batches
+	tdigestBuffer = 500
+)
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// tDigest estimates quantiles by clustering values into centroids that are small near the tails and large in the
+// middle, so extreme percentiles stay accurate
+type tDigest struct {
+	centroids []centroid
+	buffer    []centroid
+	min, max  float64
+}
+
+func newTDigest() *tDigest {
+	return &tDigest{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (t *tDigest) add(value float64) {
+	t.buffer = append(t.buffer, centroid{mean: value, weight: 1})
+	t.min = math.Min(t.min, value)
+	t.max = math.Max(t.max, value)
+
+	if len(t.buffer) >= tdigestBuffer {
+		t.compress()
+	}
+}
+
+func (t *tDigest) merge(other *tDigest) {
+	t.buffer = append(t.buffer, other.centroids...)
+	t.buffer = append(t.buffer, other.buffer...)
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+
+	t.compress()
+}
+
+// merges neighbouring centroids while they stay under the size limit for where they sit in the distribution
+func (t *tDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	all := append(t.centroids, t.buffer...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+
+	total := 0.0
+	for _, c := range all {
+		total += c.weight
+	}
+
+	merged := []centroid{all[0]}
+	before := 0.0
+
+	for _, next := range all[1:] {
+		current := &merged[len(merged)-1]
+
+		proposed := current.weight + next.weight
+		q0 := before / total
+		q2 := (before + proposed) / total
+
+		if proposed <= total*math.Min(tdigestLimit(q0), tdigestLimit(q2)) {
+			current.mean += (next.mean - current.mean) * next.weight / proposed
+			current.weight = proposed
+			continue
+		}
+
+		before += current.weight
+		merged = append(merged, next)
+	}
+
+	t.centroids = merged
+	t.buffer = nil
+}
+
+// the largest share of the total weight a centroid at quantile q can hold
+func tdigestLimit(q float64) float64 {
+	return 4 * q * (1 - q) / tdigestCompression
+}
+
+// interpolates between the centres of the centroids either side of the quantile
+func (t *tDigest) quantile(q float64) (float64, bool) {
+	t.compress()
+
+	if len(t.centroids) == 0 {
+		return 0, false
+	}
+
+	if len(t.centroids) == 1 {
+		return t.centroids[0].mean, true
+	}
+
+	total := 0.0
+	for _, c := range t.centroids {
+		total += c.weight
+	}
+
+	target := q * total
+
+	first := t.centroids[0]
+	if target < first.weight/2 {
+		return t.min + (first.mean-t.min)*target/(first.weight/2), true
+	}
+
+	cumulative := 0.0
+	for i := 0; i < len(t.centroids)-1; i++ {
+		left, right := t.centroids[i], t.centroids[i+1]
+
+		leftCentre := cumulative + left.weight/2
+		rightCentre := cumulative + left.weight + right.weight/2
+
+		if target <= rightCentre {
+			return left.mean + (right.mean-left.mean)*(target-leftCentre)/(rightCentre-leftCentre), true
+		}
+
+		cumulative += left.weight
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	lastCentre := total - last.weight/2
+
+	return last.mean + (t.max-last.mean)*(target-lastCentre)/(last.weight/2), true
+}
+
+// approx_percentile(x, p) estimates the pth percentile of the numbers that aren't null
+type approxPercentileAggregator struct {
+	percentile float64
+	sketch     *tDigest
+}
+
+func newApproxPercentile(params []interface{}) (Aggregate, error) {
+	percentile, err := percentileParam(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &approxPercentileAggregator{percentile: percentile, sketch: newTDigest()}, nil
+}
+
+// percentiles are fractions between 0 and 1
+func percentileParam(param interface{}) (float64, error) {
+	if !isNumeric(normalize(param)) {
+		return 0, errors.New(fmt.Sprintf("percentile %v must be a number between 0 and 1", param))
+	}
+
+	percentile, _ := CastValue(param, Double)
+	if percentile.(float64) < 0 || percentile.(float64) > 1 {
+		return 0, errors.New(fmt.Sprintf("percentile %v must be a number between 0 and 1", param))
+	}
+
+	return percentile.(float64), nil
+}
+
+func (a *approxPercentileAggregator) Accumulate(args []interface{}) error {
+	if args[0] == nil {
+		return nil
+	}
+
+	if !isNumeric(args[0]) {
+		return errors.New(fmt.Sprintf("cannot take a percentile of non numeric value %v", args[0]))
+	}
+
+	value, _ := CastValue(args[0], Double)
+	a.sketch.add(value.(float64))
+
+	return nil
+}
+
+func (a *approxPercentileAggregator) Merge(other Aggregate) error {
+	partial, ok := other.(*approxPercentileAggregator)
+	if !ok {
+		return mergeError(a, other)
+	}
+
+	a.sketch.merge(partial.sketch)
+
+	return nil
+}
+
+func (a *approxPercentileAggregator) Finalize() (interface{}, error) {
+	value, ok := a.sketch.quantile(a.percentile)
+	if !ok {
+		return nil, nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,407 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalValue is a span of time, months are kept apart from the duration since they vary in length
+type IntervalValue struct {
+	Months   int
+	Duration time.Duration
+}
+
+var intervalUnits = map[string]struct {
+	months   int
+	duration time.Duration
+}{
+	"microsecond": {duration: time.Microsecond},
+	"millisecond": {duration: time.Millisecond},
+	"ms":          {duration: time.Millisecond},
+	"second":      {duration: time.Second},
+	"sec":         {duration: time.Second},
+	"s":           {duration: time.Second},
+	"minute":      {duration: time.Minute},
+	"min":         {duration: time.Minute},
+	"m":           {duration: time.Minute},
+	"hour":        {duration: time.Hour},
+	"h":           {duration: time.Hour},
+	"day":         {duration: 24 * time.Hour},
+	"d":           {duration: 24 * time.Hour},
+	"week":        {duration: 7 * 24 * time.Hour},
+	"w":           {duration: 7 * 24 * time.Hour},
+	"month":       {months: 1},
+	"mon":         {months: 1},
+	"quarter":     {months: 3},
+	"year":        {months: 12},
+	"y":           {months: 12},
+}
+
+// parses intervals like '1 hour', '1 day 12 hours' or go durations like '1h30m'
+func parseInterval(raw string) (IntervalValue, error) {
+	invalid := errors.New(fmt.Sprintf("%s is not a valid interval", raw))
+
+	parts := strings.Fields(strings.ToLower(raw))
+	if len(parts) == 1 {
+		if duration, err := time.ParseDuration(parts[0]); err == nil {
+			return IntervalValue{Duration: duration}, nil
+		}
+	}
+
+	if len(parts) == 0 || len(parts)%2 != 0 {
+		return IntervalValue{}, invalid
+	}
+
+	var interval IntervalValue
+	for i := 0; i < len(parts); i += 2 {
+		count, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return IntervalValue{}, invalid
+		}
+
+		// a plural is only a long unit with an s, so 5 ms stays milliseconds rather than reading as 5 m
+		unit, ok := intervalUnits[parts[i+1]]
+		if singular := strings.TrimSuffix(parts[i+1], "s"); !ok && len(singular) > 1 {
+			unit, ok = intervalUnits[singular]
+		}
+
+		if !ok {
+			return IntervalValue{}, invalid
+		}
+
+		if unit.months != 0 {
+			if count != float64(int(count)) {
+				return IntervalValue{}, invalid
+			}
+
+			interval.Months += int(count) * unit.months
+		}
+
+		interval.Duration += time.Duration(count * float64(unit.duration))
+	}
+
+	return interval, nil
+}
+
+func (i IntervalValue) String() string {
+	var parts []string
+
+	plural := func(count int64, unit string) {
+		if count != 0 {
+			parts = append(parts, fmt.Sprintf("%d %s%s", count, unit, tern(count == 1 || count == -1, "", "s")))
+		}
+	}
+
+	plural(int64(i.Months/12), "year")
+	plural(int64(i.Months%12), "month")
+
+	remaining := i.Duration
+	for _, unit := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+		{"second", time.Second},
+		{"millisecond", time.Millisecond},
+		{"microsecond", time.Microsecond},
+	} {
+		plural(int64(remaining/unit.duration), unit.name)
+		remaining = remaining % unit.duration
+	}
+
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (i IntervalValue) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(i.String())), nil
+}
+
+// an approximate length used to order intervals, a month counts as 30 days
+func (i IntervalValue) approximate() time.Duration {
+	return time.Duration(i.Months)*30*24*time.Hour + i.Duration
+}
+
+func (i IntervalValue) addTo(t time.Time) time.Time {
+	return t.AddDate(0, i.Months, 0).Add(i.Duration)
+}
+
+func (i IntervalValue) negate() IntervalValue {
+	return IntervalValue{Months: -i.Months, Duration: -i.Duration}
+}
+
+var (
+	// a monday so that weekly buckets start on mondays
+	bucketOrigin  = time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+	dateBinOrigin = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// finds the start of the bucket the timestamp falls in, buckets are width wide and line up with the origin
+func timeBucket(width IntervalValue, ts time.Time, origin time.Time) (time.Time, error) {
+	ts = ts.UTC()
+	origin = origin.UTC()
+
+	switch {
+	case width.Months > 0 && width.Duration == 0:
+		months := (ts.Year()-origin.Year())*12 + int(ts.Month()-origin.Month())
+		months -= ((months % width.Months) + width.Months) % width.Months
+
+		start := origin.AddDate(0, months, 0)
+		if start.After(ts) {
+			start = origin.AddDate(0, months-width.Months, 0)
+		}
+
+		return start, nil
+	case width.Months == 0 && width.Duration > 0:
+		offset := ts.Sub(origin)
+
+		buckets := offset / width.Duration
+		if offset%width.Duration < 0 {
+			buckets--
+		}
+
+		return origin.Add(buckets * width.Duration), nil
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("%s is not a valid bucket width", width))
+}
+
+// truncates a timestamp down to the start of the unit it is in
+func dateTrunc(unit string, t time.Time) (time.Time, error) {
+	switch strings.ToLower(unit) {
+	case "microsecond", "microseconds":
+		return t.Truncate(time.Microsecond), nil
+	case "millisecond", "milliseconds":
+		return t.Truncate(time.Millisecond), nil
+	case "second", "seconds":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location()), nil
+	case "minute", "minutes":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()), nil
+	case "hour", "hours":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()), nil
+	case "day", "days":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+	case "week", "weeks":
+		// weeks start on monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location()), nil
+	case "month", "months":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	case "quarter", "quarters":
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location()), nil
+	case "year", "years":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("%s is not a valid date part", unit))
+}
+
+// extracts a single part of a timestamp, epoch is fractional seconds and everything else is whole
+func extract(part string, t time.Time) (interface{}, error) {
+	switch strings.ToLower(part) {
+	case "year":
+		return int64(t.Year()), nil
+	case "quarter":
+		return int64((t.Month()-1)/3 + 1), nil
+	case "month":
+		return int64(t.Month()), nil
+	case "week":
+		_, week := t.ISOWeek()
+		return int64(week), nil
+	case "day":
+		return int64(t.Day()), nil
+	case "dow", "dayofweek":
+		return int64(t.Weekday()), nil
+	case "doy", "dayofyear":
+		return int64(t.YearDay()), nil
+	case "hour":
+		return int64(t.Hour()), nil
+	case "minute":
+		return int64(t.Minute()), nil
+	case "second":
+		return int64(t.Second()), nil
+	case "millisecond":
+		return int64(t.Nanosecond() / int(time.Millisecond)), nil
+	case "microsecond":
+		return int64(t.Nanosecond() / int(time.Microsecond)), nil
+	case "epoch":
+		return float64(t.UnixNano()) / float64(time.Second), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("%s is not a valid date part", part))
+}
+
+// go layouts for each strftime directive
+var strftimeDirectives = map[rune]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+	'b': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'j': "002",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+// formats a timestamp with strftime directives, %F and %T are shorthand for %Y-%m-%d and %H:%M:%S
+func strftime(t time.Time, format string) (string, error) {
+	var builder strings.Builder
+
+	directives := []rune(expandStrftime(format))
+	for i := 0; i < len(directives); i++ {
+		if directives[i] != '%' {
+			builder.WriteRune(directives[i])
+			continue
+		}
+
+		if i+1 >= len(directives) {
+			return "", errors.New(fmt.Sprintf("%s ends with an incomplete directive", format))
+		}
+
+		i++
+
+		switch directives[i] {
+		case '%':
+			builder.WriteRune('%')
+		case 's':
+			builder.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'f':
+			// go only formats fractions of a second after a dot or comma in the layout
+			builder.WriteString(fmt.Sprintf("%06d", t.Nanosecond()/1000))
+		default:
+			layout, ok := strftimeDirectives[directives[i]]
+			if !ok {
+				return "", errors.New(fmt.Sprintf("%%%c is not a supported directive", directives[i]))
+			}
+
+			builder.WriteString(t.Format(layout))
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// the text each strftime directive reads when parsing
+var strptimePatterns = map[rune]string{
+	'Y': `\d{4}`,
+	'y': `\d{2}`,
+	'm': `\d{2}`,
+	'd': `\d{2}`,
+	'e': `[ \d]?\d`,
+	'H': `\d{2}`,
+	'I': `\d{2}`,
+	'M': `\d{2}`,
+	'S': `\d{2}`,
+	'f': `\d{1,9}`,
+	'p': `AM|PM`,
+	'b': `[A-Za-z]{3}`,
+	'B': `[A-Za-z]+`,
+	'a': `[A-Za-z]{3}`,
+	'A': `[A-Za-z]+`,
+	'j': `\d{3}`,
+	'z': `[+-]\d{4}`,
+	'Z': `[A-Za-z]+|[+-]\d{2,4}`,
+}
+
+// parses a string with strftime directives into a timestamp. The text between directives is matched as it is and
+// only the directives are parsed as a go layout, so a literal like Mon or 1 isn't read as part of the layout
+func strptime(value string, format string) (time.Time, error) {
+	var pattern strings.Builder
+	var layouts []string
+
+	pattern.WriteString("^")
+
+	directives := []rune(expandStrftime(format))
+	for i := 0; i < len(directives); i++ {
+		if directives[i] != '%' {
+			pattern.WriteString(regexp.QuoteMeta(string(directives[i])))
+			continue
+		}
+
+		if i+1 >= len(directives) {
+			return time.Time{}, errors.New(fmt.Sprintf("%s ends with an incomplete directive", format))
+		}
+
+		i++
+
+		if directives[i] == '%' {
+			pattern.WriteString("%")
+			continue
+		}
+
+		directive, ok := strptimePatterns[directives[i]]
+		if !ok {
+			return time.Time{}, errors.New(fmt.Sprintf("%%%c is not a supported directive", directives[i]))
+		}
+
+		pattern.WriteString("(" + directive + ")")
+
+		// go reads fractions of a second after a dot
+		layouts = append(layouts, tern(directives[i] == 'f', ".999999999", strftimeDirectives[directives[i]]))
+	}
+
+	pattern.WriteString("$")
+
+	matches := regexp.MustCompile(pattern.String()).FindStringSubmatch(value)
+	if matches == nil {
+		return time.Time{}, errors.New(fmt.Sprintf("%s doesn't match the format %s", value, format))
+	}
+
+	pieces := matches[1:]
+	for i, layout := range layouts {
+		if layout == ".999999999" {
+			pieces[i] = "." + pieces[i]
+		}
+	}
+
+	// the pieces are parsed on their own, kept apart by a character that isn't part of any layout
+	return time.Parse(strings.Join(layouts, "|"), strings.Join(pieces, "|"))
+}
+
+// the directives that are short for others
+var strftimeShorthands = map[rune]string{
+	'F': "%Y-%m-%d",
+	'T': "%H:%M:%S",
+}
+
+// expands %F and %T in one pass from the left, so the F of an escaped %%F is left as it is
+func expandStrftime(format string) string {
+	var builder strings.Builder
+
+	directives := []rune(format)
+	for i := 0; i < len(directives); i++ {
+		if directives[i] != '%' || i+1 >= len(directives) {
+			builder.WriteRune(directives[i])
+			continue
+		}
+
+		i++
+
+		if expanded, ok := strftimeShorthands[directives[i]]; ok {
+			builder.WriteString(expanded)
+		} else {
+			builder.WriteRune('%')
+			builder.WriteRune(directives[i])
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,186 @@
+package sql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsesIntervals(t *testing.T) {
+	cases := map[string]IntervalValue{
+		"1 hour":             {Duration: time.Hour},
+		"5 minutes":          {Duration: 5 * time.Minute},
+		"1 day 12 hours":     {Duration: 36 * time.Hour},
+		"2 months":           {Months: 2},
+		"1 year 1 month":     {Months: 13},
+		"1h30m":              {Duration: 90 * time.Minute},
+		"500 milliseconds":   {Duration: 500 * time.Millisecond},
+		"1.5 hours":          {Duration: 90 * time.Minute},
+		"1 week 1 second":    {Duration: 7*24*time.Hour + time.Second},
+		"30 seconds 2 hours": {Duration: 2*time.Hour + 30*time.Second},
+		"5 ms":               {Duration: 5 * time.Millisecond},
+		"2 secs 3 mins":      {Duration: 3*time.Minute + 2*time.Second},
+	}
+
+	for raw, expect := range cases {
+		interval, err := parseInterval(raw)
+		if err != nil {
+			t.Errorf("%s: %s", raw, err)
+			continue
+		}
+
+		if interval != expect {
+			t.Errorf("%s: expected %s got %s", raw, expect, interval)
+		}
+	}
+
+	for _, raw := range []string{"", "hour", "1 fortnight", "1.5 months", "5 hs"} {
+		if _, err := parseInterval(raw); err == nil {
+			t.Errorf("expected %s to fail", raw)
+		}
+	}
+}
+
+func TestTruncatesDates(t *testing.T) {
+	ts := time.Date(2024, 5, 15, 10, 17, 33, 500, time.UTC)
+
+	cases := map[string]time.Time{
+		"second":  time.Date(2024, 5, 15, 10, 17, 33, 0, time.UTC),
+		"minute":  time.Date(2024, 5, 15, 10, 17, 0, 0, time.UTC),
+		"hour":    time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC),
+		"day":     time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+		"week":    time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC),
+		"month":   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		"quarter": time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		"year":    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for unit, expect := range cases {
+		truncated, err := dateTrunc(unit, ts)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !truncated.Equal(expect) {
+			t.Errorf("%s: expected %s got %s", unit, expect, truncated)
+		}
+	}
+}
+
+func TestFormatsAndParsesTimes(t *testing.T) {
+	ts := time.Date(2024, 5, 15, 10, 17, 33, 0, time.UTC)
+
+	formatted, err := strftime(ts, "%Y/%m/%d %H:%M:%S %% %s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if formatted != "2024/05/15 10:17:33 % 1715768253" {
+		t.Error(formatted)
+	}
+
+	parsed, err := strptime("15/05/2024 10:17:33", "%d/%m/%Y %T")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.Equal(ts) {
+		t.Error(parsed)
+	}
+
+	// literals that are also go layout tokens are matched as they are
+	for _, test := range []struct {
+		value, format string
+		expected      time.Time
+	}{
+		{"Mon 1 Jan: 05/03/2024 10:17 PM", "Mon 1 Jan: %d/%m/%Y %I:%M %p", time.Date(2024, 3, 5, 22, 17, 0, 0, time.UTC)},
+		{"2024-05-15 10:17:33.0425", "%F %T.%f", time.Date(2024, 5, 15, 10, 17, 33, 42500*1000, time.UTC)},
+		{"day 136 of 2024 at 07", "day %j of %Y at %H", time.Date(2024, 5, 15, 7, 0, 0, 0, time.UTC)},
+	} {
+		parsed, err := strptime(test.value, test.format)
+		if err != nil {
+			t.Fatalf("%s: %s", test.format, err)
+		}
+
+		if !parsed.Equal(test.expected) {
+			t.Errorf("%s: expected %s got %s", test.format, test.expected, parsed)
+		}
+	}
+
+	for _, value := range []string{"Tue 1 Jan: 05/03/2024 10:17 PM", "Mon 1 Jan: 5/03/2024 10:17 PM"} {
+		if _, err := strptime(value, "Mon 1 Jan: %d/%m/%Y %I:%M %p"); err == nil {
+			t.Errorf("expected %s to fail", value)
+		}
+	}
+
+	micros, err := strftime(time.Date(2024, 5, 15, 10, 17, 33, 42500*1000, time.UTC), "%S.%f|%f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if micros != "33.042500|042500" {
+		t.Error(micros)
+	}
+
+	// an escaped percent sign is never the start of a directive
+	escaped, err := strftime(ts, "%%F %%%F %%T%T")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if escaped != "%F %2024-05-15 %T10:17:33" {
+		t.Error(escaped)
+	}
+
+	if parsed, err := strptime("%F 2024-05-15", "%%F %F"); err != nil || !parsed.Equal(time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected %%F to be matched as it is but got %s %v", parsed, err)
+	}
+
+	if _, err := strftime(ts, "%Q"); err == nil {
+		t.Fail()
+	}
+}
+
+func TestBucketsTimes(t *testing.T) {
+	ts := time.Date(2024, 5, 15, 10, 17, 33, 0, time.UTC)
+
+	cases := []struct {
+		width  string
+		expect time.Time
+	}{
+		{"5 minutes", time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC)},
+		{"1 hour", time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)},
+		{"1 day", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"1 week", time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{"1 month", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"3 months", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		width, err := parseInterval(c.width)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		origin := tern(width.Months > 0, dateBinOrigin, bucketOrigin)
+
+		bucket, err := timeBucket(width, ts, origin)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !bucket.Equal(c.expect) {
+			t.Errorf("%s: expected %s got %s", c.width, c.expect, bucket)
+		}
+	}
+
+	before, err := timeBucket(IntervalValue{Duration: time.Hour}, time.Date(1999, 1, 1, 10, 30, 0, 0, time.UTC), bucketOrigin)
+	if err != nil || !before.Equal(time.Date(1999, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Error(before, err)
+	}
+
+	if _, err := timeBucket(IntervalValue{}, ts, bucketOrigin); err == nil {
+		t.Fail()
+	}
+}
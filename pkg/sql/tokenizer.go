@@ -1,26 +1,38 @@
 package sql
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 type streamTokenizer struct {
-	tokens []string
+	tokens []Token
 	index  int
+	// the number of ? placeholders read so far
+	positional int
 }
 
 var (
 	eof = errors.New("Cannot consume past end of stream")
 )
 
-func NewStreamTokenizer(tokens Tokens) *streamTokenizer {
+func NewStreamTokenizer(tokens []Token) *streamTokenizer {
 	return &streamTokenizer{
 		tokens: tokens,
 		index:  0,
 	}
 }
 
+// the value of the next token
 func (c *streamTokenizer) Consume() (string, error) {
+	token, err := c.ConsumeToken()
+
+	return token.Value, err
+}
+
+func (c *streamTokenizer) ConsumeToken() (Token, error) {
 	if c.index > len(c.tokens)-1 {
-		return "", eof
+		return Token{}, eof
 	}
 
 	result := c.tokens[c.index]
@@ -30,12 +42,35 @@ func (c *streamTokenizer) Consume() (string, error) {
 	return result, nil
 }
 
+// the next token as a keyword, lowercase when it is bare so keywords can be written in any case
 func (c *streamTokenizer) Peek() (string, error) {
+	token, err := c.PeekToken()
+
+	return token.Keyword(), err
+}
+
+// consumes the next token as a keyword
+func (c *streamTokenizer) ConsumeKeyword() (string, error) {
+	token, err := c.ConsumeToken()
+
+	return token.Keyword(), err
+}
+
+func (c *streamTokenizer) PeekToken() (Token, error) {
 	if c.index > len(c.tokens)-1 {
-		return "", eof
+		return Token{}, eof
 	}
 
-	result := c.tokens[c.index]
+	return c.tokens[c.index], nil
+}
 
-	return result, nil
+// the placeholder a ? or $name token is, each ? is numbered in the order it is read
+func (c *streamTokenizer) param(token Token) *Expr {
+	if name, ok := strings.CutPrefix(token.Value, "$"); ok {
+		return &Expr{Param: &Param{Name: name}}
+	}
+
+	c.positional++
+
+	return &Expr{Param: &Param{Position: c.positional}}
 }
@@ -0,0 +1,648 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Type string
+
+const (
+	Boolean   Type = "boolean"
+	BigInt         = "bigint"
+	Double         = "double"
+	Decimal        = "decimal"
+	Varchar        = "varchar"
+	Timestamp      = "timestamp"
+	Interval       = "interval"
+	Json           = "json"
+)
+
+var typeAliases = map[string]Type{
+	"boolean":   Boolean,
+	"bool":      Boolean,
+	"bigint":    BigInt,
+	"int":       BigInt,
+	"integer":   BigInt,
+	"double":    Double,
+	"float":     Double,
+	"decimal":   Decimal,
+	"numeric":   Decimal,
+	"varchar":   Varchar,
+	"string":    Varchar,
+	"text":      Varchar,
+	"timestamp": Timestamp,
+	"interval":  Interval,
+	"json":      Json,
+}
+
+func ParseType(name string) (Type, error) {
+	if t, ok := typeAliases[strings.ToLower(name)]; ok {
+		return t, nil
+	}
+
+	return "", errors.New(fmt.Sprintf("%s is not a valid type", name))
+}
+
+// DecimalValue is an exact numeric value, Scale is the number of digits kept after the decimal point
+type DecimalValue struct {
+	Rat   *big.Rat
+	Scale int
+}
+
+func (d DecimalValue) String() string {
+	return normalize(d).(DecimalValue).Rat.FloatString(d.Scale)
+}
+
+func (d DecimalValue) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func parseDecimal(raw string) (DecimalValue, error) {
+	raw = strings.TrimSpace(raw)
+
+	rat, ok := new(big.Rat).SetString(raw)
+	if !ok {
+		return DecimalValue{}, errors.New(fmt.Sprintf("%s is not a valid decimal", raw))
+	}
+
+	scale := 0
+	if dot := strings.IndexByte(raw, '.'); dot >= 0 && !strings.ContainsAny(raw, "eE") {
+		scale = len(raw) - dot - 1
+	}
+
+	return DecimalValue{Rat: rat, Scale: scale}, nil
+}
+
+// rounds the decimal half away from zero to the requested scale
+func (d DecimalValue) withScale(scale int) DecimalValue {
+	rounded, _ := new(big.Rat).SetString(d.Rat.FloatString(scale))
+
+	return DecimalValue{Rat: rounded, Scale: scale}
+}
+
+// normalizes a value so that every integer width is an int64 and every float width is a float64, json numbers
+// become an int64 when they are whole, a decimal when they are whole but too large for an int64 and a float64 otherwise
+func normalize(value interface{}) interface{} {
+	switch casted := value.(type) {
+	case json.Number:
+		return normalizeNumber(casted)
+	case int:
+		return int64(casted)
+	case int8:
+		return int64(casted)
+	case int16:
+		return int64(casted)
+	case int32:
+		return int64(casted)
+	case uint:
+		return normalize(uint64(casted))
+	case uint8:
+		return int64(casted)
+	case uint16:
+		return int64(casted)
+	case uint32:
+		return int64(casted)
+	case uint64:
+		if casted > math.MaxInt64 {
+			return float64(casted)
+		}
+		return int64(casted)
+	case float32:
+		return float64(casted)
+	case DecimalValue:
+		// the zero DecimalValue has no Rat and is 0
+		if casted.Rat == nil {
+			return DecimalValue{Rat: new(big.Rat), Scale: casted.Scale}
+		}
+	}
+
+	return value
+}
+
+func normalizeNumber(number json.Number) interface{} {
+	if integer, err := number.Int64(); err == nil {
+		return integer
+	}
+
+	if !strings.ContainsAny(string(number), ".eE") {
+		if decimal, err := parseDecimal(string(number)); err == nil {
+			return decimal
+		}
+	}
+
+	if float, err := number.Float64(); err == nil {
+		return float
+	}
+
+	if decimal, err := parseDecimal(string(number)); err == nil {
+		return decimal
+	}
+
+	return string(number)
+}
+
+func isNumeric(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64, DecimalValue:
+		return true
+	}
+
+	return false
+}
+
+// the sql type of a normalized value, json is any object or array
+func typeOf(value interface{}) (Type, bool) {
+	switch value.(type) {
+	case bool:
+		return Boolean, true
+	case int64:
+		return BigInt, true
+	case float64:
+		return Double, true
+	case DecimalValue:
+		return Decimal, true
+	case string:
+		return Varchar, true
+	case time.Time:
+		return Timestamp, true
+	case IntervalValue:
+		return Interval, true
+	case map[string]interface{}, []interface{}:
+		return Json, true
+	}
+
+	return "", false
+}
+
+func toRat(value interface{}) *big.Rat {
+	switch casted := value.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(casted)
+	case float64:
+		rat, _ := new(big.Rat).SetString(strconv.FormatFloat(casted, 'g', -1, 64))
+		return rat
+	case DecimalValue:
+		return casted.Rat
+	}
+
+	return nil
+}
+
+// compares two numbers of any numeric type without losing precision
+func compareNumbers(left interface{}, right interface{}) int {
+	switch l := left.(type) {
+	case int64:
+		if r, ok := right.(int64); ok {
+			return cmpInt(l, r)
+		}
+	case float64:
+		if r, ok := right.(float64); ok {
+			return cmpFloat(l, r)
+		}
+	}
+
+	if l, ok := left.(float64); ok && (math.IsInf(l, 0) || math.IsNaN(l)) {
+		return cmpFloat(l, 0)
+	}
+
+	if r, ok := right.(float64); ok && (math.IsInf(r, 0) || math.IsNaN(r)) {
+		return -cmpFloat(r, 0)
+	}
+
+	return toRat(left).Cmp(toRat(right))
+}
+
+func cmpInt(left int64, right int64) int {
+	return tern(left < right, -1, tern(left > right, 1, 0))
+}
+
+func cmpFloat(left float64, right float64) int {
+	return tern(left < right, -1, tern(left > right, 1, 0))
+}
+
+// compares two normalized values, ok is false when the values are of different types and can't be compared
+func compareValues(left interface{}, right interface{}) (result int, ok bool) {
+	if isNumeric(left) && isNumeric(right) {
+		return compareNumbers(left, right), true
+	}
+
+	switch casted := left.(type) {
+	case string:
+		if other, ok := right.(string); ok {
+			return strings.Compare(casted, other), true
+		}
+	case bool:
+		if other, ok := right.(bool); ok {
+			return cmpInt(tern[int64](casted, 1, 0), tern[int64](other, 1, 0)), true
+		}
+	case time.Time:
+		if other, ok := right.(time.Time); ok {
+			return casted.Compare(other), true
+		}
+	case IntervalValue:
+		if other, ok := right.(IntervalValue); ok {
+			return cmpInt(int64(casted.approximate()), int64(other.approximate())), true
+		}
+	case []interface{}:
+		if other, ok := right.([]interface{}); ok {
+			return compareArrays(casted, other)
+		}
+	}
+
+	return 0, false
+}
+
+// compares arrays element by element, an array that runs out first is the smaller one
+func compareArrays(left []interface{}, right []interface{}) (int, bool) {
+	for i := 0; i < len(left) && i < len(right); i++ {
+		leftValue, rightValue := normalize(left[i]), normalize(right[i])
+		if leftValue == nil || rightValue == nil {
+			if leftValue != rightValue {
+				return tern(leftValue == nil, -1, 1), true
+			}
+
+			continue
+		}
+
+		if result, ok := compareValues(leftValue, rightValue); !ok || result != 0 {
+			return result, ok
+		}
+	}
+
+	return cmpInt(int64(len(left)), int64(len(right))), true
+}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func parseTimestamp(raw string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if parsed, err := time.Parse(layout, strings.TrimSpace(raw)); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("%s is not a valid timestamp", raw))
+}
+
+// CastValue converts a value into the go representation of the sql type, null always casts to null
+func CastValue(value interface{}, to Type) (interface{}, error) {
+	value = normalize(value)
+
+	if value == nil {
+		return nil, nil
+	}
+
+	invalid := errors.New(fmt.Sprintf("cannot cast %v (%s) to %s", value, reflect.TypeOf(value), to))
+
+	switch to {
+	case Boolean:
+		switch casted := value.(type) {
+		case bool:
+			return casted, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(casted)) {
+			case "true", "t", "1":
+				return true, nil
+			case "false", "f", "0":
+				return false, nil
+			}
+		case int64, float64, DecimalValue:
+			return compareNumbers(casted, int64(0)) != 0, nil
+		}
+	case BigInt:
+		switch casted := value.(type) {
+		case int64:
+			return casted, nil
+		case bool:
+			return tern[int64](casted, 1, 0), nil
+		case string:
+			if parsed, err := strconv.ParseInt(strings.TrimSpace(casted), 10, 64); err == nil {
+				return parsed, nil
+			}
+		case float64:
+			rounded := math.Round(casted)
+			if rounded >= math.MinInt64 && rounded < math.MaxInt64 {
+				return int64(rounded), nil
+			}
+		case DecimalValue:
+			rounded := casted.withScale(0).Rat
+			if rounded.Num().IsInt64() {
+				return rounded.Num().Int64(), nil
+			}
+		}
+	case Double:
+		switch casted := value.(type) {
+		case float64:
+			return casted, nil
+		case int64:
+			return float64(casted), nil
+		case bool:
+			return tern[float64](casted, 1, 0), nil
+		case string:
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(casted), 64); err == nil {
+				return parsed, nil
+			}
+		case DecimalValue:
+			float, _ := casted.Rat.Float64()
+			return float, nil
+		}
+	case Decimal:
+		switch casted := value.(type) {
+		case DecimalValue:
+			return casted, nil
+		case int64:
+			return DecimalValue{Rat: new(big.Rat).SetInt64(casted)}, nil
+		case float64:
+			if !math.IsInf(casted, 0) && !math.IsNaN(casted) {
+				return parseDecimal(strconv.FormatFloat(casted, 'f', -1, 64))
+			}
+		case string:
+			if parsed, err := parseDecimal(casted); err == nil {
+				return parsed, nil
+			}
+		}
+	case Varchar:
+		switch casted := value.(type) {
+		case string:
+			return casted, nil
+		case DecimalValue:
+			return casted.String(), nil
+		case time.Time:
+			return casted.Format(time.RFC3339Nano), nil
+		case IntervalValue:
+			return casted.String(), nil
+		default:
+			encoded, err := json.Marshal(casted)
+			if err != nil {
+				return nil, err
+			}
+
+			return string(encoded), nil
+		}
+	case Timestamp:
+		switch casted := value.(type) {
+		case time.Time:
+			return casted, nil
+		case string:
+			if parsed, err := parseTimestamp(casted); err == nil {
+				return parsed, nil
+			}
+		case int64:
+			return time.Unix(casted, 0).UTC(), nil
+		case float64:
+			seconds, fraction := math.Modf(casted)
+			return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+		}
+	case Interval:
+		switch casted := value.(type) {
+		case IntervalValue:
+			return casted, nil
+		case string:
+			if parsed, err := parseInterval(casted); err == nil {
+				return parsed, nil
+			}
+		}
+	case Json:
+		switch casted := value.(type) {
+		case string:
+			var parsed interface{}
+			if err := json.Unmarshal([]byte(casted), &parsed); err == nil {
+				return parsed, nil
+			}
+		default:
+			return casted, nil
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("%s is not a valid type", to))
+	}
+
+	return nil, invalid
+}
+
+// converts a number or numeric string into the narrowest number it represents, int64 for whole numbers and float64 otherwise
+func toNumber(value interface{}) (interface{}, error) {
+	value = normalize(value)
+
+	if isNumeric(value) {
+		return value, nil
+	}
+
+	if _, ok := value.(string); !ok {
+		return nil, errors.New(fmt.Sprintf("%v is not a number", value))
+	}
+
+	if integer, err := CastValue(value, BigInt); err == nil {
+		return integer, nil
+	}
+
+	return CastValue(value, Double)
+}
+
+// applies an arithmetic operator, nulls propagate and timestamps and intervals can be added and subtracted
+func arithmetic(operator string, left interface{}, right interface{}) (interface{}, error) {
+	left = normalize(left)
+	right = normalize(right)
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	invalid := errors.New(fmt.Sprintf("cannot apply %s to %v (%s) and %v (%s)", operator, left, reflect.TypeOf(left), right, reflect.TypeOf(right)))
+
+	if operator == "||" {
+		l, err := CastValue(left, Varchar)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := CastValue(right, Varchar)
+		if err != nil {
+			return nil, err
+		}
+
+		return l.(string) + r.(string), nil
+	}
+
+	switch l := left.(type) {
+	case time.Time:
+		switch r := right.(type) {
+		case IntervalValue:
+			switch operator {
+			case "+":
+				return r.addTo(l), nil
+			case "-":
+				return r.negate().addTo(l), nil
+			}
+		case time.Time:
+			if operator == "-" {
+				return IntervalValue{Duration: l.Sub(r)}, nil
+			}
+		}
+
+		return nil, invalid
+	case IntervalValue:
+		switch r := right.(type) {
+		case time.Time:
+			if operator == "+" {
+				return l.addTo(r), nil
+			}
+		case IntervalValue:
+			switch operator {
+			case "+":
+				return IntervalValue{Months: l.Months + r.Months, Duration: l.Duration + r.Duration}, nil
+			case "-":
+				return IntervalValue{Months: l.Months - r.Months, Duration: l.Duration - r.Duration}, nil
+			}
+		case int64, float64, DecimalValue:
+			factor, _ := CastValue(r, Double)
+			switch operator {
+			case "*":
+				return IntervalValue{Months: int(float64(l.Months) * factor.(float64)), Duration: time.Duration(float64(l.Duration) * factor.(float64))}, nil
+			case "/":
+				if factor.(float64) == 0 {
+					return nil, errors.New("division by zero")
+				}
+
+				return IntervalValue{Months: int(float64(l.Months) / factor.(float64)), Duration: time.Duration(float64(l.Duration) / factor.(float64))}, nil
+			}
+		}
+
+		return nil, invalid
+	}
+
+	if _, ok := right.(IntervalValue); ok && isNumeric(left) && operator == "*" {
+		return arithmetic(operator, right, left)
+	}
+
+	if !isNumeric(left) || !isNumeric(right) {
+		return nil, invalid
+	}
+
+	return numericArithmetic(operator, left, right)
+}
+
+// integers stay integers unless they overflow or are divided, decimals stay exact and anything touching a float is a float
+func numericArithmetic(operator string, left interface{}, right interface{}) (interface{}, error) {
+	l, leftInt := left.(int64)
+	r, rightInt := right.(int64)
+
+	if leftInt && rightInt {
+		switch operator {
+		case "+":
+			if sum := l + r; (sum > l) == (r > 0) {
+				return sum, nil
+			}
+		case "-":
+			if difference := l - r; (difference < l) == (r > 0) {
+				return difference, nil
+			}
+		case "*":
+			if l == 0 || r == 0 {
+				return int64(0), nil
+			}
+
+			if product := l * r; product/r == l && !(l == -1 && r == math.MinInt64) && !(r == -1 && l == math.MinInt64) {
+				return product, nil
+			}
+		case "%":
+			if r == 0 {
+				return nil, errors.New("division by zero")
+			}
+
+			return l % r, nil
+		case "/":
+			if r == 0 {
+				return nil, errors.New("division by zero")
+			}
+
+			return float64(l) / float64(r), nil
+		default:
+			return nil, errors.New(fmt.Sprintf("%s is not a valid operator", operator))
+		}
+	}
+
+	_, leftFloat := left.(float64)
+	_, rightFloat := right.(float64)
+
+	if leftFloat || rightFloat {
+		lf, _ := CastValue(left, Double)
+		rf, _ := CastValue(right, Double)
+
+		return floatArithmetic(operator, lf.(float64), rf.(float64))
+	}
+
+	// exact arithmetic for decimals and for integers that overflowed
+	lr, rr := toRat(left), toRat(right)
+	scale := tern(scaleOf(left) > scaleOf(right), scaleOf(left), scaleOf(right))
+
+	switch operator {
+	case "+":
+		return DecimalValue{Rat: new(big.Rat).Add(lr, rr), Scale: scale}, nil
+	case "-":
+		return DecimalValue{Rat: new(big.Rat).Sub(lr, rr), Scale: scale}, nil
+	case "*":
+		return DecimalValue{Rat: new(big.Rat).Mul(lr, rr), Scale: scaleOf(left) + scaleOf(right)}, nil
+	case "/":
+		if rr.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		quotientScale := tern(scale > 6, scale, 6)
+
+		return DecimalValue{Rat: new(big.Rat).Quo(lr, rr)}.withScale(quotientScale), nil
+	case "%":
+		lf, _ := lr.Float64()
+		rf, _ := rr.Float64()
+
+		return floatArithmetic(operator, lf, rf)
+	}
+
+	return nil, errors.New(fmt.Sprintf("%s is not a valid operator", operator))
+}
+
+func scaleOf(value interface{}) int {
+	if decimal, ok := value.(DecimalValue); ok {
+		return decimal.Scale
+	}
+
+	return 0
+}
+
+func floatArithmetic(operator string, left float64, right float64) (interface{}, error) {
+	switch operator {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/":
+		if right == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		return left / right, nil
+	case "%":
+		if right == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		return math.Mod(left, right), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("%s is not a valid operator", operator))
+}
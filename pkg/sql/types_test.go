@@ -0,0 +1,108 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCastsValues(t *testing.T) {
+	cases := []struct {
+		value  interface{}
+		to     Type
+		expect string
+	}{
+		{"42", BigInt, "42"},
+		{"1.25", Double, "1.25"},
+		{42, Varchar, "42"},
+		{"1.50", Decimal, "1.50"},
+		{1.5, BigInt, "2"},
+		{"true", Boolean, "true"},
+		{int64(0), Boolean, "false"},
+		{"2024-01-01T00:00:00Z", Timestamp, "2024-01-01T00:00:00Z"},
+		{`{"a": [1, 2]}`, Json, `{"a":[1,2]}`},
+	}
+
+	for _, c := range cases {
+		result, err := CastValue(c.value, c.to)
+		if err != nil {
+			t.Errorf("%v as %s: %s", c.value, c.to, err)
+			continue
+		}
+
+		rendered, err := CastValue(result, Varchar)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if rendered != c.expect {
+			t.Errorf("%v as %s: expected %s got %s", c.value, c.to, c.expect, rendered)
+		}
+	}
+}
+
+func TestCastFailures(t *testing.T) {
+	for _, to := range []Type{BigInt, Double, Decimal, Boolean, Timestamp} {
+		if _, err := CastValue("bob", to); err == nil {
+			t.Errorf("expected bob to fail casting to %s", to)
+		}
+	}
+
+	if result, err := CastValue(nil, BigInt); err != nil || result != nil {
+		t.Fail()
+	}
+}
+
+func TestComparesNumbersExactly(t *testing.T) {
+	if result, ok := compareValues(int64(9007199254740993), float64(9007199254740992)); !ok || result != 1 {
+		t.Fail()
+	}
+
+	decimal, _ := parseDecimal("0.1")
+	if result, ok := compareValues(decimal, float64(0.1)); !ok || result != 0 {
+		t.Fail()
+	}
+
+	if _, ok := compareValues("1", int64(1)); ok {
+		t.Fail()
+	}
+
+	if result, ok := compareValues(time.Unix(1, 0), time.Unix(2, 0)); !ok || result != -1 {
+		t.Fail()
+	}
+}
+
+func TestRunsZeroDecimals(t *testing.T) {
+	rows := []input.DataRow{{"x": -1}, {"x": 0}, {"x": 2}}
+
+	query, err := Select("x", Fn("abs", Lit(DecimalValue{})).As("zero")).Where(Col("x").Gt(DecimalValue{})).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0]["x"] != 2 || result[0]["zero"].(DecimalValue).String() != "0" {
+		t.Errorf("expected x = 2 with a zero decimal got %v", result)
+	}
+
+	// a hand built query doesn't go through the builder
+	query = &Query{
+		Fields: []Field{{Alias: "y", Expr: &Expr{Binary: &Binary{Operator: "+", Left: *NewColumn("x"), Right: *NewLiteral(DecimalValue{})}}}},
+		Group:  &PredicateGroup{Predicate: []Tree{NewLeaf(Leaf{Field: "x", Compare: Lte, Value: DecimalValue{}})}},
+	}
+
+	result, err = NewExecutor(*query).QueryData(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || !reflect.DeepEqual([]string{result[0]["y"].(DecimalValue).String(), result[1]["y"].(DecimalValue).String()}, []string{"-1", "0"}) {
+		t.Errorf("expected -1 and 0 got %v", result)
+	}
+}
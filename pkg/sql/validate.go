@@ -0,0 +1,443 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validate checks every function the query calls exists and gets the right number of arguments, that
+// arguments to registered functions have their declared types where the type is known before reading any rows
+// and that every joined table can be told apart. QueryData validates before it starts
+func (s *Executor) Validate() error {
+	if err := s.validateWith(); err != nil {
+		return err
+	}
+
+	for _, field := range s.sql.Fields {
+		expr := field.Expr
+		// a selected unnest is expanded into rows
+		if isUnnest(expr) && len(expr.Call.Args) == 1 {
+			expr = &expr.Call.Args[0]
+		}
+
+		if err := s.validateExpr(expr, true); err != nil {
+			return err
+		}
+	}
+
+	if err := s.validateGroup(s.sql.Group); err != nil {
+		return err
+	}
+
+	if err := s.validateJoins(); err != nil {
+		return err
+	}
+
+	for i := range s.sql.GroupBy {
+		if err := s.validateExpr(&s.sql.GroupBy[i], false); err != nil {
+			return err
+		}
+	}
+
+	if err := s.validateSets(); err != nil {
+		return err
+	}
+
+	return s.validateOrderBy()
+}
+
+// queries can only be combined when they select the same number of columns, either every one selects * or none do
+func (s *Executor) validateSets() error {
+	keys := outputKeys(s.sql)
+
+	for _, set := range s.sql.Sets {
+		other := outputKeys(set.Query)
+		if len(other) != len(keys) || (other == nil) != (keys == nil) {
+			return errors.New(fmt.Sprintf("both sides of %s must select the same number of columns", set.Operator))
+		}
+
+		if err := s.subExecutor(set.Query, nil).Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// every table needs its own name to qualify its columns with, and join conditions can't aggregate. Derived tables
+// are validated like any other query
+func (s *Executor) validateJoins() error {
+	seen := map[string]bool{}
+	if s.sql.From != nil {
+		seen[s.sql.From.qualifier()] = true
+
+		if s.sql.From.Lateral || s.sql.From.Unnest != nil {
+			return errors.New(fmt.Sprintf("%s has to be joined to the table it reads", s.sql.From))
+		}
+
+		if err := s.validateTable(*s.sql.From); err != nil {
+			return err
+		}
+	}
+
+	for _, join := range s.sql.Joins {
+		qualifier := join.Table.qualifier()
+		if seen[qualifier] {
+			return errors.New(fmt.Sprintf("table name %s is used more than once, give one of them an alias", qualifier))
+		}
+
+		seen[qualifier] = true
+
+		if err := s.validateTable(join.Table); err != nil {
+			return err
+		}
+
+		if join.On == nil && len(join.Using) == 0 && join.Kind != CrossJoin {
+			return errors.New(fmt.Sprintf("join %s needs an on or using clause", join.Table))
+		}
+
+		lateral := join.Table.Lateral || join.Table.Unnest != nil
+		if lateral && join.Kind != InnerJoin && join.Kind != LeftJoin && join.Kind != CrossJoin {
+			return errors.New(fmt.Sprintf("%s can only be joined with an inner, left or cross join", join.Table))
+		}
+
+		if err := s.validateGroup(join.On); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// common tables need their own names and as many column names as they select columns, the recursive term of one
+// has to select as many columns as its query
+func (s *Executor) validateWith() error {
+	seen := map[string]bool{}
+
+	for _, cte := range s.sql.With {
+		if seen[cte.Name] {
+			return errors.New(fmt.Sprintf("common table %s is defined more than once", cte.Name))
+		}
+
+		seen[cte.Name] = true
+
+		keys := outputKeys(cte.Query)
+		if len(cte.Columns) > 0 && len(cte.Columns) != len(keys) {
+			return errors.New(fmt.Sprintf("common table %s names %d columns but selects %d", cte.Name, len(cte.Columns), len(keys)))
+		}
+
+		if err := s.subExecutor(cte.Query, nil).Validate(); err != nil {
+			return err
+		}
+
+		if cte.Recursive == nil {
+			continue
+		}
+
+		if keys == nil || len(outputKeys(*cte.Recursive)) != len(keys) {
+			return errors.New(fmt.Sprintf("both sides of the union of common table %s must select the same number of columns", cte.Name))
+		}
+
+		if err := s.subExecutor(*cte.Recursive, nil).Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Executor) validateTable(table Table) error {
+	if table.Unnest != nil {
+		return s.validateExpr(table.Unnest, false)
+	}
+
+	if table.Query == nil {
+		return nil
+	}
+
+	return s.subExecutor(*table.Query, nil).Validate()
+}
+
+func (s *Executor) validateGroup(group *PredicateGroup) error {
+	if group == nil {
+		return nil
+	}
+
+	for _, predicate := range group.Predicate {
+		if leaf := predicate.Leaf; leaf != nil {
+			leftAny, rightAny := anyArg(leaf.Left), anyArg(leaf.Right)
+			if leftAny != nil && rightAny != nil || (leftAny != nil || rightAny != nil) && (leaf.Compare == "" || leaf.Compare == In) {
+				return errors.New("any(array) has to be compared with a value, as in any(tags) = 'x'")
+			}
+
+			if err := s.validateExpr(tern(leftAny != nil, leftAny, leaf.Left), false); err != nil {
+				return err
+			}
+
+			if err := s.validateExpr(tern(rightAny != nil, rightAny, leaf.Right), false); err != nil {
+				return err
+			}
+
+			for _, param := range listParams(leaf) {
+				if _, err := s.param(param); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := s.validateGroup(predicate.Group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// aggregates are only allowed in the selected fields and never inside another aggregate
+func (s *Executor) validateExpr(expr *Expr, aggregates bool) error {
+	switch {
+	case expr == nil:
+		return nil
+	case expr.Cast != nil:
+		return s.validateExpr(&expr.Cast.Expr, aggregates)
+	case expr.Binary != nil:
+		if err := s.validateExpr(&expr.Binary.Left, aggregates); err != nil {
+			return err
+		}
+
+		return s.validateExpr(&expr.Binary.Right, aggregates)
+	case expr.Case != nil:
+		for _, inner := range expr.Case.exprs() {
+			if err := s.validateExpr(inner, aggregates); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case expr.Subquery != nil:
+		if _, err := subqueryColumn(expr.Subquery); err != nil {
+			return err
+		}
+
+		return s.subExecutor(*expr.Subquery, nil).Validate()
+	case expr.Exists != nil:
+		return s.subExecutor(*expr.Exists, nil).Validate()
+	case expr.Param != nil:
+		_, err := s.param(*expr.Param)
+		return err
+	case expr.Call == nil:
+		return nil
+	case anyArg(expr) != nil:
+		return errors.New("any(array) can only be compared with a value, as in any(tags) = 'x'")
+	case isUnnest(expr):
+		return errors.New("unnest can only be selected or joined")
+	}
+
+	call := expr.Call
+
+	if call.Over != nil {
+		done, err := s.validateWindow(call, aggregates)
+		if done || err != nil {
+			return err
+		}
+	}
+
+	minArgs, maxArgs := 1, 1
+	inner := aggregates
+
+	// the declared argument types of a registered function
+	var argType func(i int) Type
+
+	if len(call.WithinGroup) > 0 {
+		if function, ok := s.aggregateFunction(call.Name); !ok || !function.orderedSet {
+			return errors.New(fmt.Sprintf("%s can't be used with within group", call.Name))
+		}
+
+		if len(call.WithinGroup) > 1 {
+			return errors.New(fmt.Sprintf("%s orders by a single expression", call.Name))
+		}
+
+		call = call.orderedSetCall()
+	}
+
+	if function, ok := s.aggregateFunction(call.Name); ok {
+		if !aggregates {
+			return errors.New(fmt.Sprintf("aggregate %s can only be used in the selected fields", call.Name))
+		}
+
+		minArgs, maxArgs = function.arity(), function.arity()
+
+		if function.args != nil {
+			argType = func(i int) Type { return function.args[i] }
+		}
+
+		for i := len(call.Args) - function.params; i >= 0 && i < len(call.Args); i++ {
+			if !s.constant(&call.Args[i]) {
+				return errors.New(fmt.Sprintf("argument %d of %s must be a constant", i+1, call.Name))
+			}
+		}
+
+		inner = false
+	} else {
+		function, ok := s.scalarFunction(call.Name)
+		if !ok && isWindowFunction(call.Name) {
+			return errors.New(fmt.Sprintf("window function %s needs an over clause", call.Name))
+		}
+
+		if !ok {
+			return errors.New(fmt.Sprintf("%s is not a valid function", call.Name))
+		}
+
+		minArgs, maxArgs = function.minArgs, function.maxArgs
+
+		if udf, ok := s.registry.scalar(call.Name); ok {
+			argType = udf.argType
+		}
+	}
+
+	if len(call.Args) < minArgs || (maxArgs >= 0 && len(call.Args) > maxArgs) {
+		return errors.New(fmt.Sprintf("wrong number of arguments to %s", call.Name))
+	}
+
+	for i := range call.Args {
+		if err := s.validateExpr(&call.Args[i], inner); err != nil {
+			return err
+		}
+	}
+
+	if argType == nil || s.lenient {
+		return nil
+	}
+
+	for i := range call.Args {
+		from, known := s.staticType(&call.Args[i])
+		if !known {
+			continue
+		}
+
+		if !assignable(from, argType(i)) {
+			return errors.New(fmt.Sprintf("argument %d of %s: expected %s but got %s", i+1, strings.ToLower(call.Name), argType(i), from))
+		}
+	}
+
+	return nil
+}
+
+// checks the over clause of a window call, done is true when the call is a window function and needs no more
+// checking, window aggregates go on to be checked like any other aggregate
+func (s *Executor) validateWindow(call *Call, selected bool) (done bool, err error) {
+	if !selected {
+		return true, errors.New(fmt.Sprintf("window function %s can only be used in the selected fields", call.Name))
+	}
+
+	if s.grouped() {
+		return true, errors.New(fmt.Sprintf("window function %s can't be used in a grouped query", call.Name))
+	}
+
+	for i := range call.Over.PartitionBy {
+		if err := s.validateExpr(&call.Over.PartitionBy[i], false); err != nil {
+			return true, err
+		}
+	}
+
+	for i := range call.Over.OrderBy {
+		if err := s.validateExpr(&call.Over.OrderBy[i].Expr, false); err != nil {
+			return true, err
+		}
+	}
+
+	if frame := call.Over.Frame; frame != nil {
+		offset := func(bound FrameBound) bool {
+			return bound.Kind == Preceding || bound.Kind == Following
+		}
+
+		switch {
+		case frame.Unit != Rows && frame.Unit != Range:
+			return true, errors.New(fmt.Sprintf("%s is not a valid frame unit", frame.Unit))
+		case frame.Start.Kind == UnboundedFollowing || frame.End.Kind == UnboundedPreceding:
+			return true, errors.New(fmt.Sprintf("%s between %s and %s is not a valid frame", frame.Unit, frame.Start, frame.End))
+		case frame.Unit == Range && (offset(frame.Start) || offset(frame.End)):
+			return true, errors.New("range frames can only be bounded by unbounded or current row")
+		}
+	}
+
+	function, ok := windowFunctions[strings.ToLower(call.Name)]
+	if !ok {
+		if !s.isAggregate(call.Name) {
+			return true, errors.New(fmt.Sprintf("%s is not a window function or aggregate", call.Name))
+		}
+
+		return false, nil
+	}
+
+	if len(call.WithinGroup) > 0 {
+		return true, errors.New(fmt.Sprintf("%s can't be used with within group", call.Name))
+	}
+
+	if len(call.Args) < function.minArgs || len(call.Args) > function.maxArgs {
+		return true, errors.New(fmt.Sprintf("wrong number of arguments to %s", call.Name))
+	}
+
+	for i := range call.Args {
+		if err := s.validateExpr(&call.Args[i], false); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// the type an expression has no matter the row, when that can be known up front
+func (s *Executor) staticType(expr *Expr) (Type, bool) {
+	switch {
+	case expr.Literal != nil:
+		return typeOf(normalize(expr.Literal.Value))
+	case expr.Param != nil:
+		// a bound value has the type of the go value it was bound from
+		value, err := s.param(*expr.Param)
+		if err != nil {
+			return "", false
+		}
+
+		return typeOf(value)
+	case expr.Cast != nil:
+		return expr.Cast.Type, true
+	case expr.Call != nil:
+		if udf, ok := s.registry.scalar(expr.Call.Name); ok {
+			return udf.Returns, true
+		}
+
+		if udf, ok := s.registry.aggregate(expr.Call.Name); ok {
+			return udf.Returns, true
+		}
+	}
+
+	return "", false
+}
+
+// true when the expression is the same for every row
+func (s *Executor) constant(expr *Expr) bool {
+	switch {
+	case expr.Literal != nil, expr.Param != nil:
+		return true
+	case expr.Cast != nil:
+		return s.constant(&expr.Cast.Expr)
+	case expr.Binary != nil:
+		return s.constant(&expr.Binary.Left) && s.constant(&expr.Binary.Right)
+	case expr.Call != nil:
+		if s.isAggregate(expr.Call.Name) {
+			return false
+		}
+
+		for i := range expr.Call.Args {
+			if !s.constant(&expr.Call.Args[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,500 @@
+package sql
+
+import (
+	"errors"
+	"example/pkg/input"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Window is the over (...) of a window function, rows are split into partitions and ordered within them
+type Window struct {
+	PartitionBy []Expr    `json:",omitempty"`
+	OrderBy     []OrderBy `json:",omitempty"`
+	Frame       *Frame    `json:",omitempty"`
+}
+
+type FrameUnit string
+
+const (
+	Rows FrameUnit = "rows"
+	// range frames count rows that order the same, peers, as one
+	Range = "range"
+)
+
+type BoundKind string
+
+const (
+	UnboundedPreceding BoundKind = "unbounded preceding"
+	Preceding                    = "preceding"
+	CurrentRow                   = "current row"
+	Following                    = "following"
+	UnboundedFollowing           = "unbounded following"
+)
+
+// Frame limits a window aggregate to the rows around the current one, rows between 5 preceding and current row
+type Frame struct {
+	Unit  FrameUnit
+	Start FrameBound
+	End   FrameBound
+}
+
+type FrameBound struct {
+	Kind BoundKind
+	// the number of rows for preceding and following
+	Offset int64 `json:",omitempty"`
+}
+
+// without a frame a window aggregate runs from the start of the partition to the current row and its peers,
+// which is the whole partition when there is no order by
+var defaultFrame = Frame{Unit: Range, Start: FrameBound{Kind: UnboundedPreceding}, End: FrameBound{Kind: CurrentRow}}
+
+func (w Window) String() string {
+	var parts []string
+
+	if len(w.PartitionBy) > 0 {
+		var exprs []string
+		for _, expr := range w.PartitionBy {
+			exprs = append(exprs, expr.String())
+		}
+
+		parts = append(parts, "partition by "+strings.Join(exprs, ", "))
+	}
+
+	if len(w.OrderBy) > 0 {
+		parts = append(parts, "order by "+orderByString(w.OrderBy))
+	}
+
+	if w.Frame != nil {
+		parts = append(parts, fmt.Sprintf("%s between %s and %s", w.Frame.Unit, w.Frame.Start, w.Frame.End))
+	}
+
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func (b FrameBound) String() string {
+	if b.Kind == Preceding || b.Kind == Following {
+		return fmt.Sprintf("%d %s", b.Offset, b.Kind)
+	}
+
+	return string(b.Kind)
+}
+
+// windowFunction computes a value for every row of an ordered partition
+type windowFunction struct {
+	minArgs int
+	maxArgs int
+	compute func(s *Executor, p *partition, call *Call, i int) (interface{}, error)
+}
+
+// filled in by init because the functions evaluate expressions, which can in turn compute windows
+var windowFunctions map[string]windowFunction
+
+func init() {
+	windowFunctions = map[string]windowFunction{
+		"row_number": {
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				return int64(i + 1), nil
+			},
+		},
+		// rows that order the same share a rank and leave a gap after them
+		"rank": {
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				return int64(p.peerStart[i] + 1), nil
+			},
+		},
+		// like rank without the gaps
+		"dense_rank": {
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				return int64(p.peerGroup[i] + 1), nil
+			},
+		},
+		// lag(x, offset, default) is x from offset rows before, 1 by default, or the default when there is no such row
+		"lag": {
+			minArgs: 1, maxArgs: 3,
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				return s.offsetValue(p, call, i, -1)
+			},
+		},
+		"lead": {
+			minArgs: 1, maxArgs: 3,
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				return s.offsetValue(p, call, i, 1)
+			},
+		},
+		"first_value": {
+			minArgs: 1, maxArgs: 1,
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				start, end := p.frame(i)
+				if start >= end {
+					return nil, nil
+				}
+
+				return s.eval(p.scope(start), &call.Args[0])
+			},
+		},
+		"last_value": {
+			minArgs: 1, maxArgs: 1,
+			compute: func(s *Executor, p *partition, call *Call, i int) (interface{}, error) {
+				start, end := p.frame(i)
+				if start >= end {
+					return nil, nil
+				}
+
+				return s.eval(p.scope(end-1), &call.Args[0])
+			},
+		},
+	}
+}
+
+func isWindowFunction(name string) bool {
+	_, ok := windowFunctions[strings.ToLower(name)]
+
+	return ok
+}
+
+// partition is the rows of one partition in window order
+type partition struct {
+	rows []input.DataRow
+	// where each row came from in the rows the window ran over
+	indexes []int
+	// rows that order the same are peers, peerStart and peerEnd bound each row's peers
+	peerStart []int
+	peerEnd   []int
+	peerGroup []int
+	frameSpec Frame
+	// the aliases being resolved when the window started
+	resolving map[KeyAlias]bool
+}
+
+func (p *partition) scope(i int) *scope {
+	sc := newScope(p.rows[i])
+	sc.resolving = p.resolving
+
+	return sc
+}
+
+// the rows of the frame around row i, from start up to but not including end
+func (p *partition) frame(i int) (int, int) {
+	bound := func(b FrameBound, end bool) int {
+		switch b.Kind {
+		case UnboundedPreceding:
+			return 0
+		case Preceding:
+			return i - int(b.Offset) + tern(end, 1, 0)
+		case CurrentRow:
+			if p.frameSpec.Unit == Range {
+				return tern(end, p.peerEnd[i], p.peerStart[i])
+			}
+
+			return i + tern(end, 1, 0)
+		case Following:
+			return i + int(b.Offset) + tern(end, 1, 0)
+		}
+
+		return len(p.rows)
+	}
+
+	clamp := func(index int) int {
+		return tern(index < 0, 0, tern(index > len(p.rows), len(p.rows), index))
+	}
+
+	return clamp(bound(p.frameSpec.Start, false)), clamp(bound(p.frameSpec.End, true))
+}
+
+func (s *Executor) offsetValue(p *partition, call *Call, i int, direction int) (interface{}, error) {
+	offset := 1
+
+	if len(call.Args) > 1 {
+		value, err := s.eval(p.scope(i), &call.Args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if offset, err = intArg(value); err != nil {
+			return nil, err
+		}
+	}
+
+	target := i + direction*offset
+	if target < 0 || target >= len(p.rows) {
+		if len(call.Args) > 2 {
+			return s.eval(p.scope(i), &call.Args[2])
+		}
+
+		return nil, nil
+	}
+
+	return s.eval(p.scope(target), &call.Args[0])
+}
+
+// the window calls in an expression, keyed by how they are written
+func windowCalls(expr *Expr, calls map[string]*Call) {
+	switch {
+	case expr == nil:
+	case expr.Call != nil && expr.Call.Over != nil:
+		calls[expr.String()] = expr.Call
+	case expr.Call != nil:
+		for i := range expr.Call.Args {
+			windowCalls(&expr.Call.Args[i], calls)
+		}
+	case expr.Cast != nil:
+		windowCalls(&expr.Cast.Expr, calls)
+	case expr.Binary != nil:
+		windowCalls(&expr.Binary.Left, calls)
+		windowCalls(&expr.Binary.Right, calls)
+	case expr.Case != nil:
+		for _, inner := range expr.Case.exprs() {
+			windowCalls(inner, calls)
+		}
+	}
+}
+
+// evaluates every window call of the selected fields, giving the values of each row keyed by the call
+func (s *Executor) windows(rows []input.DataRow) ([]map[string]interface{}, error) {
+	values := make([]map[string]interface{}, len(rows))
+	for i := range values {
+		values[i] = map[string]interface{}{}
+	}
+
+	for _, field := range s.sql.Fields {
+		calls := map[string]*Call{}
+		windowCalls(field.Expr, calls)
+
+		for key, call := range calls {
+			// a window inside a field reading its own alias reads the row
+			resolving := map[KeyAlias]bool{field.Alias: true}
+
+			partitions, err := s.partition(rows, call.Over, resolving)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, p := range partitions {
+				results, err := s.computeWindow(p, call)
+				if err != nil {
+					return nil, err
+				}
+
+				for i, result := range results {
+					values[p.indexes[i]][key] = result
+				}
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// splits the rows by the partition by values, in the order each partition first appears, and sorts each one
+func (s *Executor) partition(rows []input.DataRow, window *Window, resolving map[KeyAlias]bool) ([]*partition, error) {
+	var partitions []*partition
+	index := map[string]*partition{}
+
+	frame := defaultFrame
+	if window.Frame != nil {
+		frame = *window.Frame
+	}
+
+	for i, row := range rows {
+		sc := newScope(row)
+		sc.resolving = resolving
+
+		var keys []interface{}
+		for j := range window.PartitionBy {
+			key, err := s.eval(sc, &window.PartitionBy[j])
+			if err != nil {
+				return nil, err
+			}
+
+			keys = append(keys, key)
+		}
+
+		id := groupId(keys)
+
+		p, ok := index[id]
+		if !ok {
+			p = &partition{frameSpec: frame, resolving: resolving}
+			index[id] = p
+			partitions = append(partitions, p)
+		}
+
+		p.rows = append(p.rows, row)
+		p.indexes = append(p.indexes, i)
+	}
+
+	for _, p := range partitions {
+		if err := s.sortPartition(p, window.OrderBy); err != nil {
+			return nil, err
+		}
+	}
+
+	return partitions, nil
+}
+
+// sorts the partition by the order by values, nulls sort after everything else, and finds each row's peers
+func (s *Executor) sortPartition(p *partition, orderBy []OrderBy) error {
+	keys := make([][]interface{}, len(p.rows))
+
+	for i := range p.rows {
+		for j := range orderBy {
+			key, err := s.eval(p.scope(i), &orderBy[j].Expr)
+			if err != nil {
+				return err
+			}
+
+			keys[i] = append(keys[i], s.comparisonValue(key))
+		}
+	}
+
+	var err error
+
+	compare := func(left []interface{}, right []interface{}) int {
+		for j, order := range orderBy {
+			result, ok := compareNullsLast(left[j], right[j])
+			if !ok && err == nil {
+				err = errors.New(fmt.Sprintf("cannot order %s with %s", reflect.TypeOf(left[j]), reflect.TypeOf(right[j])))
+			}
+
+			if result != 0 {
+				return tern(order.Desc, -result, result)
+			}
+		}
+
+		return 0
+	}
+
+	order := make([]int, len(p.rows))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return compare(keys[order[i]], keys[order[j]]) < 0
+	})
+
+	if err != nil {
+		return err
+	}
+
+	rows := make([]input.DataRow, len(p.rows))
+	indexes := make([]int, len(p.rows))
+
+	for i, from := range order {
+		rows[i], indexes[i] = p.rows[from], p.indexes[from]
+	}
+
+	p.rows, p.indexes = rows, indexes
+
+	p.peerStart = make([]int, len(rows))
+	p.peerEnd = make([]int, len(rows))
+	p.peerGroup = make([]int, len(rows))
+
+	for i := range rows {
+		switch {
+		case i == 0:
+		case compare(keys[order[i-1]], keys[order[i]]) == 0:
+			p.peerStart[i], p.peerGroup[i] = p.peerStart[i-1], p.peerGroup[i-1]
+		default:
+			p.peerStart[i], p.peerGroup[i] = i, p.peerGroup[i-1]+1
+		}
+	}
+
+	for i := len(rows) - 1; i >= 0; i-- {
+		if i < len(rows)-1 && p.peerStart[i+1] == p.peerStart[i] {
+			p.peerEnd[i] = p.peerEnd[i+1]
+		} else {
+			p.peerEnd[i] = i + 1
+		}
+	}
+
+	return nil
+}
+
+// nulls are larger than any value
+func compareNullsLast(left interface{}, right interface{}) (int, bool) {
+	switch {
+	case left == nil && right == nil:
+		return 0, true
+	case left == nil:
+		return 1, true
+	case right == nil:
+		return -1, true
+	}
+
+	return compareValues(left, right)
+}
+
+// the value of the window call for every row of the partition
+func (s *Executor) computeWindow(p *partition, call *Call) ([]interface{}, error) {
+	results := make([]interface{}, len(p.rows))
+
+	if function, ok := windowFunctions[strings.ToLower(call.Name)]; ok {
+		for i := range p.rows {
+			value, err := function.compute(s, p, call, i)
+			if err != nil {
+				return nil, err
+			}
+
+			results[i] = value
+		}
+
+		return results, nil
+	}
+
+	call, function, params, err := s.prepareAggregate(call)
+	if err != nil {
+		return nil, err
+	}
+
+	// frames that start at the start of the partition only grow, so one aggregate runs along the partition
+	if p.frameSpec.Start.Kind == UnboundedPreceding {
+		state, err := function.init(params)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+
+		for i := range p.rows {
+			_, end := p.frame(i)
+
+			if end > added {
+				if err := s.accumulateRows(p.resolving, call, function, state, p.rows[added:end]); err != nil {
+					return nil, err
+				}
+
+				added = end
+			}
+
+			if results[i], err = s.finalize(call, function, state); err != nil {
+				return nil, err
+			}
+		}
+
+		return results, nil
+	}
+
+	for i := range p.rows {
+		start, end := p.frame(i)
+
+		state, err := function.init(params)
+		if err != nil {
+			return nil, err
+		}
+
+		if start < end {
+			if err := s.accumulateRows(p.resolving, call, function, state, p.rows[start:end]); err != nil {
+				return nil, err
+			}
+		}
+
+		if results[i], err = s.finalize(call, function, state); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
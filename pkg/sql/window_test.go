@@ -0,0 +1,177 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"reflect"
+	"testing"
+)
+
+func TestParsesWindows(t *testing.T) {
+	for _, sql := range []string{
+		"sum(x) over (partition by user order by ts rows between 5 preceding and current row)",
+		"row_number() over (order by ts desc, id)",
+		"lag(x, 2, 0) over (partition by a, b)",
+		"avg(x) over ()",
+		"count(*) over (order by ts range between unbounded preceding and unbounded following)",
+	} {
+		result, err := Parse("select " + sql + " as w")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Fields[0].Expr.String() != sql {
+			t.Errorf("expected %s got %s", sql, result.Fields[0].Expr.String())
+		}
+	}
+
+	result, err := Parse("select sum(x) over (order by ts rows 2 preceding) as w")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame := result.Fields[0].Expr.Call.Over.Frame; *frame != (Frame{Unit: Rows, Start: FrameBound{Kind: Preceding, Offset: 2}, End: FrameBound{Kind: CurrentRow}}) {
+		t.Logf("%s", toJson(result, t))
+		t.Fail()
+	}
+
+	for _, sql := range []string{
+		"select sum(x) over (rows between potato preceding and current row) as w",
+		"select sum(x) over (rows between 1 preceding) as w",
+		"select sum(x) over (order by) as w",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
+
+func TestRankingWindows(t *testing.T) {
+	query, err := Parse(`select user, score,
+		row_number() over (partition by user order by score desc) as n,
+		rank() over (order by score desc) as rank,
+		dense_rank() over (order by score desc) as dense,
+		lag(score) over (partition by user order by score) as previous,
+		lead(score, 1, -1) over (partition by user order by score) as next`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{
+		{"user": "a", "score": 10},
+		{"user": "b", "score": 30},
+		{"user": "a", "score": 30},
+		{"user": "a", "score": 20},
+		{"user": "b", "score": 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []input.DataRow{
+		{"user": "a", "score": 10, "n": int64(3), "rank": int64(4), "dense": int64(3), "previous": nil, "next": int64(20)},
+		{"user": "b", "score": 30, "n": int64(1), "rank": int64(1), "dense": int64(1), "previous": int64(5), "next": int64(-1)},
+		{"user": "a", "score": 30, "n": int64(1), "rank": int64(1), "dense": int64(1), "previous": int64(20), "next": int64(-1)},
+		{"user": "a", "score": 20, "n": int64(2), "rank": int64(3), "dense": int64(2), "previous": int64(10), "next": int64(30)},
+		{"user": "b", "score": 5, "n": int64(2), "rank": int64(5), "dense": int64(4), "previous": nil, "next": int64(30)},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
+
+func TestFramedWindowAggregates(t *testing.T) {
+	query, err := Parse(`select ts,
+		sum(x) over (partition by user order by ts rows between 1 preceding and current row) as moving,
+		sum(x) over (partition by user order by ts) as running,
+		sum(x) over (partition by user) as total,
+		count(*) over (order by day) as peers,
+		first_value(x) over (partition by user order by ts) as first,
+		last_value(x) over (partition by user order by ts) as last,
+		last_value(x) over (partition by user order by ts rows between current row and unbounded following) as final,
+		avg(x) over (partition by user order by ts rows between 1 following and 2 following) as ahead,
+		median(x) over (partition by user) as median`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query).QueryData([]input.DataRow{
+		{"user": "a", "ts": 1, "day": 1, "x": 1},
+		{"user": "a", "ts": 2, "day": 1, "x": 2},
+		{"user": "b", "ts": 1, "day": 1, "x": 100},
+		{"user": "a", "ts": 3, "day": 2, "x": 3},
+		{"user": "a", "ts": 4, "day": 2, "x": 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []input.DataRow{
+		{"ts": 1, "moving": int64(1), "running": int64(1), "total": int64(10), "peers": int64(3), "first": int64(1), "last": int64(1), "final": int64(4), "ahead": 2.5, "median": 2.5},
+		{"ts": 2, "moving": int64(3), "running": int64(3), "total": int64(10), "peers": int64(3), "first": int64(1), "last": int64(2), "final": int64(4), "ahead": 3.5, "median": 2.5},
+		{"ts": 1, "moving": int64(100), "running": int64(100), "total": int64(100), "peers": int64(3), "first": int64(100), "last": int64(100), "final": int64(100), "ahead": nil, "median": 100.0},
+		{"ts": 3, "moving": int64(5), "running": int64(6), "total": int64(10), "peers": int64(5), "first": int64(1), "last": int64(3), "final": int64(4), "ahead": 4.0, "median": 2.5},
+		{"ts": 4, "moving": int64(7), "running": int64(10), "total": int64(10), "peers": int64(5), "first": int64(1), "last": int64(4), "final": int64(4), "ahead": nil, "median": 2.5},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
+
+func TestRegisteredAggregatesOverWindows(t *testing.T) {
+	registry := NewRegistry()
+
+	err := registry.RegisterAggregate("weighted_avg", AggregateUDF{
+		Args:    []Type{Double, Double},
+		Returns: Double,
+		Init:    func() Aggregate { return &weightedAverage{} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, err := Parse(`select weighted_avg(x, w) over (order by ts rows between 1 preceding and current row) as x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewExecutor(*query, WithRegistry(registry)).QueryData([]input.DataRow{
+		{"ts": 1, "x": 10, "w": 1},
+		{"ts": 2, "x": 40, "w": 2},
+		{"ts": 3, "x": 0, "w": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{{"x": 10.0}, {"x": 30.0}, {"x": 20.0}}) {
+		t.Logf("%v", result)
+		t.Fail()
+	}
+}
+
+func TestInvalidWindows(t *testing.T) {
+	for _, sql := range []string{
+		`select row_number() as n`,
+		`select x where row_number() over () > 1`,
+		`select lower(x) over () as y`,
+		`select row_number(x, y) over () as n`,
+		`select sum(x) over (range between 1 preceding and current row) as n`,
+		`select sum(x) over (rows between unbounded following and current row) as n`,
+		`select sum(row_number() over ()) as n`,
+		`select count(*) as n, row_number() over () as m`,
+		`select sum(x) over (partition by sum(x)) as n`,
+	} {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewExecutor(*query).QueryData([]input.DataRow{{"x": 1}}); err == nil {
+			t.Errorf("expected %s to fail", sql)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// WireVersion is the version of the json a query is sent as, it changes whenever a query written by one version
+// can't be read the same way by another
+const WireVersion = 1
+
+// the json a query is sent as, {"Version": 1, "Query": {...}}
+type wireQuery struct {
+	Version int
+	Query   *Query
+}
+
+// MarshalQuery writes the query as versioned json that UnmarshalQuery reads back to the same query. Literal values
+// keep their type, a value json can't tell apart from another type is written as {"Type": "double", "Value": 2}
+func MarshalQuery(query Query) ([]byte, error) {
+	return json.Marshal(wireQuery{Version: WireVersion, Query: &query})
+}
+
+// UnmarshalQuery reads a query written by MarshalQuery or built as json by hand, and checks that it is well formed. Whether
+// its columns and functions exist is checked by Executor.Validate when it runs
+func UnmarshalQuery(data []byte) (*Query, error) {
+	var version struct{ Version int }
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid query json: %s", err))
+	}
+
+	if version.Version != WireVersion {
+		return nil, errors.New(fmt.Sprintf("unsupported query version %d, expected %d", version.Version, WireVersion))
+	}
+
+	var wire wireQuery
+	if err := decodeStrict(data, &wire); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid query json: %s", err))
+	}
+
+	if wire.Query == nil {
+		return nil, errors.New("invalid query json: missing Query")
+	}
+
+	if err := checkQuery(wire.Query); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid query: %s", err))
+	}
+
+	return wire.Query, nil
+}
+
+// fails on fields the types don't have, so a misspelt field isn't silently dropped
+func decodeStrict(data []byte, into interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+
+	return decoder.Decode(into)
+}
+
+// a placeholder in an in list as json, {"Param": {"Position": 1}}
+type wireParam struct {
+	Param Param
+}
+
+// a literal value as json, values json reads back as the same type are written as they are
+type typedValue struct {
+	Type  Type
+	Value interface{}
+}
+
+func encodeValue(value interface{}) (interface{}, error) {
+	switch casted := normalize(value).(type) {
+	case nil, bool, string, int64:
+		return casted, nil
+	case float64:
+		if math.IsNaN(casted) || math.IsInf(casted, 0) {
+			return nil, errors.New(fmt.Sprintf("%v can't be written as json", casted))
+		}
+
+		// 2.0 would be read back as the bigint 2
+		if casted == math.Trunc(casted) {
+			return typedValue{Type: Double, Value: casted}, nil
+		}
+
+		return casted, nil
+	case DecimalValue:
+		return typedValue{Type: Decimal, Value: casted.String()}, nil
+	case time.Time:
+		return typedValue{Type: Timestamp, Value: casted.Format(time.RFC3339Nano)}, nil
+	case IntervalValue:
+		return typedValue{Type: Interval, Value: casted.String()}, nil
+	case Param:
+		// a placeholder in an in list
+		return wireParam{Param: casted}, nil
+	case map[string]interface{}:
+		return nil, errors.New("objects can't be literals")
+	}
+
+	array, err := arrayArg(value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("unsupported literal type %s", reflect.TypeOf(value)))
+	}
+
+	encoded := make([]interface{}, len(array))
+	for i, element := range array {
+		if encoded[i], err = encodeValue(element); err != nil {
+			return nil, err
+		}
+	}
+
+	return encoded, nil
+}
+
+// reads a value written by encodeValue, numbers read the way the parser reads them so 2 is a bigint and 2.5 a double
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var value interface{}
+	if err := decodeStrict(raw, &value); err != nil {
+		return nil, err
+	}
+
+	switch casted := value.(type) {
+	case nil, bool, string:
+		return casted, nil
+	case json.Number:
+		return parseNumber(string(casted))
+	case []interface{}:
+		decoded := make([]interface{}, len(casted))
+		for i := range casted {
+			element, err := json.Marshal(casted[i])
+			if err != nil {
+				return nil, err
+			}
+
+			if decoded[i], err = decodeValue(element); err != nil {
+				return nil, err
+			}
+		}
+
+		return decoded, nil
+	}
+
+	var param struct{ Param *Param }
+	if err := decodeStrict(raw, &param); err == nil && param.Param != nil {
+		return *param.Param, nil
+	}
+
+	var typed struct {
+		Type  Type
+		Value json.RawMessage
+	}
+	if err := decodeStrict(raw, &typed); err != nil {
+		return nil, errors.New(fmt.Sprintf("expected a value or {\"Type\": ..., \"Value\": ...} but got %s", raw))
+	}
+
+	switch typed.Type {
+	case Double:
+		var double float64
+		if err := json.Unmarshal(typed.Value, &double); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid double %s", typed.Value))
+		}
+
+		return double, nil
+	case Decimal, Timestamp, Interval:
+		var text string
+		if err := json.Unmarshal(typed.Value, &text); err != nil {
+			return nil, errors.New(fmt.Sprintf("a %s is written as a string but got %s", typed.Type, typed.Value))
+		}
+
+		return CastValue(text, typed.Type)
+	}
+
+	return nil, errors.New(fmt.Sprintf("unsupported literal type %s", typed.Type))
+}
+
+func (l Literal) MarshalJSON() ([]byte, error) {
+	value, err := encodeValue(l.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct{ Value interface{} }{value})
+}
+
+func (l *Literal) UnmarshalJSON(data []byte) error {
+	var wire struct{ Value json.RawMessage }
+	if err := decodeStrict(data, &wire); err != nil {
+		return err
+	}
+
+	value, err := decodeValue(wire.Value)
+	if err != nil {
+		return err
+	}
+
+	l.Value = value
+
+	return nil
+}
+
+// the json of a leaf, the same as its fields but with the value typed
+type wireLeaf struct {
+	Field   string
+	Compare ComparisonOperator
+	Value   interface{}
+	Left    *Expr `json:",omitempty"`
+	Right   *Expr `json:",omitempty"`
+}
+
+func (l Leaf) MarshalJSON() ([]byte, error) {
+	value, err := encodeValue(l.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(wireLeaf{Field: l.Field, Compare: l.Compare, Value: value, Left: l.Left, Right: l.Right})
+}
+
+func (l *Leaf) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		wireLeaf
+		Value json.RawMessage
+	}
+	if err := decodeStrict(data, &wire); err != nil {
+		return err
+	}
+
+	value, err := decodeValue(wire.Value)
+	if err != nil {
+		return err
+	}
+
+	*l = Leaf{Field: wire.Field, Compare: wire.Compare, Value: value, Left: wire.Left, Right: wire.Right}
+
+	return nil
+}
+
+// checks the parts of a query that the parser would never produce, like an expression with nothing set or an
+// operator that doesn't exist
+func checkQuery(query *Query) error {
+	var err error
+	fail := func(check error) {
+		if err == nil {
+			err = check
+		}
+	}
+
+	walkQuery(query, func(query *Query) {
+		if len(query.Fields) == 0 {
+			fail(errors.New("a query has to select at least one field"))
+		}
+
+		for _, field := range query.Fields {
+			if field.Name == "" && field.Expr == nil {
+				fail(errors.New("a field needs a Name or an Expr"))
+			}
+		}
+
+		for _, cte := range query.With {
+			if cte.Name == "" {
+				fail(errors.New("a common table needs a Name"))
+			}
+		}
+
+		if query.From != nil {
+			fail(checkTable(*query.From))
+		}
+
+		for _, join := range query.Joins {
+			fail(checkTable(join.Table))
+
+			if !slices.Contains([]JoinKind{InnerJoin, LeftJoin, RightJoin, FullJoin, CrossJoin}, join.Kind) {
+				fail(errors.New(fmt.Sprintf("%s is not a join kind", join.Kind)))
+			}
+
+			if (join.Kind == CrossJoin) != (join.On == nil && len(join.Using) == 0) {
+				fail(errors.New(fmt.Sprintf("a %s join needs either On or Using unless it is a cross join", join.Kind)))
+			}
+
+			fail(checkGroup(join.On))
+		}
+
+		fail(checkGroup(query.Group))
+
+		for _, set := range query.Sets {
+			if !slices.Contains([]SetOperator{Union, Intersect, Except}, set.Operator) {
+				fail(errors.New(fmt.Sprintf("%s is not a set operator", set.Operator)))
+			}
+		}
+	}, func(expr *Expr) {
+		fail(checkExpr(expr))
+	})
+
+	return err
+}
+
+func checkTable(table Table) error {
+	sources := 0
+	for _, set := range []bool{table.Name != "", table.Query != nil, table.File != "", table.Unnest != nil} {
+		sources += tern(set, 1, 0)
+	}
+
+	if sources != 1 {
+		return errors.New("a table needs exactly one of Name, Query, File or Unnest")
+	}
+
+	return nil
+}
+
+// every expression has exactly one member set
+func checkExpr(expr *Expr) error {
+	set := 0
+	for _, member := range []bool{
+		expr.Column != "", expr.Literal != nil, expr.Cast != nil, expr.Call != nil, expr.Binary != nil,
+		expr.Case != nil, expr.Subquery != nil, expr.Exists != nil, expr.Param != nil,
+	} {
+		set += tern(member, 1, 0)
+	}
+
+	switch {
+	case set != 1:
+		return errors.New("an expression needs exactly one of Column, Literal, Cast, Call, Binary, Case, Subquery, Exists or Param")
+	case expr.Binary != nil:
+		if _, ok := binaryPrecedence[expr.Binary.Operator]; !ok {
+			return errors.New(fmt.Sprintf("%s is not an operator", expr.Binary.Operator))
+		}
+	case expr.Cast != nil:
+		if _, err := ParseType(string(expr.Cast.Type)); err != nil {
+			return err
+		}
+	case expr.Call != nil:
+		if expr.Call.Name == "" {
+			return errors.New("a call needs a Name")
+		}
+
+		if expr.Call.Over != nil && expr.Call.Over.Frame != nil {
+			return checkFrame(*expr.Call.Over.Frame)
+		}
+	case expr.Case != nil:
+		if len(expr.Case.Whens) == 0 {
+			return errors.New("a case needs at least one when")
+		}
+
+		for _, when := range expr.Case.Whens {
+			if (when.Condition == nil) == (expr.Case.Operand == nil) || (when.Value == nil) == (expr.Case.Operand != nil) {
+				return errors.New("a when needs a Value when the case has an Operand and a Condition when it doesn't")
+			}
+
+			if err := checkGroup(when.Condition); err != nil {
+				return err
+			}
+		}
+	case expr.Param != nil:
+		if (expr.Param.Name == "") == (expr.Param.Position == 0) {
+			return errors.New("a param needs either a Name or a Position")
+		}
+	}
+
+	return nil
+}
+
+func checkFrame(frame Frame) error {
+	if frame.Unit != Rows && frame.Unit != Range {
+		return errors.New(fmt.Sprintf("%s is not a frame unit", frame.Unit))
+	}
+
+	for _, bound := range []FrameBound{frame.Start, frame.End} {
+		if !slices.Contains([]BoundKind{UnboundedPreceding, Preceding, CurrentRow, Following, UnboundedFollowing}, bound.Kind) {
+			return errors.New(fmt.Sprintf("%s is not a frame bound", bound.Kind))
+		}
+
+		switch {
+		case bound.Offset < 0:
+			return errors.New(fmt.Sprintf("a frame can't be %d rows %s", bound.Offset, bound.Kind))
+		case bound.Offset != 0 && bound.Kind != Preceding && bound.Kind != Following:
+			return errors.New(fmt.Sprintf("%s takes no Offset", bound.Kind))
+		}
+	}
+
+	return nil
+}
+
+func checkGroup(group *PredicateGroup) error {
+	if group == nil {
+		return nil
+	}
+
+	if !slices.Contains([]GroupingOperator{"", And, Or}, group.Operator) {
+		return errors.New(fmt.Sprintf("%s is not and or or", group.Operator))
+	}
+
+	for _, predicate := range group.Predicate {
+		if (predicate.Leaf == nil) == (predicate.Group == nil) {
+			return errors.New("a predicate needs exactly one of Leaf or Group")
+		}
+
+		if err := checkGroup(predicate.Group); err != nil {
+			return err
+		}
+
+		leaf := predicate.Leaf
+		if leaf == nil {
+			continue
+		}
+
+		if (leaf.Field == "") == (leaf.Left == nil) {
+			return errors.New("a leaf needs exactly one of Field or Left")
+		}
+
+		switch leaf.Compare {
+		case "", Eq, Neq, Gt, Lt, Gte, Lte:
+		case In:
+			if _, err := arrayArg(leaf.Value); err != nil && leaf.Right == nil {
+				return errors.New("in needs a list of values or a Right")
+			}
+		default:
+			return errors.New(fmt.Sprintf("%s is not a comparison", leaf.Compare))
+		}
+
+		if leaf.Compare == "" && (leaf.Value != nil || leaf.Right != nil) {
+			return errors.New("a leaf without a Compare can't have a Value or Right")
+		}
+
+		if _, ok := leaf.Value.(Param); ok {
+			return errors.New("a placeholder is a Param in Right unless it is in an in list")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package sql
+
+import (
+	"example/pkg/input"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWireRoundTrips(t *testing.T) {
+	for _, sql := range roundTrips {
+		query, err := Parse(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := MarshalQuery(*query)
+		if err != nil {
+			t.Fatalf("%s: %s", sql, err)
+		}
+
+		decoded, err := UnmarshalQuery(encoded)
+		if err != nil {
+			t.Fatalf("%s: %s in %s", sql, err, encoded)
+		}
+
+		if !reflect.DeepEqual(query, decoded) {
+			t.Errorf("%s: expected %s got %s", sql, toJson(query, t), toJson(decoded, t))
+		}
+	}
+}
+
+func TestWireKeepsTypes(t *testing.T) {
+	decimal, err := parseDecimal("12.50")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []interface{}{
+		nil, true, "2", int64(2), 2.0, 2.5, decimal,
+		time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), IntervalValue{Months: 1, Duration: time.Hour},
+		[]interface{}{int64(1), 1.0, "1"},
+	}
+
+	for _, value := range values {
+		query := Query{
+			Fields: []Field{{Alias: "x", Expr: NewLiteral(value)}},
+			Group:  &PredicateGroup{Predicate: []Tree{NewLeaf(Leaf{Field: "y", Compare: tern[ComparisonOperator](reflect.TypeOf(value) != nil && reflect.TypeOf(value).Kind() == reflect.Slice, In, Eq), Value: value})}},
+		}
+
+		encoded, err := MarshalQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := UnmarshalQuery(encoded)
+		if err != nil {
+			t.Fatalf("%v: %s in %s", value, err, encoded)
+		}
+
+		if !reflect.DeepEqual(decoded.Fields[0].Expr.Literal.Value, value) || !reflect.DeepEqual(decoded.Group.Predicate[0].Leaf.Value, value) {
+			t.Errorf("expected %v (%T) got %s", value, value, encoded)
+		}
+	}
+}
+
+func TestWireWritesZeroDecimals(t *testing.T) {
+	query, err := Select(Lit(DecimalValue{}).As("d")).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := MarshalQuery(*query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := UnmarshalQuery(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.String() != "select 0 as d" {
+		t.Errorf("expected a zero decimal got %s", decoded)
+	}
+}
+
+func TestWireQueriesBuiltAsJson(t *testing.T) {
+	query, err := UnmarshalQuery([]byte(`{
+		"Version": 1,
+		"Query": {
+			"Fields": [{"Name": "host", "Alias": "host"}, {"Alias": "n", "Expr": {"Call": {"Name": "count", "Args": [{"Column": "*"}]}}}],
+			"Group": {"Operator": "and", "Predicate": [
+				{"Leaf": {"Field": "status", "Compare": "=", "Value": 500}},
+				{"Leaf": {"Field": "ms", "Compare": ">", "Value": {"Type": "double", "Value": 10}}}
+			]},
+			"GroupBy": [{"Column": "host"}]
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "select host, count(*) as n where status = 500 and ms > 10.0 group by host"
+	if query.String() != expected {
+		t.Errorf("expected %s got %s", expected, query)
+	}
+
+	if query.Group.Predicate[1].Leaf.Value != 10.0 {
+		t.Errorf("expected a double got %#v", query.Group.Predicate[1].Leaf.Value)
+	}
+
+	result, err := NewExecutor(*query).QueryData(hostLogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result, []input.DataRow{{"host": "web1", "n": int64(1)}, {"host": "web2", "n": int64(2)}}) {
+		t.Errorf("got %v", result)
+	}
+}
+
+func TestInvalidWireQueries(t *testing.T) {
+	for _, test := range []struct {
+		json  string
+		error string
+	}{
+		{`{"Query": {"Fields": [{"Name": "a"}]}}`, "unsupported query version 0"},
+		{`{"Version": 2, "Query": {"Fields": [{"Name": "a"}]}}`, "unsupported query version 2"},
+		{`{"Version": 1}`, "missing Query"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Potato": 1}}`, "unknown field"},
+		{`{"Version": 1, "Query": {"Fields": []}}`, "at least one field"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "a", "Expr": {}}]}}`, "exactly one of Column"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "a", "Expr": {"Column": "a", "Literal": {"Value": 1}}}]}}`, "exactly one of Column"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "a", "Expr": {"Binary": {"Operator": "^", "Left": {"Column": "a"}, "Right": {"Column": "b"}}}}]}}`, "not an operator"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "a", "Expr": {"Cast": {"Expr": {"Column": "a"}, "Type": "potato"}}}]}}`, "not a valid type"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "a", "Expr": {"Literal": {"Value": {"Type": "potato", "Value": 1}}}}]}}`, "unsupported literal type"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "a", "Expr": {"Literal": {"Value": {"Type": "timestamp", "Value": "yesterday"}}}}]}}`, "yesterday"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Group": {"Operator": "xor", "Predicate": []}}}`, "not and or or"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Group": {"Predicate": [{}]}}}`, "exactly one of Leaf or Group"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Group": {"Predicate": [{"Leaf": {"Field": "a", "Compare": "~", "Value": 1}}]}}}`, "not a comparison"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Group": {"Predicate": [{"Leaf": {"Field": "a", "Compare": "in", "Value": 1}}]}}}`, "list of values"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Group": {"Predicate": [{"Leaf": {"Field": "a", "Compare": "=", "Value": {"Param": {"Position": 1}}}}]}}}`, "Param in Right"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Group": {"Predicate": [{"Leaf": {"Field": "a", "Compare": "in", "Value": [{"Param": {}}]}}]}}}`, "either a Name or a Position"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "From": {"Name": "a", "File": "b.json"}}}`, "exactly one of Name"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "From": {"Name": "a"}, "Joins": [{"Kind": "inner", "Table": {"Name": "b"}}]}}`, "needs either On or Using"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "n", "Expr": {"Call": {"Name": "count", "Args": [{"Column": "*"}], "Over": {"Frame": {"Unit": "rows", "Start": {"Kind": "preceding", "Offset": -5}, "End": {"Kind": "current row"}}}}}}]}}`, "can't be -5 rows preceding"},
+		{`{"Version": 1, "Query": {"Fields": [{"Alias": "n", "Expr": {"Call": {"Name": "count", "Args": [{"Column": "*"}], "Over": {"Frame": {"Unit": "rows", "Start": {"Kind": "unbounded preceding", "Offset": 2}, "End": {"Kind": "current row"}}}}}}]}}`, "takes no Offset"},
+		{`{"Version": 1, "Query": {"Fields": [{"Name": "a"}], "Sets": [{"Operator": "minus", "Query": {"Fields": [{"Name": "a"}]}}]}}`, "not a set operator"},
+	} {
+		if _, err := UnmarshalQuery([]byte(test.json)); err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("expected %s to fail with %s but got %v", test.json, test.error, err)
+		}
+	}
+}